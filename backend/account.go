@@ -0,0 +1,643 @@
+// Full account backup and restore: a versioned zip with every graph, its
+// metadata and its attachment files.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// accountArchiveVersion is bumped whenever the manifest layout changes in a
+// way older servers cannot read.
+const accountArchiveVersion = 1
+
+const accountManifestFile = "manifest.json"
+
+// ID-conflict strategies for POST /api/account/import.
+const (
+	restoreSkip      = "skip"
+	restoreOverwrite = "overwrite"
+	restoreDuplicate = "duplicate"
+)
+
+// accountManifest is manifest.json; graph data lives in graphs/<id>.json and
+// attachment bytes in attachments/<id>.
+type accountManifest struct {
+	Version     int                 `json:"version"`
+	ExportedAt  time.Time           `json:"exportedAt"`
+	Graphs      []accountGraph      `json:"graphs"`
+	Attachments []accountAttachment `json:"attachments"`
+}
+
+type accountGraph struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Folder    string    `json:"folder,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	File      string    `json:"file"`
+}
+
+type accountAttachment struct {
+	ID          string    `json:"id"`
+	GraphID     string    `json:"graphId"`
+	NodeID      string    `json:"nodeId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+	File        string    `json:"file"`
+}
+
+// accountRestoreResult reports what happened to one archived graph. ID is
+// the graph's id after the restore, which differs from SourceID for copies.
+type accountRestoreResult struct {
+	SourceID string `json:"sourceId"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type accountRestoreResponse struct {
+	Version     int                    `json:"version"`
+	Strategy    string                 `json:"strategy"`
+	Graphs      []accountRestoreResult `json:"graphs"`
+	Attachments int                    `json:"attachments"`
+	Warnings    []string               `json:"warnings"`
+}
+
+// GET /api/account/export: every graph of every kind plus attachments.
+func (s *server) handleAccountExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	manifest := accountManifest{Version: accountArchiveVersion, ExportedAt: time.Now().UTC()}
+	var graphData [][]byte
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT id, name, kind, folder, tags, updated_at, data
+		 FROM graphs
+		 WHERE user_id = $1
+		 ORDER BY updated_at, id`,
+		userID,
+	)
+	if err != nil {
+		log.Printf("failed to list graphs for account export: %v", err)
+		http.Error(w, "failed to export account", http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var graph accountGraph
+		var data []byte
+		if err := rows.Scan(&graph.ID, &graph.Name, &graph.Kind, &graph.Folder, &graph.Tags, &graph.UpdatedAt, &data); err != nil {
+			rows.Close()
+			log.Printf("failed to scan graph for account export: %v", err)
+			http.Error(w, "failed to export account", http.StatusInternalServerError)
+			return
+		}
+		graph.File = "graphs/" + graph.ID + ".json"
+		manifest.Graphs = append(manifest.Graphs, graph)
+		graphData = append(graphData, data)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list graphs for account export: %v", err)
+		http.Error(w, "failed to export account", http.StatusInternalServerError)
+		return
+	}
+
+	var storageKeys []string
+	rows, err = s.pool.Query(
+		ctx,
+		`SELECT id, graph_id, node_id, file_name, content_type, size_bytes, created_at, storage_key
+		 FROM node_attachments
+		 WHERE user_id = $1
+		 ORDER BY created_at, id`,
+		userID,
+	)
+	if err != nil {
+		log.Printf("failed to list attachments for account export: %v", err)
+		http.Error(w, "failed to export account", http.StatusInternalServerError)
+		return
+	}
+	var attachments []accountAttachment
+	for rows.Next() {
+		var item accountAttachment
+		var storageKey string
+		if err := rows.Scan(&item.ID, &item.GraphID, &item.NodeID, &item.FileName, &item.ContentType, &item.Size, &item.CreatedAt, &storageKey); err != nil {
+			rows.Close()
+			log.Printf("failed to scan attachment for account export: %v", err)
+			http.Error(w, "failed to export account", http.StatusInternalServerError)
+			return
+		}
+		item.File = "attachments/" + item.ID
+		attachments = append(attachments, item)
+		storageKeys = append(storageKeys, storageKey)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list attachments for account export: %v", err)
+		http.Error(w, "failed to export account", http.StatusInternalServerError)
+		return
+	}
+
+	// The archive is streamed, so failures past this point can only be
+	// logged; the client sees a truncated zip.
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "account-" + manifest.ExportedAt.Format("2006-01-02") + ".zip",
+	}))
+	zw := zip.NewWriter(w)
+	create := func(name string, modified time.Time) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	}
+	for i, graph := range manifest.Graphs {
+		writer, err := create(graph.File, graph.UpdatedAt)
+		if err == nil {
+			_, err = writer.Write(graphData[i])
+		}
+		if err != nil {
+			log.Printf("failed to write account export: %v", err)
+			return
+		}
+	}
+	manifest.Attachments = []accountAttachment{}
+	for i, item := range attachments {
+		body, err := s.blobs.Get(ctx, storageKeys[i])
+		if errors.Is(err, errBlobNotFound) {
+			log.Printf("skipping missing attachment blob %s in account export", storageKeys[i])
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to fetch attachment blob for account export: %v", err)
+			return
+		}
+		writer, err := create(item.File, item.CreatedAt)
+		if err == nil {
+			_, err = io.Copy(writer, body)
+		}
+		body.Close()
+		if err != nil {
+			log.Printf("failed to write account export: %v", err)
+			return
+		}
+		manifest.Attachments = append(manifest.Attachments, item)
+	}
+	if manifest.Graphs == nil {
+		manifest.Graphs = []accountGraph{}
+	}
+	// The manifest goes last so it only lists attachments that were written.
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		log.Printf("failed to encode account manifest: %v", err)
+		return
+	}
+	writer, err := create(accountManifestFile, manifest.ExportedAt)
+	if err == nil {
+		_, err = writer.Write(data)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Printf("failed to write account export: %v", err)
+	}
+}
+
+// POST /api/account/import?strategy=skip|overwrite|duplicate restores an
+// archive from GET /api/account/export. The strategy decides what happens to
+// graphs whose id already exists in the account (default skip); graphs whose
+// id belongs to another account are always restored as copies. Everything
+// is applied in one transaction.
+func (s *server) handleAccountImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	strategy := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("strategy")))
+	switch strategy {
+	case "":
+		strategy = restoreSkip
+	case restoreSkip, restoreOverwrite, restoreDuplicate:
+	default:
+		http.Error(w, "strategy must be skip, overwrite or duplicate", http.StatusBadRequest)
+		return
+	}
+
+	planCtx, planCancel := context.WithTimeout(r.Context(), 3*time.Second)
+	plan, limits, err := s.userPlan(planCtx, userID)
+	planCancel()
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to load plan", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := openRequestBody(w, r, maxArchiveBytes, maxArchiveBytes)
+	if errors.Is(err, errUnsupportedEncoding) {
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "archive is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	manifest, files, err := readAccountArchive(data)
+	if err != nil {
+		var importErr *errImport
+		if errors.As(err, &importErr) {
+			http.Error(w, importErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "invalid account archive", http.StatusUnprocessableEntity)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin account import: %v", err)
+		http.Error(w, "failed to import account", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	sourceIDs := make([]string, 0, len(manifest.Graphs))
+	for _, graph := range manifest.Graphs {
+		sourceIDs = append(sourceIDs, graph.ID)
+	}
+	owners := map[string]bool{}
+	rows, err := tx.Query(ctx, "SELECT id, user_id = $1 FROM graphs WHERE id = ANY($2)", userID, sourceIDs)
+	if err != nil {
+		log.Printf("failed to look up graph ids: %v", err)
+		http.Error(w, "failed to import account", http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var id string
+		var own bool
+		if err := rows.Scan(&id, &own); err != nil {
+			rows.Close()
+			log.Printf("failed to look up graph ids: %v", err)
+			http.Error(w, "failed to import account", http.StatusInternalServerError)
+			return
+		}
+		owners[id] = own
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to look up graph ids: %v", err)
+		http.Error(w, "failed to import account", http.StatusInternalServerError)
+		return
+	}
+
+	// Decide every graph's target id first so node links between archived
+	// graphs can be pointed at the copies.
+	response := accountRestoreResponse{Version: manifest.Version, Strategy: strategy, Graphs: []accountRestoreResult{}, Warnings: []string{}}
+	targets := make([]string, len(manifest.Graphs))
+	remapped := map[string]string{}
+	for i, graph := range manifest.Graphs {
+		own, exists := owners[graph.ID]
+		switch {
+		case !exists:
+			targets[i] = graph.ID
+		case own && strategy == restoreSkip:
+		case own && strategy == restoreOverwrite:
+			targets[i] = graph.ID
+		default:
+			id, err := generateID()
+			if err != nil {
+				http.Error(w, "failed to import account", http.StatusInternalServerError)
+				return
+			}
+			targets[i] = id
+			remapped[graph.ID] = id
+		}
+	}
+
+	var newKeys, oldKeys []string
+	restored := map[string]string{}
+	nodeIDs := map[string][]string{}
+	for i, graph := range manifest.Graphs {
+		result := accountRestoreResult{SourceID: graph.ID, Name: graph.Name}
+		id := targets[i]
+		if id == "" {
+			result.Status = "skipped"
+			response.Graphs = append(response.Graphs, result)
+			continue
+		}
+
+		var payload graphPayload
+		if err := json.Unmarshal(files[graph.File], &payload); err != nil {
+			result.Status = "invalid"
+			result.Error = "graph data is not valid JSON"
+			response.Graphs = append(response.Graphs, result)
+			continue
+		}
+		payload.Name = firstNonEmpty(strings.TrimSpace(graph.Name), payload.Name)
+		payload.Kind = firstNonEmpty(strings.TrimSpace(graph.Kind), payload.Kind)
+		normalizeGraphPayload(&payload)
+		if len(remapped) > 0 {
+			payload.Nodes = remapNodeLinks(payload.Nodes, remapped)
+		}
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			http.Error(w, "failed to import account", http.StatusInternalServerError)
+			return
+		}
+		if err := validateGraphData(encoded); err != nil {
+			result.Status = "invalid"
+			result.Error = err.Error()
+			response.Graphs = append(response.Graphs, result)
+			continue
+		}
+
+		quotaErr, err := checkGraphQuota(ctx, tx, userID, id, plan, limits, payload, len(encoded))
+		if err != nil {
+			log.Printf("failed to check quota: %v", err)
+			http.Error(w, "failed to import account", http.StatusInternalServerError)
+			return
+		}
+		if quotaErr != nil {
+			result.Status = "quota_exceeded"
+			result.Error = quotaErr.Message
+			response.Graphs = append(response.Graphs, result)
+			continue
+		}
+
+		result.ID = id
+		result.Status = "created"
+		if owners[graph.ID] && id == graph.ID {
+			result.Status = "overwritten"
+			// The archive carries the attachments to restore.
+			keys, err := deleteGraphAttachments(ctx, tx, userID, []string{id})
+			if err != nil {
+				log.Printf("failed to replace attachments: %v", err)
+				http.Error(w, "failed to import account", http.StatusInternalServerError)
+				return
+			}
+			oldKeys = append(oldKeys, keys...)
+		} else if id != graph.ID {
+			result.Status = "duplicated"
+		}
+
+		// Links are not validated: they were valid when exported and may
+		// point at graphs restored later in this archive.
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO graphs (id, user_id, name, kind, data, node_notes, tags, folder, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+			 ON CONFLICT (id) DO UPDATE
+			 SET name = EXCLUDED.name, kind = EXCLUDED.kind, data = EXCLUDED.data, node_notes = EXCLUDED.node_notes,
+			     tags = EXCLUDED.tags, folder = EXCLUDED.folder, updated_at = now()`,
+			id,
+			userID,
+			payload.Name,
+			payload.Kind,
+			encoded,
+			extractNodeNotes(payload.Nodes),
+			normalizeTags(graph.Tags),
+			strings.TrimSpace(graph.Folder),
+		)
+		if err == nil {
+			err = indexGraph(ctx, tx, userID, id, payload.Nodes, extractNodeLinks(id, payload.Nodes))
+		}
+		if err != nil {
+			log.Printf("failed to restore graph %s: %v", graph.ID, err)
+			http.Error(w, "failed to import account", http.StatusInternalServerError)
+			return
+		}
+		restored[graph.ID] = id
+		nodeIDs[graph.ID], _ = nodeIDsOf(payload.Nodes)
+		response.Graphs = append(response.Graphs, result)
+	}
+
+	fail := func(message string, err error) {
+		log.Printf("%s: %v", message, err)
+		s.deleteBlobs(context.WithoutCancel(ctx), newKeys)
+		http.Error(w, "failed to import account", http.StatusInternalServerError)
+	}
+	for _, item := range manifest.Attachments {
+		graphID, ok := restored[item.GraphID]
+		if !ok {
+			continue
+		}
+		content, ok := files[item.File]
+		if !ok {
+			response.Warnings = append(response.Warnings, "attachment "+item.FileName+" is missing from the archive")
+			continue
+		}
+		if !slices.Contains(nodeIDs[item.GraphID], item.NodeID) {
+			response.Warnings = append(response.Warnings, "attachment "+item.FileName+" belongs to a node that no longer exists")
+			continue
+		}
+		if len(content) == 0 || int64(len(content)) > s.maxAttachmentBytes {
+			response.Warnings = append(response.Warnings, "attachment "+item.FileName+" is empty or too large")
+			continue
+		}
+		// Archives are user-supplied, so types are checked as on upload.
+		contentType := http.DetectContentType(content)
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if _, ok := allowedAttachmentTypes[mediaType]; !ok {
+			response.Warnings = append(response.Warnings, "attachment "+item.FileName+" has unsupported type "+mediaType)
+			continue
+		}
+
+		quotaErr, err := checkAttachmentQuota(ctx, tx, userID, plan, limits, int64(len(content)))
+		if err != nil {
+			fail("failed to check quota", err)
+			return
+		}
+		if quotaErr != nil {
+			response.Warnings = append(response.Warnings, "attachment "+item.FileName+" was skipped: "+quotaErr.Message)
+			continue
+		}
+
+		id, err := generateID()
+		if err != nil {
+			fail("failed to generate attachment id", err)
+			return
+		}
+		storageKey := "attachments/" + id[:2] + "/" + id
+		if err := s.blobs.Put(ctx, storageKey, bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+			fail("failed to store attachment", err)
+			return
+		}
+		newKeys = append(newKeys, storageKey)
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO node_attachments (id, user_id, graph_id, node_id, file_name, content_type, size_bytes, storage_key, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, coalesce($9, now()))`,
+			id,
+			userID,
+			graphID,
+			item.NodeID,
+			sanitizeFileName(item.FileName),
+			contentType,
+			int64(len(content)),
+			storageKey,
+			nullableTime(item.CreatedAt),
+		)
+		if err != nil {
+			fail("failed to record attachment", err)
+			return
+		}
+		response.Attachments++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		fail("failed to commit account import", err)
+		return
+	}
+	s.deleteBlobs(ctx, oldKeys)
+	writeJSON(w, response)
+}
+
+// readAccountArchive unpacks an account archive and checks its manifest.
+func readAccountArchive(data []byte) (accountManifest, map[string][]byte, error) {
+	archived, err := readZipArchive(data)
+	if err != nil {
+		return accountManifest{}, nil, err
+	}
+	files := make(map[string][]byte, len(archived))
+	for _, file := range archived {
+		files[file.Name] = file.Data
+	}
+	raw, ok := files[accountManifestFile]
+	if !ok {
+		return accountManifest{}, nil, importErrorf("archive has no %s", accountManifestFile)
+	}
+	var manifest accountManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return accountManifest{}, nil, importErrorf("invalid %s: %v", accountManifestFile, err)
+	}
+	if manifest.Version < 1 {
+		return accountManifest{}, nil, importErrorf("%s has no version", accountManifestFile)
+	}
+	if manifest.Version > accountArchiveVersion {
+		return accountManifest{}, nil, importErrorf("archive version %d is newer than this server supports (%d)", manifest.Version, accountArchiveVersion)
+	}
+
+	seen := map[string]struct{}{}
+	for i := range manifest.Graphs {
+		manifest.Graphs[i].File = path.Clean(manifest.Graphs[i].File)
+		graph := manifest.Graphs[i]
+		if strings.TrimSpace(graph.ID) == "" {
+			return accountManifest{}, nil, importErrorf("%s lists a graph without an id", accountManifestFile)
+		}
+		if _, ok := seen[graph.ID]; ok {
+			return accountManifest{}, nil, importErrorf("%s lists graph %s twice", accountManifestFile, graph.ID)
+		}
+		seen[graph.ID] = struct{}{}
+		if _, ok := files[graph.File]; !ok {
+			return accountManifest{}, nil, importErrorf("graph file %q is missing from the archive", graph.File)
+		}
+	}
+	for i := range manifest.Attachments {
+		manifest.Attachments[i].File = path.Clean(manifest.Attachments[i].File)
+	}
+	return manifest, files, nil
+}
+
+// remapNodeLinks points data.links entries at the new ids of graphs that were
+// restored as copies. Nodes that cannot be decoded are left untouched.
+func remapNodeLinks(nodes json.RawMessage, ids map[string]string) json.RawMessage {
+	var parsed []map[string]json.RawMessage
+	if err := json.Unmarshal(nodes, &parsed); err != nil {
+		return nodes
+	}
+	changed := false
+	for _, node := range parsed {
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(node["data"], &data); err != nil {
+			continue
+		}
+		var links []map[string]any
+		if err := json.Unmarshal(data["links"], &links); err != nil || len(links) == 0 {
+			continue
+		}
+		linkChanged := false
+		for _, link := range links {
+			graphID, _ := link["graphId"].(string)
+			if id, ok := ids[graphID]; ok {
+				link["graphId"] = id
+				linkChanged = true
+			}
+		}
+		if !linkChanged {
+			continue
+		}
+		encodedLinks, err := json.Marshal(links)
+		if err != nil {
+			continue
+		}
+		data["links"] = encodedLinks
+		encodedData, err := json.Marshal(data)
+		if err != nil {
+			continue
+		}
+		node["data"] = encodedData
+		changed = true
+	}
+	if !changed {
+		return nodes
+	}
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		return nodes
+	}
+	return encoded
+}
+
+// nullableTime maps the zero time to SQL NULL.
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,491 @@
+// Node attachments: multipart upload, listing, download and cleanup.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultMaxAttachmentBytes = 10 << 20
+
+// Sniffed content types accepted for upload. SVG/HTML are excluded because
+// they can carry scripts when served inline.
+var allowedAttachmentTypes = map[string]struct{}{
+	"image/png":       {},
+	"image/jpeg":      {},
+	"image/gif":       {},
+	"image/webp":      {},
+	"image/bmp":       {},
+	"application/pdf": {},
+	"text/plain":      {},
+}
+
+type attachment struct {
+	ID          string    `json:"id"`
+	GraphID     string    `json:"graphId"`
+	NodeID      string    `json:"nodeId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+	URL         string    `json:"url"`
+}
+
+func attachmentURL(graphID, nodeID, id string) string {
+	return "/api/graphs/" + graphID + "/nodes/" + nodeID + "/attachments/" + id
+}
+
+// Routes /api/graphs/:id/nodes/:nodeId/<rest>.
+func (s *server) handleNodeSubresource(w http.ResponseWriter, r *http.Request, graphID, rest string) {
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 || parts[0] == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	nodeID := parts[0]
+	switch {
+	case parts[1] == "attachments" && len(parts) == 2:
+		switch r.Method {
+		case http.MethodGet:
+			s.handleListAttachments(w, r, graphID, nodeID)
+		case http.MethodPost:
+			s.handleUploadAttachment(w, r, graphID, nodeID)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case parts[1] == "attachments" && len(parts) == 3 && parts[2] != "":
+		switch r.Method {
+		case http.MethodGet:
+			s.handleGetAttachment(w, r, graphID, nodeID, parts[2])
+		case http.MethodDelete:
+			s.handleDeleteAttachment(w, r, graphID, nodeID, parts[2])
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case parts[1] == "backlinks" && len(parts) == 2:
+		s.handleBacklinks(w, r, graphID, nodeID)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// graphHasNode reports whether the user's graph exists and contains nodeID.
+func graphHasNode(ctx context.Context, q querier, userID, graphID, nodeID string) (bool, bool, error) {
+	var hasNode bool
+	err := q.QueryRow(
+		ctx,
+		`SELECT coalesce(data->'nodes' @> jsonb_build_array(jsonb_build_object('id', $3::text)), false)
+		 FROM graphs
+		 WHERE id = $1 AND user_id = $2`,
+		graphID,
+		userID,
+		nodeID,
+	).Scan(&hasNode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return true, hasNode, nil
+}
+
+// POST /api/graphs/:id/nodes/:nodeId/attachments (multipart field "file").
+func (s *server) handleUploadAttachment(w http.ResponseWriter, r *http.Request, graphID, nodeID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	graphExists, hasNode, err := graphHasNode(ctx, s.pool, userID, graphID, nodeID)
+	if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to upload attachment", http.StatusInternalServerError)
+		return
+	}
+	if !graphExists {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	}
+	if !hasNode {
+		http.Error(w, "node not found", http.StatusNotFound)
+		return
+	}
+
+	// Allow some room for multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxAttachmentBytes+64<<10)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "multipart body required", http.StatusBadRequest)
+		return
+	}
+
+	var fileName string
+	var content []byte
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeAttachmentReadError(w, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		fileName = sanitizeFileName(part.FileName())
+		content, err = io.ReadAll(io.LimitReader(part, s.maxAttachmentBytes+1))
+		part.Close()
+		if err != nil {
+			writeAttachmentReadError(w, err)
+			return
+		}
+		break
+	}
+	if content == nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	if int64(len(content)) > s.maxAttachmentBytes {
+		http.Error(w, fmt.Sprintf("attachment exceeds %d bytes", s.maxAttachmentBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(content) == 0 {
+		http.Error(w, "file is empty", http.StatusBadRequest)
+		return
+	}
+
+	contentType := http.DetectContentType(content)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if _, ok := allowedAttachmentTypes[mediaType]; !ok {
+		http.Error(w, "unsupported attachment type "+mediaType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	id, err := generateID()
+	if err != nil {
+		http.Error(w, "failed to upload attachment", http.StatusInternalServerError)
+		return
+	}
+	storageKey := "attachments/" + id[:2] + "/" + id
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to upload attachment", http.StatusInternalServerError)
+		return
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin transaction: %v", err)
+		http.Error(w, "failed to upload attachment", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	quotaErr, err := checkAttachmentQuota(ctx, tx, userID, plan, limits, int64(len(content)))
+	if err != nil {
+		log.Printf("failed to check quota: %v", err)
+		http.Error(w, "failed to upload attachment", http.StatusInternalServerError)
+		return
+	}
+	if quotaErr != nil {
+		writeQuotaError(w, quotaErr)
+		return
+	}
+
+	if err := s.blobs.Put(ctx, storageKey, bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+		log.Printf("failed to store attachment: %v", err)
+		http.Error(w, "failed to upload attachment", http.StatusBadGateway)
+		return
+	}
+
+	item := attachment{
+		ID:          id,
+		GraphID:     graphID,
+		NodeID:      nodeID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		URL:         attachmentURL(graphID, nodeID, id),
+	}
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO node_attachments (id, user_id, graph_id, node_id, file_name, content_type, size_bytes, storage_key)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING created_at`,
+		id,
+		userID,
+		graphID,
+		nodeID,
+		fileName,
+		contentType,
+		item.Size,
+		storageKey,
+	).Scan(&item.CreatedAt)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("failed to record attachment: %v", err)
+		s.deleteBlobs(context.WithoutCancel(ctx), []string{storageKey})
+		http.Error(w, "failed to upload attachment", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, item)
+}
+
+func writeAttachmentReadError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, "attachment is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "invalid multipart body", http.StatusBadRequest)
+}
+
+// sanitizeFileName keeps the base name and drops control characters.
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	return name
+}
+
+// GET /api/graphs/:id/nodes/:nodeId/attachments
+func (s *server) handleListAttachments(w http.ResponseWriter, r *http.Request, graphID, nodeID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT id, file_name, content_type, size_bytes, created_at
+		 FROM node_attachments
+		 WHERE user_id = $1 AND graph_id = $2 AND node_id = $3
+		 ORDER BY created_at`,
+		userID,
+		graphID,
+		nodeID,
+	)
+	if err != nil {
+		log.Printf("failed to list attachments: %v", err)
+		http.Error(w, "failed to list attachments", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []attachment{}
+	for rows.Next() {
+		item := attachment{GraphID: graphID, NodeID: nodeID}
+		if err := rows.Scan(&item.ID, &item.FileName, &item.ContentType, &item.Size, &item.CreatedAt); err != nil {
+			log.Printf("failed to scan attachment: %v", err)
+			http.Error(w, "failed to list attachments", http.StatusInternalServerError)
+			return
+		}
+		item.URL = attachmentURL(graphID, nodeID, item.ID)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list attachments: %v", err)
+		http.Error(w, "failed to list attachments", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, items)
+}
+
+// GET /api/graphs/:id/nodes/:nodeId/attachments/:attachmentId streams the file.
+func (s *server) handleGetAttachment(w http.ResponseWriter, r *http.Request, graphID, nodeID, id string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	var fileName, contentType, storageKey string
+	var size int64
+	err = s.pool.QueryRow(
+		ctx,
+		`SELECT file_name, content_type, size_bytes, storage_key
+		 FROM node_attachments
+		 WHERE id = $1 AND user_id = $2 AND graph_id = $3 AND node_id = $4`,
+		id,
+		userID,
+		graphID,
+		nodeID,
+	).Scan(&fileName, &contentType, &size, &storageKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read attachment: %v", err)
+		http.Error(w, "failed to load attachment", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := s.blobs.Get(ctx, storageKey)
+	if errors.Is(err, errBlobNotFound) {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to fetch attachment blob: %v", err)
+		http.Error(w, "failed to load attachment", http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(size))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, body)
+}
+
+// DELETE /api/graphs/:id/nodes/:nodeId/attachments/:attachmentId
+func (s *server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request, graphID, nodeID, id string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var storageKey string
+	err = s.pool.QueryRow(
+		ctx,
+		`DELETE FROM node_attachments
+		 WHERE id = $1 AND user_id = $2 AND graph_id = $3 AND node_id = $4
+		 RETURNING storage_key`,
+		id,
+		userID,
+		graphID,
+		nodeID,
+	).Scan(&storageKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to delete attachment: %v", err)
+		http.Error(w, "failed to delete attachment", http.StatusInternalServerError)
+		return
+	}
+
+	s.deleteBlobs(ctx, []string{storageKey})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pruneAttachments removes attachments of nodes no longer present in nodes.
+// Returned keys must be passed to deleteBlobs once the change is committed.
+func pruneAttachments(ctx context.Context, q querier, userID, graphID string, nodes json.RawMessage) ([]string, error) {
+	ids, ok := nodeIDsOf(nodes)
+	if !ok {
+		// Never treat an unreadable node list as "all nodes removed".
+		return nil, nil
+	}
+	return collectStorageKeys(q.Query(
+		ctx,
+		`DELETE FROM node_attachments
+		 WHERE user_id = $1 AND graph_id = $2 AND NOT (node_id = ANY($3))
+		 RETURNING storage_key`,
+		userID,
+		graphID,
+		ids,
+	))
+}
+
+// deleteGraphAttachments removes attachment rows for deleted graphs.
+// Returned keys must be passed to deleteBlobs once the change is committed.
+func deleteGraphAttachments(ctx context.Context, q querier, userID string, graphIDs []string) ([]string, error) {
+	return collectStorageKeys(q.Query(
+		ctx,
+		`DELETE FROM node_attachments
+		 WHERE user_id = $1 AND graph_id = ANY($2)
+		 RETURNING storage_key`,
+		userID,
+		graphIDs,
+	))
+}
+
+func collectStorageKeys(rows pgx.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// deleteBlobs removes stored files; failures are logged since the rows are already gone.
+func (s *server) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// pruneNodeAttachments is the non-transactional form used after single-graph saves.
+func (s *server) pruneNodeAttachments(ctx context.Context, userID, graphID string, nodes json.RawMessage) {
+	keys, err := pruneAttachments(ctx, s.pool, userID, graphID, nodes)
+	if err != nil {
+		log.Printf("failed to prune attachments: %v", err)
+		return
+	}
+	s.deleteBlobs(ctx, keys)
+}
+
+func nodeIDsOf(nodes json.RawMessage) ([]string, bool) {
+	var parsed []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(nodes, &parsed); err != nil {
+		return nil, false
+	}
+	ids := make([]string, 0, len(parsed))
+	for _, node := range parsed {
+		if node.ID != "" {
+			ids = append(ids, node.ID)
+		}
+	}
+	return ids, true
+}
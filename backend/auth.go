@@ -20,7 +20,18 @@ var errMissingAuth = errors.New("missing authorization")
 
 type supabaseClaims struct {
 	jwt.RegisteredClaims
-	Role string `json:"role"`
+	Role         string `json:"role"`
+	Email        string `json:"email"`
+	UserMetadata struct {
+		FullName string `json:"full_name"`
+		Name     string `json:"name"`
+	} `json:"user_metadata"`
+}
+
+// authUser is the verified caller; Name is a display name for authored content.
+type authUser struct {
+	ID   string
+	Name string
 }
 
 type supabaseJWKS struct {
@@ -36,33 +47,47 @@ type supabaseJWK struct {
 }
 
 func (s *server) requireUserID(r *http.Request) (string, error) {
+	user, err := s.requireUser(r)
+	return user.ID, err
+}
+
+// requireUser verifies the bearer token and returns the caller with a display
+// name taken from the Supabase profile claims (falling back to the email).
+func (s *server) requireUser(r *http.Request) (authUser, error) {
 	token, err := bearerToken(r)
 	if err != nil {
-		return "", err
+		return authUser{}, err
 	}
 
 	unverifiedClaims := &supabaseClaims{}
 	parser := jwt.NewParser()
 	unverifiedToken, _, err := parser.ParseUnverified(token, unverifiedClaims)
 	if err != nil {
-		return "", errors.New("invalid token")
+		return authUser{}, errors.New("invalid token")
 	}
 
 	alg, _ := unverifiedToken.Header["alg"].(string)
 	keyFunc, err := s.authKeyFunc(r.Context(), alg, unverifiedClaims.Issuer, unverifiedToken.Header)
 	if err != nil {
-		return "", errors.New("invalid token")
+		return authUser{}, errors.New("invalid token")
 	}
 
 	claims := &supabaseClaims{}
 	parsed, err := jwt.ParseWithClaims(token, claims, keyFunc)
 	if err != nil || !parsed.Valid {
-		return "", errors.New("invalid token")
+		return authUser{}, errors.New("invalid token")
 	}
 	if strings.TrimSpace(claims.Subject) == "" {
-		return "", errors.New("missing subject")
+		return authUser{}, errors.New("missing subject")
+	}
+	name := strings.TrimSpace(claims.UserMetadata.FullName)
+	if name == "" {
+		name = strings.TrimSpace(claims.UserMetadata.Name)
+	}
+	if name == "" {
+		name = strings.TrimSpace(claims.Email)
 	}
-	return claims.Subject, nil
+	return authUser{ID: claims.Subject, Name: name}, nil
 }
 
 func bearerToken(r *http.Request) (string, error) {
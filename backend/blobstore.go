@@ -0,0 +1,117 @@
+// Pluggable blob storage for node attachments (local filesystem or S3-compatible).
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	blobStoreLocal = "local"
+	blobStoreS3    = "s3"
+)
+
+var errBlobNotFound = errors.New("blob not found")
+
+// blobStore keeps attachment bytes outside Postgres. Keys are generated by the
+// server and contain only [a-z0-9/-].
+type blobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// newBlobStoreFromEnv builds the store selected by BLOB_STORE ("local" or "s3").
+func newBlobStoreFromEnv() (blobStore, error) {
+	switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("BLOB_STORE"))); kind {
+	case "", blobStoreLocal:
+		root := strings.TrimSpace(os.Getenv("BLOB_LOCAL_DIR"))
+		if root == "" {
+			root = "data/blobs"
+		}
+		return newLocalBlobStore(root)
+	case blobStoreS3:
+		return newS3BlobStore(
+			strings.TrimSpace(os.Getenv("S3_ENDPOINT")),
+			strings.TrimSpace(os.Getenv("S3_BUCKET")),
+			strings.TrimSpace(os.Getenv("S3_REGION")),
+			strings.TrimSpace(os.Getenv("S3_ACCESS_KEY_ID")),
+			strings.TrimSpace(os.Getenv("S3_SECRET_ACCESS_KEY")),
+		)
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", kind)
+	}
+}
+
+// localBlobStore writes blobs below root on the local filesystem.
+type localBlobStore struct {
+	root string
+}
+
+func newLocalBlobStore(root string) (*localBlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &localBlobStore{root: root}, nil
+}
+
+func (l *localBlobStore) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(l.root, cleaned), nil
+}
+
+func (l *localBlobStore) Put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temp file first so readers never see partial blobs.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *localBlobStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return file, err
+}
+
+func (l *localBlobStore) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,526 @@
+// Comment threads on graph nodes and items, stored outside the graph data blob.
+// Graphs are not shared yet, so only the owner can read and write comments;
+// the author is recorded separately so threads keep attribution once they are.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const maxCommentBodyRunes = 10000
+
+type commentAuthor struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// comment is a thread root (ParentID empty) or a reply. Resolution state lives
+// on the root; replies always point at the root.
+type comment struct {
+	ID         string        `json:"id"`
+	GraphID    string        `json:"graphId"`
+	NodeID     string        `json:"nodeId"`
+	ItemID     string        `json:"itemId,omitempty"`
+	ParentID   string        `json:"parentId,omitempty"`
+	Author     commentAuthor `json:"author"`
+	Body       string        `json:"body"`
+	Resolved   bool          `json:"resolved"`
+	ResolvedAt *time.Time    `json:"resolvedAt,omitempty"`
+	ResolvedBy string        `json:"resolvedBy,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	Replies    []comment     `json:"replies,omitempty"`
+}
+
+type createCommentRequest struct {
+	NodeID   string `json:"nodeId"`
+	ItemID   string `json:"itemId,omitempty"`
+	ParentID string `json:"parentId,omitempty"`
+	Body     string `json:"body"`
+}
+
+type updateCommentRequest struct {
+	Body     *string `json:"body,omitempty"`
+	Resolved *bool   `json:"resolved,omitempty"`
+}
+
+const commentColumns = `id, graph_id, node_id, item_id, coalesce(parent_id, ''), author_id, author_name,
+	body, resolved, resolved_at, coalesce(resolved_by, ''), created_at, updated_at`
+
+func scanComment(row pgx.Row) (comment, error) {
+	var c comment
+	err := row.Scan(
+		&c.ID,
+		&c.GraphID,
+		&c.NodeID,
+		&c.ItemID,
+		&c.ParentID,
+		&c.Author.ID,
+		&c.Author.Name,
+		&c.Body,
+		&c.Resolved,
+		&c.ResolvedAt,
+		&c.ResolvedBy,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	return c, err
+}
+
+// Routes /api/graphs/:id/comments[/:commentId].
+func (s *server) handleComments(w http.ResponseWriter, r *http.Request, graphID, commentID string) {
+	if commentID == "" {
+		switch r.Method {
+		case http.MethodGet:
+			s.handleListComments(w, r, graphID)
+		case http.MethodPost:
+			s.handleCreateComment(w, r, graphID)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if strings.Contains(commentID, "/") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		s.handleUpdateComment(w, r, graphID, commentID)
+	case http.MethodDelete:
+		s.handleDeleteComment(w, r, graphID, commentID)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/graphs/:id/comments: threads with replies (?nodeId=, ?itemId=, ?resolved=).
+func (s *server) handleListComments(w http.ResponseWriter, r *http.Request, graphID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	resolvedFilter := strings.TrimSpace(query.Get("resolved"))
+	if resolvedFilter != "" && resolvedFilter != "true" && resolvedFilter != "false" {
+		http.Error(w, "resolved must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	exists, _, err := graphHasNode(ctx, s.pool, userID, graphID, "")
+	if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to list comments", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	}
+
+	// Replies inherit node/item from their root, so filtering applies to both.
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT `+commentColumns+`
+		 FROM graph_comments
+		 WHERE user_id = $1 AND graph_id = $2
+		   AND ($3 = '' OR node_id = $3)
+		   AND ($4 = '' OR item_id = $4)
+		 ORDER BY created_at, id`,
+		userID,
+		graphID,
+		strings.TrimSpace(query.Get("nodeId")),
+		strings.TrimSpace(query.Get("itemId")),
+	)
+	if err != nil {
+		log.Printf("failed to list comments: %v", err)
+		http.Error(w, "failed to list comments", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var roots []comment
+	replies := make(map[string][]comment)
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			log.Printf("failed to scan comment: %v", err)
+			http.Error(w, "failed to list comments", http.StatusInternalServerError)
+			return
+		}
+		if c.ParentID == "" {
+			roots = append(roots, c)
+		} else {
+			replies[c.ParentID] = append(replies[c.ParentID], c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list comments: %v", err)
+		http.Error(w, "failed to list comments", http.StatusInternalServerError)
+		return
+	}
+
+	threads := []comment{}
+	for _, root := range roots {
+		if resolvedFilter != "" && root.Resolved != (resolvedFilter == "true") {
+			continue
+		}
+		root.Replies = replies[root.ID]
+		threads = append(threads, root)
+	}
+
+	writeJSON(w, threads)
+}
+
+// POST /api/graphs/:id/comments: start a thread on a node/item or reply to one.
+func (s *server) handleCreateComment(w http.ResponseWriter, r *http.Request, graphID string) {
+	user, err := s.requireUser(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var req createCommentRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	text, ok := normalizeCommentBody(w, req.Body)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	nodeID := strings.TrimSpace(req.NodeID)
+	itemID := strings.TrimSpace(req.ItemID)
+	var parentID *string
+	if parent := strings.TrimSpace(req.ParentID); parent != "" {
+		// Replies attach to the thread root and inherit its node and item.
+		var rootID string
+		err := s.pool.QueryRow(
+			ctx,
+			`SELECT coalesce(parent_id, id), node_id, item_id
+			 FROM graph_comments
+			 WHERE id = $1 AND user_id = $2 AND graph_id = $3`,
+			parent,
+			user.ID,
+			graphID,
+		).Scan(&rootID, &nodeID, &itemID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "parent comment not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("failed to read comment: %v", err)
+			http.Error(w, "failed to create comment", http.StatusInternalServerError)
+			return
+		}
+		parentID = &rootID
+	} else {
+		if nodeID == "" {
+			http.Error(w, "nodeId is required", http.StatusBadRequest)
+			return
+		}
+		exists, hasNode, err := graphHasNode(ctx, s.pool, user.ID, graphID, nodeID)
+		if err != nil {
+			log.Printf("failed to read graph: %v", err)
+			http.Error(w, "failed to create comment", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "graph not found", http.StatusNotFound)
+			return
+		}
+		if !hasNode {
+			http.Error(w, "node not found", http.StatusNotFound)
+			return
+		}
+		if itemID != "" {
+			hasItem, err := nodeHasItem(ctx, s.pool, user.ID, graphID, nodeID, itemID)
+			if err != nil {
+				log.Printf("failed to read graph: %v", err)
+				http.Error(w, "failed to create comment", http.StatusInternalServerError)
+				return
+			}
+			if !hasItem {
+				http.Error(w, "item not found", http.StatusNotFound)
+				return
+			}
+		}
+	}
+
+	id, err := generateID()
+	if err != nil {
+		http.Error(w, "failed to create comment", http.StatusInternalServerError)
+		return
+	}
+
+	created, err := scanComment(s.pool.QueryRow(
+		ctx,
+		`INSERT INTO graph_comments (id, user_id, graph_id, node_id, item_id, parent_id, author_id, author_name, body)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING `+commentColumns,
+		id,
+		user.ID,
+		graphID,
+		nodeID,
+		itemID,
+		parentID,
+		user.ID,
+		user.Name,
+		text,
+	))
+	if err != nil {
+		log.Printf("failed to create comment: %v", err)
+		http.Error(w, "failed to create comment", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, created)
+}
+
+// PATCH /api/graphs/:id/comments/:commentId: edit the body or
+// resolve/unresolve a thread root.
+func (s *server) handleUpdateComment(w http.ResponseWriter, r *http.Request, graphID, commentID string) {
+	user, err := s.requireUser(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var req updateCommentRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.Body == nil && req.Resolved == nil {
+		http.Error(w, "body or resolved is required", http.StatusBadRequest)
+		return
+	}
+	var text *string
+	if req.Body != nil {
+		normalized, ok := normalizeCommentBody(w, *req.Body)
+		if !ok {
+			return
+		}
+		text = &normalized
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	current, err := scanComment(s.pool.QueryRow(
+		ctx,
+		"SELECT "+commentColumns+" FROM graph_comments WHERE id = $1 AND user_id = $2 AND graph_id = $3",
+		commentID,
+		user.ID,
+		graphID,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read comment: %v", err)
+		http.Error(w, "failed to update comment", http.StatusInternalServerError)
+		return
+	}
+	if req.Resolved != nil && current.ParentID != "" {
+		http.Error(w, "only thread roots can be resolved", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := scanComment(s.pool.QueryRow(
+		ctx,
+		`UPDATE graph_comments
+		 SET body = coalesce($4, body),
+		     resolved = coalesce($5, resolved),
+		     resolved_at = CASE WHEN $5 IS NULL THEN resolved_at WHEN $5 THEN now() ELSE NULL END,
+		     resolved_by = CASE WHEN $5 IS NULL THEN resolved_by WHEN $5 THEN $6 ELSE NULL END,
+		     updated_at = CASE WHEN $4 IS NULL THEN updated_at ELSE now() END
+		 WHERE id = $1 AND user_id = $2 AND graph_id = $3
+		 RETURNING `+commentColumns,
+		commentID,
+		user.ID,
+		graphID,
+		text,
+		req.Resolved,
+		user.ID,
+	))
+	if err != nil {
+		log.Printf("failed to update comment: %v", err)
+		http.Error(w, "failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, updated)
+}
+
+// DELETE /api/graphs/:id/comments/:commentId: deleting a root removes its replies.
+func (s *server) handleDeleteComment(w http.ResponseWriter, r *http.Request, graphID, commentID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	cmd, err := s.pool.Exec(
+		ctx,
+		`DELETE FROM graph_comments
+		 WHERE user_id = $1 AND graph_id = $2 AND (id = $3 OR parent_id = $3)`,
+		userID,
+		graphID,
+		commentID,
+	)
+	if err != nil {
+		log.Printf("failed to delete comment: %v", err)
+		http.Error(w, "failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func normalizeCommentBody(w http.ResponseWriter, body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		http.Error(w, "comment body is required", http.StatusBadRequest)
+		return "", false
+	}
+	if len([]rune(body)) > maxCommentBodyRunes {
+		http.Error(w, "comment body is too long", http.StatusBadRequest)
+		return "", false
+	}
+	return body, true
+}
+
+// pruneComments removes threads on nodes no longer present in nodes, and
+// threads on items no longer present in their own node.
+func pruneComments(ctx context.Context, q querier, userID, graphID string, nodes json.RawMessage) error {
+	nodeIDs, ok := nodeIDsOf(nodes)
+	if !ok {
+		return nil
+	}
+	itemNodeIDs, itemIDs, ok := itemKeysOf(nodes)
+	if !ok {
+		return nil
+	}
+	_, err := q.Exec(
+		ctx,
+		`DELETE FROM graph_comments c
+		 WHERE c.user_id = $1 AND c.graph_id = $2
+		   AND (NOT (c.node_id = ANY($3))
+		        OR (c.item_id <> '' AND NOT EXISTS (
+		            SELECT 1 FROM unnest($4::text[], $5::text[]) AS k(node_id, item_id)
+		            WHERE k.node_id = c.node_id AND k.item_id = c.item_id)))`,
+		userID,
+		graphID,
+		nodeIDs,
+		itemNodeIDs,
+		itemIDs,
+	)
+	return err
+}
+
+type commentItemTree struct {
+	ID       string            `json:"id"`
+	Children []commentItemTree `json:"children"`
+}
+
+type commentNodeItems struct {
+	ID   string `json:"id"`
+	Data struct {
+		Items []commentItemTree `json:"items"`
+	} `json:"data"`
+}
+
+// walkItems calls fn with the id of every item, nested children included.
+func walkItems(items []commentItemTree, fn func(id string)) {
+	for _, item := range items {
+		if item.ID != "" {
+			fn(item.ID)
+		}
+		walkItems(item.Children, fn)
+	}
+}
+
+// itemKeysOf lists every item in nodes as parallel node id and item id
+// slices, nested children included.
+func itemKeysOf(nodes json.RawMessage) ([]string, []string, bool) {
+	var parsed []commentNodeItems
+	if err := json.Unmarshal(nodes, &parsed); err != nil {
+		return nil, nil, false
+	}
+	nodeIDs, itemIDs := []string{}, []string{}
+	for _, node := range parsed {
+		walkItems(node.Data.Items, func(id string) {
+			nodeIDs = append(nodeIDs, node.ID)
+			itemIDs = append(itemIDs, id)
+		})
+	}
+	return nodeIDs, itemIDs, true
+}
+
+// nodeHasItem reports whether itemID is an item (at any depth) of node
+// nodeID in the user's graph.
+func nodeHasItem(ctx context.Context, q querier, userID, graphID, nodeID, itemID string) (bool, error) {
+	var raw []byte
+	err := q.QueryRow(
+		ctx,
+		`SELECT node
+		 FROM graphs, jsonb_array_elements(data->'nodes') AS node
+		 WHERE id = $1 AND user_id = $2 AND node->>'id' = $3
+		 LIMIT 1`,
+		graphID,
+		userID,
+		nodeID,
+	).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var node commentNodeItems
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return false, nil
+	}
+	found := false
+	walkItems(node.Data.Items, func(id string) {
+		found = found || id == itemID
+	})
+	return found, nil
+}
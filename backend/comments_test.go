@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestItemKeysOfScopesItemsToTheirNode(t *testing.T) {
+	nodes := json.RawMessage(`[
+		{"id":"a","data":{"items":[{"id":"i1","children":[{"id":"i2"}]},{"title":"no id"}]}},
+		{"id":"b","data":{"items":[{"id":"i1"}]}},
+		{"id":"c","data":{}}
+	]`)
+	nodeIDs, itemIDs, ok := itemKeysOf(nodes)
+	if !ok {
+		t.Fatal("itemKeysOf rejected valid nodes")
+	}
+	if want := []string{"a", "a", "b"}; !reflect.DeepEqual(nodeIDs, want) {
+		t.Fatalf("node ids = %v, want %v", nodeIDs, want)
+	}
+	if want := []string{"i1", "i2", "i1"}; !reflect.DeepEqual(itemIDs, want) {
+		t.Fatalf("item ids = %v, want %v", itemIDs, want)
+	}
+	if _, _, ok := itemKeysOf(json.RawMessage(`{}`)); ok {
+		t.Fatal("itemKeysOf accepted an object")
+	}
+}
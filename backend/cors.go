@@ -39,8 +39,8 @@ func (s *server) withCORS(next http.Handler) http.Handler {
 		if allowed := matchOrigin(origin, s.corsOrigins); allowed != "" {
 			w.Header().Set("Access-Control-Allow-Origin", allowed)
 		}
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, Authorization")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
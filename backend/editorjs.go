@@ -0,0 +1,336 @@
+// Conversion of Editor.js documents (nodeNotes/itemNotes) to Markdown.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+type editorJSDocument struct {
+	Blocks []struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	} `json:"blocks"`
+}
+
+var editorJSInlineRules = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`), "[$2]($1)"},
+	{regexp.MustCompile(`(?i)</?(b|strong)>`), "**"},
+	{regexp.MustCompile(`(?i)</?(i|em)>`), "_"},
+	{regexp.MustCompile(`(?i)</?(s|del|strike)>`), "~~"},
+	{regexp.MustCompile(`(?i)</?mark[^>]*>`), "=="},
+	{regexp.MustCompile(`(?i)</?code[^>]*>`), "`"},
+	{regexp.MustCompile(`(?i)<br\s*/?>`), "  \n"},
+}
+
+// editorJSInlineMarkdown converts Editor.js inline HTML to Markdown.
+func editorJSInlineMarkdown(text string) string {
+	for _, rule := range editorJSInlineRules {
+		text = rule.pattern.ReplaceAllString(text, rule.replacement)
+	}
+	return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(text, "")))
+}
+
+// editorJSToMarkdown renders a stored Editor.js document as Markdown. Plain
+// text (notes saved before Editor.js was used) is returned unchanged.
+func editorJSToMarkdown(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	var doc editorJSDocument
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil || doc.Blocks == nil {
+		return raw
+	}
+
+	var blocks []string
+	for _, block := range doc.Blocks {
+		if rendered := editorJSBlockMarkdown(block.Type, block.Data); rendered != "" {
+			blocks = append(blocks, rendered)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func editorJSBlockMarkdown(blockType string, raw json.RawMessage) string {
+	var data struct {
+		Text     string               `json:"text"`
+		Level    int                  `json:"level"`
+		Style    string               `json:"style"`
+		Items    json.RawMessage      `json:"items"`
+		Caption  string               `json:"caption"`
+		Code     string               `json:"code"`
+		HTML     string               `json:"html"`
+		Title    string               `json:"title"`
+		Message  string               `json:"message"`
+		URL      string               `json:"url"`
+		File     struct{ URL string } `json:"file"`
+		Content  [][]string           `json:"content"`
+		Headings bool                 `json:"withHeadings"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ""
+	}
+
+	switch blockType {
+	case "header":
+		level := min(max(data.Level, 1), 6)
+		return strings.Repeat("#", level) + " " + editorJSInlineMarkdown(data.Text)
+	case "list":
+		return strings.Join(editorJSListMarkdown(data.Items, data.Style, ""), "\n")
+	case "checklist":
+		return strings.Join(editorJSListMarkdown(data.Items, "checklist", ""), "\n")
+	case "quote":
+		lines := strings.Split(editorJSInlineMarkdown(data.Text), "\n")
+		if caption := editorJSInlineMarkdown(data.Caption); caption != "" {
+			lines = append(lines, "— "+caption)
+		}
+		return "> " + strings.Join(lines, "\n> ")
+	case "code":
+		return "```\n" + strings.TrimRight(data.Code, "\n") + "\n```"
+	case "delimiter":
+		return "***"
+	case "raw":
+		return strings.TrimSpace(data.HTML)
+	case "warning":
+		return fmt.Sprintf("> **%s** %s", editorJSInlineMarkdown(data.Title), editorJSInlineMarkdown(data.Message))
+	case "image":
+		url := data.File.URL
+		if url == "" {
+			url = data.URL
+		}
+		return fmt.Sprintf("![%s](%s)", editorJSInlineMarkdown(data.Caption), url)
+	case "table":
+		return editorJSTableMarkdown(data.Content, data.Headings)
+	}
+	return editorJSInlineMarkdown(data.Text)
+}
+
+// editorJSListMarkdown supports both list formats: plain strings (list 1.x)
+// and {content, meta, items} objects (list 2.x).
+func editorJSListMarkdown(raw json.RawMessage, style string, indent string) []string {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+	var lines []string
+	for i, item := range items {
+		var entry struct {
+			Content string                 `json:"content"`
+			Text    string                 `json:"text"`
+			Checked bool                   `json:"checked"`
+			Meta    struct{ Checked bool } `json:"meta"`
+			Items   json.RawMessage        `json:"items"`
+		}
+		if err := json.Unmarshal(item, &entry.Content); err != nil {
+			if err := json.Unmarshal(item, &entry); err != nil {
+				continue
+			}
+		}
+		text := editorJSInlineMarkdown(firstNonEmpty(entry.Content, entry.Text))
+		marker := "- "
+		switch style {
+		case "ordered":
+			marker = fmt.Sprintf("%d. ", i+1)
+		case "checklist":
+			marker = "- [ ] "
+			if entry.Checked || entry.Meta.Checked {
+				marker = "- [x] "
+			}
+		}
+		// Nested lines align with the item text.
+		nested := indent + strings.Repeat(" ", len(marker))
+		if style == "checklist" {
+			nested = indent + "  "
+		}
+		lines = append(lines, indent+marker+strings.ReplaceAll(text, "\n", "\n"+nested))
+		if len(entry.Items) > 0 {
+			lines = append(lines, editorJSListMarkdown(entry.Items, style, nested)...)
+		}
+	}
+	return lines
+}
+
+func editorJSTableMarkdown(rows [][]string, withHeadings bool) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	format := func(row []string) string {
+		cells := make([]string, columns)
+		for i := range cells {
+			if i < len(row) {
+				cells[i] = strings.ReplaceAll(editorJSInlineMarkdown(row[i]), "|", `\|`)
+			}
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	var lines []string
+	if withHeadings {
+		lines = append(lines, format(rows[0]))
+		rows = rows[1:]
+	} else {
+		lines = append(lines, format(nil))
+	}
+	lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+type editorJSBlock struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type editorJSListItem struct {
+	Content string             `json:"content"`
+	Meta    map[string]any     `json:"meta"`
+	Items   []editorJSListItem `json:"items"`
+}
+
+var (
+	markdownHeadingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	markdownListItemPattern = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(\[[ xX]\]\s+)?(.*)$`)
+	markdownRulePattern     = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	markdownInlineRules     = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile("`([^`]+)`"), `<code class="inline-code">$1</code>`},
+		{regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`), "<b>$1$2</b>"},
+		{regexp.MustCompile(`(^|[^\w*])\*([^*\s][^*]*?)\*`), "$1<i>$2</i>"},
+		{regexp.MustCompile(`(^|[^\w_])_([^_\s][^_]*?)_`), "$1<i>$2</i>"},
+		{regexp.MustCompile(`~~(.+?)~~`), "<s>$1</s>"},
+		{regexp.MustCompile(`==(.+?)==`), `<mark class="cdx-marker">$1</mark>`},
+		{regexp.MustCompile(`(^|[^!])\[([^\]]+)\]\(([^)\s]+)\)`), `$1<a href="$3">$2</a>`},
+	}
+)
+
+// markdownInlineHTML converts inline Markdown to the HTML Editor.js stores.
+// [[wikilinks]] are kept as text so they are still indexed.
+func markdownInlineHTML(text string) string {
+	text = html.EscapeString(strings.TrimSpace(text))
+	for _, rule := range markdownInlineRules {
+		text = rule.pattern.ReplaceAllString(text, rule.replacement)
+	}
+	return text
+}
+
+// markdownToEditorJS converts Markdown to an Editor.js document using the
+// blocks the editor is configured with (paragraph, header, list). Code,
+// quotes and tables become paragraphs. It returns "" for empty input.
+func markdownToEditorJS(markdown string) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	var blocks []editorJSBlock
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, editorJSBlock{Type: "paragraph", Data: map[string]any{"text": strings.Join(paragraph, "<br>")}})
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := trimmed[:3]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, html.EscapeString(lines[i]))
+			}
+			if len(code) > 0 {
+				blocks = append(blocks, editorJSBlock{Type: "paragraph", Data: map[string]any{
+					"text": `<code class="inline-code">` + strings.Join(code, "<br>") + "</code>",
+				}})
+			}
+		case markdownHeadingPattern.MatchString(trimmed):
+			flush()
+			match := markdownHeadingPattern.FindStringSubmatch(trimmed)
+			blocks = append(blocks, editorJSBlock{Type: "header", Data: map[string]any{
+				"text":  markdownInlineHTML(match[2]),
+				"level": len(match[1]),
+			}})
+		case markdownRulePattern.MatchString(line):
+			flush()
+		case markdownListItemPattern.MatchString(line):
+			flush()
+			end := i
+			for end < len(lines) && (markdownListItemPattern.MatchString(lines[end]) ||
+				(strings.TrimSpace(lines[end]) != "" && strings.HasPrefix(lines[end], " "))) {
+				end++
+			}
+			blocks = append(blocks, markdownListBlock(lines[i:end]))
+			i = end - 1
+		default:
+			text := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			paragraph = append(paragraph, markdownInlineHTML(text))
+		}
+	}
+	flush()
+	if len(blocks) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(map[string]any{"blocks": blocks})
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// markdownListBlock builds a nested Editor.js list (list 2.x format).
+func markdownListBlock(lines []string) editorJSBlock {
+	style := "unordered"
+	if match := markdownListItemPattern.FindStringSubmatch(lines[0]); match != nil {
+		if match[3] != "" {
+			style = "checklist"
+		} else if match[2][0] >= '0' && match[2][0] <= '9' {
+			style = "ordered"
+		}
+	}
+
+	type level struct {
+		indent int
+		items  *[]editorJSListItem
+	}
+	var root []editorJSListItem
+	stack := []level{{indent: -1, items: &root}}
+	var last *editorJSListItem
+	for _, line := range lines {
+		match := markdownListItemPattern.FindStringSubmatch(line)
+		if match == nil {
+			if last != nil {
+				last.Content += "<br>" + markdownInlineHTML(line)
+			}
+			continue
+		}
+		indent := len(strings.ReplaceAll(match[1], "\t", "    "))
+		for len(stack) > 1 && indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		item := editorJSListItem{Content: markdownInlineHTML(match[4]), Meta: map[string]any{}, Items: []editorJSListItem{}}
+		if style == "checklist" {
+			item.Meta["checked"] = strings.ContainsAny(match[3], "xX")
+		}
+		parent := stack[len(stack)-1].items
+		*parent = append(*parent, item)
+		last = &(*parent)[len(*parent)-1]
+		stack = append(stack, level{indent: indent, items: &last.Items})
+	}
+	return editorJSBlock{Type: "list", Data: map[string]any{"style": style, "meta": map[string]any{}, "items": root}}
+}
@@ -0,0 +1,206 @@
+// JSON Canvas 1.0 (.canvas) import/export. Canvas groups contain nodes by
+// geometry rather than by reference, so parents are derived from bounds.
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type canvasDocument struct {
+	Nodes []canvasNode `json:"nodes"`
+	Edges []canvasEdge `json:"edges"`
+}
+
+type canvasNode struct {
+	ID     string  `json:"id"`
+	Type   string  `json:"type"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Color  string  `json:"color,omitempty"`
+	Text   string  `json:"text,omitempty"`
+	File   string  `json:"file,omitempty"`
+	URL    string  `json:"url,omitempty"`
+	Label  string  `json:"label,omitempty"`
+}
+
+type canvasEdge struct {
+	ID       string `json:"id"`
+	FromNode string `json:"fromNode"`
+	FromSide string `json:"fromSide,omitempty"`
+	FromEnd  string `json:"fromEnd,omitempty"`
+	ToNode   string `json:"toNode"`
+	ToSide   string `json:"toSide,omitempty"`
+	ToEnd    string `json:"toEnd,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// Canvas sides map to the handles rendered by the frontend nodes, which only
+// have sources on the right/bottom and targets on the left/top.
+var (
+	canvasSourceHandles = map[string]string{"right": "right-out", "bottom": "bottom-out"}
+	canvasTargetHandles = map[string]string{"left": "left-in", "top": "top-in"}
+)
+
+func exportCanvas(g ixGraph) ([]byte, error) {
+	hasChildren := map[string]bool{}
+	for _, node := range g.Nodes {
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+	positions := g.absolutePositions()
+
+	doc := canvasDocument{Nodes: []canvasNode{}, Edges: []canvasEdge{}}
+	// Groups are listed first so they are drawn beneath their children.
+	for _, groups := range []bool{true, false} {
+		for _, node := range g.Nodes {
+			group := node.Group || hasChildren[node.ID]
+			if group != groups {
+				continue
+			}
+			position := positions[node.ID]
+			converted := canvasNode{ID: node.ID, X: position.X, Y: position.Y, Width: defaultNodeWidth, Height: defaultNodeHeight}
+			if group {
+				converted.Type, converted.Label = "group", node.Label
+				converted.Width, converted.Height = node.Width, node.Height
+			} else {
+				var b strings.Builder
+				writeMarkdownNoteBody(&b, node)
+				converted.Type, converted.Text = "text", strings.TrimSpace(b.String())
+			}
+			doc.Nodes = append(doc.Nodes, converted)
+		}
+	}
+
+	for _, edge := range g.Edges {
+		converted := canvasEdge{
+			ID:       firstNonEmpty(edge.ID, newID("edge")),
+			FromNode: edge.Source,
+			ToNode:   edge.Target,
+			Label:    edge.Label,
+		}
+		for side, handle := range canvasSourceHandles {
+			if handle == edge.SourceHandle {
+				converted.FromSide = side
+			}
+		}
+		for side, handle := range canvasTargetHandles {
+			if handle == edge.TargetHandle {
+				converted.ToSide = side
+			}
+		}
+		if !edge.Directed {
+			converted.ToEnd = "none"
+		}
+		doc.Edges = append(doc.Edges, converted)
+	}
+	return json.MarshalIndent(doc, "", "\t")
+}
+
+func importCanvas(data []byte, _ url.Values) (ixGraph, error) {
+	var doc canvasDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return ixGraph{}, importErrorf("invalid JSON Canvas: %v", err)
+	}
+
+	g := ixGraph{}
+	for _, node := range doc.Nodes {
+		converted := ixNode{ID: node.ID, X: node.X, Y: node.Y, HasPosition: true}
+		switch node.Type {
+		case "group":
+			converted.Group, converted.Label = true, node.Label
+			converted.Width, converted.Height = node.Width, node.Height
+		case "text":
+			note := parseVaultNote(node.Text)
+			converted.Label = note.title
+			converted.Items = note.node.Items
+			converted.NodeNotes = note.node.NodeNotes
+			if converted.Label == "" {
+				converted.Label = canvasTextLabel(node.Text)
+				if strings.TrimSpace(node.Text) == converted.Label {
+					converted.NodeNotes = ""
+				}
+			}
+		case "file":
+			converted.Label = strings.TrimSuffix(path.Base(node.File), path.Ext(node.File))
+			converted.NodeNotes = markdownToEditorJS(node.File)
+		case "link":
+			converted.Label = node.URL
+			converted.NodeNotes = markdownToEditorJS("[" + node.URL + "](" + node.URL + ")")
+		default:
+			converted.Label = firstNonEmpty(node.Label, node.Text, node.ID)
+		}
+		g.Nodes = append(g.Nodes, converted)
+	}
+	assignCanvasParents(g.Nodes, doc.Nodes)
+
+	for _, edge := range doc.Edges {
+		converted := ixEdge{
+			ID:           edge.ID,
+			Source:       edge.FromNode,
+			Target:       edge.ToNode,
+			Label:        edge.Label,
+			Directed:     edge.ToEnd != "none" || edge.FromEnd == "arrow",
+			SourceHandle: canvasSourceHandles[edge.FromSide],
+			TargetHandle: canvasTargetHandles[edge.ToSide],
+		}
+		// An arrow only at the start points from the target to the source.
+		if edge.ToEnd == "none" && edge.FromEnd == "arrow" {
+			converted.Source, converted.Target = edge.ToNode, edge.FromNode
+			converted.SourceHandle = canvasSourceHandles[edge.ToSide]
+			converted.TargetHandle = canvasTargetHandles[edge.FromSide]
+		}
+		g.Edges = append(g.Edges, converted)
+	}
+
+	g.relativizePositions()
+	return g, nil
+}
+
+// canvasTextLabel uses the first line of a text node, without Markdown
+// markers, as the label.
+func canvasTextLabel(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#>-*+ "))
+		if line != "" {
+			if runes := []rune(line); len(runes) > 80 {
+				line = string(runes[:80])
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// assignCanvasParents puts each node in the smallest group whose bounds
+// contain it.
+func assignCanvasParents(nodes []ixNode, source []canvasNode) {
+	contains := func(outer, inner canvasNode) bool {
+		return inner.X >= outer.X && inner.Y >= outer.Y &&
+			inner.X+inner.Width <= outer.X+outer.Width &&
+			inner.Y+inner.Height <= outer.Y+outer.Height
+	}
+	for i, node := range source {
+		best := -1
+		for j, group := range source {
+			if i == j || group.Type != "group" || !contains(group, node) {
+				continue
+			}
+			// Identical bounds would make two groups contain each other.
+			if node.Type == "group" && group.Width*group.Height <= node.Width*node.Height {
+				continue
+			}
+			if best < 0 || group.Width*group.Height < source[best].Width*source[best].Height {
+				best = j
+			}
+		}
+		if best >= 0 {
+			nodes[i].ParentID = source[best].ID
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestCanvasRoundTrip(t *testing.T) {
+	testExportImportRoundTrip(t, "canvas", roundTripKeeps{edges: true, items: true, notes: true, positions: true})
+}
+
+func TestImportCanvasRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "canvas", []importCase{
+		{name: "empty"},
+		{name: "not JSON", data: "\x00\x01not a graph\xff"},
+		{name: "array", data: `[]`},
+		{name: "nodes not an array", data: `{"nodes":"x"}`},
+	})
+}
+
+func TestImportCanvasRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "canvas", []importCase{
+		{name: "edge to a missing node", data: `{"nodes":[{"id":"a","type":"text","text":"A","x":0,"y":0,"width":10,"height":10}],"edges":[{"id":"e","fromNode":"a","toNode":"zz"}]}`},
+		{name: "node without id", data: `{"nodes":[{"type":"text","text":"A"},{"type":"text","text":"B"}]}`},
+	})
+}
@@ -0,0 +1,286 @@
+// CSV import: an edge list (one row per edge) or an adjacency matrix.
+// Column mapping comes from the query string; nodes are laid out with
+// layoutLayered since CSV carries no positions.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// importCSV reads ?mode=edges|matrix (default edges), ?header=false,
+// ?delimiter=, ?layout=TB|BT|LR|RL and, for edge lists, the column names
+// (or 1-based numbers) ?source=, ?target=, ?label=, ?weight=, ?direction=
+// plus ?directed=false to make edges undirected by default.
+func importCSV(data []byte, opts url.Values) (ixGraph, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = csvDelimiter(opts.Get("delimiter"), data)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ixGraph{}, importErrorf("invalid CSV: %v", err)
+		}
+		blank := true
+		for _, cell := range record {
+			if strings.TrimSpace(cell) != "" {
+				blank = false
+			}
+		}
+		if !blank {
+			rows = append(rows, record)
+		}
+	}
+	if len(rows) == 0 {
+		return ixGraph{}, importErrorf("CSV file is empty")
+	}
+
+	header := opts.Get("header") != "false"
+	var g ixGraph
+	var err error
+	switch mode := strings.ToLower(strings.TrimSpace(opts.Get("mode"))); mode {
+	case "", "edges", "edgelist":
+		g, err = csvEdgeList(rows, header, opts)
+	case "matrix", "adjacency":
+		g, err = csvMatrix(rows, header, opts)
+	default:
+		return ixGraph{}, importErrorf("unknown CSV mode %q (use edges or matrix)", mode)
+	}
+	if err != nil {
+		return ixGraph{}, err
+	}
+
+	g.Name = "Imported CSV"
+	layout := strings.ToUpper(strings.TrimSpace(opts.Get("layout")))
+	switch layout {
+	case "TB", "BT", "LR", "RL":
+	default:
+		layout = "TB"
+	}
+	g.layoutLayered(layout)
+	return g, nil
+}
+
+// csvDelimiter uses ?delimiter= ("tab" for tabs) or picks the most common
+// of comma, semicolon and tab in the first line.
+func csvDelimiter(option string, data []byte) rune {
+	switch option {
+	case "tab", `\t`:
+		return '\t'
+	case "":
+	default:
+		if r := []rune(option); len(r) == 1 {
+			return r[0]
+		}
+	}
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', bytes.Count(first, []byte(","))
+	for _, candidate := range []rune{';', '\t'} {
+		if n := bytes.Count(first, []byte(string(candidate))); n > count {
+			best, count = candidate, n
+		}
+	}
+	return best
+}
+
+// csvNodes creates one node per distinct name, in order of appearance.
+type csvNodes struct {
+	graph *ixGraph
+	ids   map[string]string
+}
+
+func (n *csvNodes) id(name string) string {
+	name = strings.TrimSpace(name)
+	if id, ok := n.ids[name]; ok {
+		return id
+	}
+	id := newID("node")
+	n.ids[name] = id
+	n.graph.Nodes = append(n.graph.Nodes, ixNode{ID: id, Label: name})
+	return id
+}
+
+func csvEdgeList(rows [][]string, header bool, opts url.Values) (ixGraph, error) {
+	var names []string
+	if header {
+		names, rows = rows[0], rows[1:]
+	}
+	column := func(param string, defaults ...string) (int, error) {
+		wanted := strings.TrimSpace(opts.Get(param))
+		candidates := defaults
+		if wanted != "" {
+			candidates = []string{wanted}
+		}
+		for _, candidate := range candidates {
+			for i, name := range names {
+				if strings.EqualFold(strings.TrimSpace(name), candidate) {
+					return i, nil
+				}
+			}
+			if number, err := strconv.Atoi(candidate); err == nil && number >= 1 {
+				return number - 1, nil
+			}
+		}
+		if wanted != "" {
+			return -1, importErrorf("column %q for %s not found", wanted, param)
+		}
+		return -1, nil
+	}
+
+	source, err := column("source", "source", "from", "1")
+	if err != nil {
+		return ixGraph{}, err
+	}
+	target, err := column("target", "target", "to", "2")
+	if err != nil {
+		return ixGraph{}, err
+	}
+	label, err := column("label", "label")
+	if err != nil {
+		return ixGraph{}, err
+	}
+	weight, err := column("weight", "weight")
+	if err != nil {
+		return ixGraph{}, err
+	}
+	direction, err := column("direction", "direction", "directed", "dir")
+	if err != nil {
+		return ixGraph{}, err
+	}
+	defaultDirected := opts.Get("directed") != "false"
+
+	g := ixGraph{}
+	nodes := csvNodes{graph: &g, ids: map[string]string{}}
+	cell := func(row []string, index int) string {
+		if index < 0 || index >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[index])
+	}
+	for number, row := range rows {
+		from, to := cell(row, source), cell(row, target)
+		if from == "" {
+			continue
+		}
+		if to == "" {
+			// Rows with only a source still create the node.
+			nodes.id(from)
+			continue
+		}
+		edge := ixEdge{Source: nodes.id(from), Target: nodes.id(to), Directed: defaultDirected}
+		edge.Label = firstNonEmpty(cell(row, label), cell(row, weight))
+		switch value := strings.ToLower(cell(row, direction)); value {
+		case "":
+		case "directed", "->", "true", "yes", "1", "forward":
+			edge.Directed = true
+		case "undirected", "--", "-", "false", "no", "0", "none", "both":
+			edge.Directed = false
+		case "<-", "reverse", "backward":
+			edge.Directed = true
+			edge.Source, edge.Target = edge.Target, edge.Source
+		default:
+			line := number + 1
+			if header {
+				line++
+			}
+			return ixGraph{}, importErrorf("row %d: unknown direction %q", line, value)
+		}
+		g.Edges = append(g.Edges, edge)
+	}
+	if len(g.Nodes) == 0 {
+		return ixGraph{}, importErrorf("no source column values found in CSV")
+	}
+	return g, nil
+}
+
+// csvMatrix reads a square adjacency matrix whose first row and column hold
+// node names (numbers are used without a header). A symmetric matrix gives
+// undirected edges unless ?directed=true; values other than 1 become labels.
+func csvMatrix(rows [][]string, header bool, opts url.Values) (ixGraph, error) {
+	var names []string
+	if header {
+		for _, name := range rows[0][1:] {
+			names = append(names, strings.TrimSpace(name))
+		}
+		rows = rows[1:]
+	}
+
+	labels := make([]string, len(rows))
+	values := make([][]string, len(rows))
+	for i, row := range rows {
+		if header {
+			labels[i] = strings.TrimSpace(row[0])
+			row = row[1:]
+		} else {
+			labels[i] = strconv.Itoa(i + 1)
+		}
+		values[i] = row
+	}
+	if !header {
+		names = labels
+	}
+	if len(names) != len(labels) {
+		return ixGraph{}, importErrorf("adjacency matrix must be square (%d columns, %d rows)", len(names), len(labels))
+	}
+	for i := range labels {
+		if labels[i] == "" {
+			labels[i] = names[i]
+		}
+	}
+
+	present := func(value string) bool {
+		value = strings.ToLower(strings.TrimSpace(value))
+		return value != "" && value != "0" && value != "false" && value != "no"
+	}
+	cell := func(i, j int) string {
+		if j < len(values[i]) {
+			return strings.TrimSpace(values[i][j])
+		}
+		return ""
+	}
+	symmetric := true
+	for i := range labels {
+		for j := range labels {
+			if present(cell(i, j)) != present(cell(j, i)) || (present(cell(i, j)) && cell(i, j) != cell(j, i)) {
+				symmetric = false
+			}
+		}
+	}
+	directed := !symmetric
+	if value := opts.Get("directed"); value != "" {
+		directed = value != "false"
+	}
+
+	g := ixGraph{}
+	nodes := csvNodes{graph: &g, ids: map[string]string{}}
+	for _, label := range labels {
+		nodes.id(label)
+	}
+	for i := range labels {
+		for j := range labels {
+			value := cell(i, j)
+			if !present(value) || (!directed && j < i && present(cell(j, i))) {
+				continue
+			}
+			edge := ixEdge{Source: nodes.id(labels[i]), Target: nodes.id(labels[j]), Directed: directed}
+			if !strings.EqualFold(value, "1") && !strings.EqualFold(value, "true") && !strings.EqualFold(value, "yes") && !strings.EqualFold(value, "x") {
+				edge.Label = value
+			}
+			g.Edges = append(g.Edges, edge)
+		}
+	}
+	return g, nil
+}
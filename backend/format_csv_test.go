@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestImportCSVRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "csv", []importCase{
+		{name: "empty"},
+		{name: "header only", data: "source,target\n"},
+	})
+}
+
+func TestImportCSVRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "csv", []importCase{
+		{name: "ragged rows", data: "source,target\na\nb,c,d\n"},
+		{name: "unterminated quote", data: "source,target\na,\"b\n"},
+	})
+}
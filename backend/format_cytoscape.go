@@ -0,0 +1,278 @@
+// Cytoscape.js elements JSON import/export. Groups are compound parent nodes
+// (data.parent); positions are absolute node centers, as in cy.json().
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type cytoscapeDocument struct {
+	Data     map[string]any    `json:"data,omitempty"`
+	Elements cytoscapeElements `json:"elements"`
+}
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+type cytoscapeElement struct {
+	Group    string          `json:"group,omitempty"`
+	Data     map[string]any  `json:"data"`
+	Position *cytoscapePoint `json:"position,omitempty"`
+}
+
+type cytoscapePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func exportCytoscape(g ixGraph) ([]byte, error) {
+	hasChildren := map[string]bool{}
+	for _, node := range g.Nodes {
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+	positions := g.absolutePositions()
+
+	doc := cytoscapeDocument{
+		Data:     map[string]any{"name": g.Name},
+		Elements: cytoscapeElements{Nodes: []cytoscapeElement{}, Edges: []cytoscapeElement{}},
+	}
+	if g.Kind != "" {
+		doc.Data["kind"] = g.Kind
+	}
+
+	for _, node := range g.Nodes {
+		width, height := defaultNodeWidth, defaultNodeHeight
+		data := map[string]any{"id": node.ID, "label": node.Label}
+		if node.ParentID != "" {
+			data["parent"] = node.ParentID
+		}
+		if node.Group || hasChildren[node.ID] {
+			width, height = node.Width, node.Height
+			data["group"] = true
+			data["width"], data["height"] = width, height
+		}
+		if len(node.Items) > 0 {
+			data["items"] = node.Items
+		}
+		if node.NodeNotes != "" {
+			data["nodeNotes"] = node.NodeNotes
+		}
+		position := positions[node.ID]
+		doc.Elements.Nodes = append(doc.Elements.Nodes, cytoscapeElement{
+			Data:     data,
+			Position: &cytoscapePoint{X: position.X + width/2, Y: position.Y + height/2},
+		})
+	}
+	for _, edge := range g.Edges {
+		data := map[string]any{
+			"id":       firstNonEmpty(edge.ID, newID("edge")),
+			"source":   edge.Source,
+			"target":   edge.Target,
+			"directed": edge.Directed,
+		}
+		if edge.Label != "" {
+			data["label"] = edge.Label
+		}
+		if edge.SourceHandle != "" {
+			data["sourceHandle"] = edge.SourceHandle
+		}
+		if edge.TargetHandle != "" {
+			data["targetHandle"] = edge.TargetHandle
+		}
+		doc.Elements.Edges = append(doc.Elements.Edges, cytoscapeElement{Data: data})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// importCytoscape accepts cy.json() output ({elements: {nodes, edges}}), a
+// flat elements array (with "group" or edge source/target), or that array
+// under "elements". Edges are directed unless data.directed is false.
+func importCytoscape(data []byte, _ url.Values) (ixGraph, error) {
+	var doc cytoscapeDocument
+	var elements []cytoscapeElement
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return ixGraph{}, importErrorf("invalid Cytoscape JSON: %v", err)
+		}
+	default:
+		var raw struct {
+			Data     map[string]any  `json:"data"`
+			Elements json.RawMessage `json:"elements"`
+			Nodes    json.RawMessage `json:"nodes"`
+			Edges    json.RawMessage `json:"edges"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return ixGraph{}, importErrorf("invalid Cytoscape JSON: %v", err)
+		}
+		doc.Data = raw.Data
+		groups := raw.Elements
+		if len(groups) == 0 {
+			groups, _ = json.Marshal(map[string]json.RawMessage{"nodes": raw.Nodes, "edges": raw.Edges})
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(groups)), "[") {
+			if err := json.Unmarshal(groups, &elements); err != nil {
+				return ixGraph{}, importErrorf("invalid Cytoscape elements: %v", err)
+			}
+		} else if err := json.Unmarshal(groups, &doc.Elements); err != nil {
+			return ixGraph{}, importErrorf("invalid Cytoscape elements: %v", err)
+		}
+	}
+	for _, element := range elements {
+		_, hasSource := element.Data["source"]
+		if element.Group == "edges" || (element.Group == "" && hasSource) {
+			doc.Elements.Edges = append(doc.Elements.Edges, element)
+		} else {
+			doc.Elements.Nodes = append(doc.Elements.Nodes, element)
+		}
+	}
+	if len(doc.Elements.Nodes) == 0 {
+		return ixGraph{}, importErrorf("Cytoscape JSON has no nodes")
+	}
+
+	g := ixGraph{}
+	if name, ok := doc.Data["name"].(string); ok {
+		g.Name = strings.TrimSpace(name)
+	}
+	if kind, ok := doc.Data["kind"].(string); ok {
+		g.Kind = strings.TrimSpace(kind)
+	}
+
+	parents := map[string]bool{}
+	for _, element := range doc.Elements.Nodes {
+		if parent := cytoscapeString(element.Data["parent"]); parent != "" {
+			parents[parent] = true
+		}
+	}
+	positioned := true
+	seen := make(map[string]struct{}, len(doc.Elements.Nodes))
+	for _, element := range doc.Elements.Nodes {
+		id := cytoscapeString(element.Data["id"])
+		// Edges and children referencing a repeated id keep the first node.
+		if _, dup := seen[id]; id == "" || dup {
+			id = newID("node")
+		}
+		seen[id] = struct{}{}
+		node := ixNode{
+			ID:       id,
+			Label:    firstNonEmpty(cytoscapeString(element.Data["label"]), cytoscapeString(element.Data["name"]), id),
+			ParentID: cytoscapeString(element.Data["parent"]),
+			Group:    parents[id] || element.Data["group"] == true,
+		}
+		node.NodeNotes, _ = element.Data["nodeNotes"].(string)
+		if raw, err := json.Marshal(element.Data["items"]); err == nil {
+			_ = json.Unmarshal(raw, &node.Items)
+		}
+		width, height := defaultNodeWidth, defaultNodeHeight
+		if node.Group {
+			// Compound nodes are sized from their children below unless the
+			// file carries a size.
+			width, height = numberOr(element.Data["width"], 0), numberOr(element.Data["height"], 0)
+			node.Width, node.Height = width, height
+		}
+		if element.Position != nil {
+			node.X, node.Y, node.HasPosition = element.Position.X-width/2, element.Position.Y-height/2, true
+		} else if !node.Group {
+			positioned = false
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+	for _, element := range doc.Elements.Edges {
+		edge := ixEdge{
+			ID:           cytoscapeString(element.Data["id"]),
+			Source:       cytoscapeString(element.Data["source"]),
+			Target:       cytoscapeString(element.Data["target"]),
+			Label:        firstNonEmpty(cytoscapeString(element.Data["label"]), cytoscapeString(element.Data["name"]), cytoscapeString(element.Data["interaction"])),
+			Directed:     element.Data["directed"] != false,
+			SourceHandle: cytoscapeString(element.Data["sourceHandle"]),
+			TargetHandle: cytoscapeString(element.Data["targetHandle"]),
+		}
+		g.Edges = append(g.Edges, edge)
+	}
+
+	if !positioned {
+		g.layoutLayered("TB")
+		return g, nil
+	}
+	fitCytoscapeGroups(g.Nodes)
+	g.relativizePositions()
+	return g, nil
+}
+
+// fitCytoscapeGroups gives compound nodes without a stored size the bounds
+// of their children plus padding, innermost groups first.
+func fitCytoscapeGroups(nodes []ixNode) {
+	const padding = 24.0
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+	depth := func(i int) int {
+		d := 0
+		for parent := nodes[i].ParentID; parent != "" && d < len(nodes); d++ {
+			j, ok := index[parent]
+			if !ok {
+				break
+			}
+			parent = nodes[j].ParentID
+		}
+		return d
+	}
+	maxDepth := 0
+	for i := range nodes {
+		maxDepth = max(maxDepth, depth(i))
+	}
+	for level := maxDepth; level >= 0; level-- {
+		for i := range nodes {
+			group := &nodes[i]
+			if !group.Group || depth(i) != level || (group.Width > 0 && group.Height > 0) {
+				continue
+			}
+			minX, minY, maxX, maxY := 0.0, 0.0, 0.0, 0.0
+			found := false
+			for _, child := range nodes {
+				if child.ParentID != group.ID || !child.HasPosition {
+					continue
+				}
+				width, height := defaultNodeWidth, defaultNodeHeight
+				if child.Group {
+					width, height = child.Width, child.Height
+				}
+				if !found {
+					minX, minY, maxX, maxY = child.X, child.Y, child.X+width, child.Y+height
+					found = true
+					continue
+				}
+				minX, minY = min(minX, child.X), min(minY, child.Y)
+				maxX, maxY = max(maxX, child.X+width), max(maxY, child.Y+height)
+			}
+			if !found {
+				group.Width, group.Height = defaultGroupWidth, defaultGroupHeight
+				continue
+			}
+			// The header needs more room than the other sides.
+			group.X, group.Y = minX-padding, minY-2*padding
+			group.Width, group.Height = maxX-minX+2*padding, maxY-minY+3*padding
+			group.HasPosition = true
+		}
+	}
+}
+
+// cytoscapeString reads an id or label that may have been written as a number.
+func cytoscapeString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
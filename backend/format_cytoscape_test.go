@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestCytoscapeRoundTrip(t *testing.T) {
+	testExportImportRoundTrip(t, "cytoscape", roundTripKeeps{edges: true, items: true, notes: true, positions: true})
+}
+
+func TestImportCytoscapeRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "cytoscape", []importCase{
+		{name: "empty"},
+		{name: "not JSON", data: "\x00\x01not a graph\xff"},
+		{name: "no nodes", data: `{"elements":{"nodes":[]}}`},
+	})
+}
+
+func TestImportCytoscapeRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "cytoscape", []importCase{
+		{name: "edge to a missing node", data: `{"elements":{"nodes":[{"data":{"id":"a"}}],"edges":[{"data":{"source":"a","target":"zz"}}]}}`},
+		{name: "parent cycle", data: `{"elements":{"nodes":[{"data":{"id":"a","parent":"b"}},{"data":{"id":"b","parent":"a"}}]}}`},
+		{name: "nodes without data", data: `[{"data":{}},{"group":"nodes"},{"data":{"id":"x","parent":"x"}}]`},
+	})
+}
@@ -0,0 +1,625 @@
+// Graphviz DOT import/export. Subgraph clusters map to group nodes.
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const dotClusterPrefix = "cluster_"
+
+func exportDOT(g ixGraph) ([]byte, error) {
+	directed := false
+	for _, edge := range g.Edges {
+		if edge.Directed {
+			directed = true
+			break
+		}
+	}
+	keyword, op := "graph", "--"
+	if directed {
+		keyword, op = "digraph", "->"
+	}
+
+	children := map[string][]ixNode{}
+	hasChildren := map[string]bool{}
+	for _, node := range g.Nodes {
+		children[node.ParentID] = append(children[node.ParentID], node)
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+	positions := g.absolutePositions()
+
+	groups := map[string]bool{}
+	for _, node := range g.Nodes {
+		groups[node.ID] = node.Group || hasChildren[node.ID]
+	}
+	// An edge cannot end at a cluster, so edges to a group go to an
+	// invisible anchor node inside it, named like the cluster, and are
+	// clipped at the cluster border with lhead/ltail.
+	anchored := map[string]bool{}
+	for _, edge := range g.Edges {
+		for _, id := range []string{edge.Source, edge.Target} {
+			if groups[id] {
+				anchored[id] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s {\n", keyword, dotQuote(g.Name))
+	if len(anchored) > 0 {
+		b.WriteString("  compound=true;\n")
+	}
+	b.WriteString("  node [shape=box];\n")
+	var writeNodes func(parentID string, indent string)
+	writeNodes = func(parentID string, indent string) {
+		for _, node := range children[parentID] {
+			position := positions[node.ID]
+			if node.Group || hasChildren[node.ID] {
+				fmt.Fprintf(&b, "%ssubgraph %s {\n", indent, dotQuote(dotClusterPrefix+node.ID))
+				fmt.Fprintf(&b, "%s  label=%s;\n", indent, dotQuote(node.Label))
+				if anchored[node.ID] {
+					fmt.Fprintf(&b, "%s  %s [shape=point, style=invis, label=\"\"];\n", indent, dotQuote(dotClusterPrefix+node.ID))
+				}
+				writeNodes(node.ID, indent+"  ")
+				fmt.Fprintf(&b, "%s}\n", indent)
+				continue
+			}
+			fmt.Fprintf(&b, "%s%s [label=%s, pos=%s];\n", indent, dotQuote(node.ID), dotQuote(node.Label),
+				dotQuote(formatFloat(position.X)+","+formatFloat(0-position.Y)))
+		}
+	}
+	writeNodes("", "  ")
+
+	for _, edge := range g.Edges {
+		source, target := dotQuote(edge.Source), dotQuote(edge.Target)
+		var attrs []string
+		if groups[edge.Source] {
+			source = dotQuote(dotClusterPrefix + edge.Source)
+			attrs = append(attrs, "ltail="+source)
+		}
+		if groups[edge.Target] {
+			target = dotQuote(dotClusterPrefix + edge.Target)
+			attrs = append(attrs, "lhead="+target)
+		}
+		if edge.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(edge.Label))
+		}
+		if directed && !edge.Directed {
+			attrs = append(attrs, "dir=none")
+		}
+		fmt.Fprintf(&b, "  %s %s %s", source, op, target)
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return []byte(b.String()), nil
+}
+
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+// dotToken is an ID (plain, numeral, quoted or HTML) or a punctuation symbol.
+type dotToken struct {
+	text   string
+	isID   bool
+	quoted bool
+	pos    int
+}
+
+func tokenizeDOT(src string) ([]dotToken, error) {
+	var tokens []dotToken
+	atLineStart := true
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case r == '\n':
+			atLineStart = true
+			i += size
+			continue
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '#' && atLineStart:
+			// Preprocessor-style lines are ignored.
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, importErrorf("unterminated comment")
+			}
+			i += end + 4
+			continue
+		}
+		atLineStart = false
+
+		start := i
+		switch {
+		case strings.HasPrefix(src[i:], "->"), strings.HasPrefix(src[i:], "--"):
+			tokens = append(tokens, dotToken{text: src[i : i+2], pos: start})
+			i += 2
+		case strings.ContainsRune("{}[];,=:", r):
+			tokens = append(tokens, dotToken{text: string(r), pos: start})
+			i += size
+		case r == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(src) {
+					return nil, importErrorf("unterminated string at offset %d", start)
+				}
+				c := src[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(src) {
+					switch src[i+1] {
+					case '"':
+						b.WriteByte('"')
+						i += 2
+						continue
+					case '\n':
+						i += 2
+						continue
+					case 'n', 'l', 'r':
+						b.WriteByte('\n')
+						i += 2
+						continue
+					}
+				}
+				b.WriteByte(c)
+				i++
+			}
+			// "a" + "b" concatenation.
+			if len(tokens) > 0 && tokens[len(tokens)-1].text == "+" {
+				tokens = tokens[:len(tokens)-1]
+				tokens[len(tokens)-1].text += b.String()
+				continue
+			}
+			tokens = append(tokens, dotToken{text: b.String(), isID: true, quoted: true, pos: start})
+		case r == '<':
+			depth := 0
+			for i < len(src) {
+				if src[i] == '<' {
+					depth++
+				} else if src[i] == '>' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+				i++
+			}
+			if depth != 0 {
+				return nil, importErrorf("unterminated HTML label at offset %d", start)
+			}
+			tokens = append(tokens, dotToken{text: stripHTMLLabel(src[start+1 : i-1]), isID: true, quoted: true, pos: start})
+		case r == '+':
+			tokens = append(tokens, dotToken{text: "+", pos: start})
+			i += size
+		case r == '_' || r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) || r >= 0x80:
+			for i < len(src) {
+				c, n := utf8.DecodeRuneInString(src[i:])
+				if !(c == '_' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c) || c >= 0x80 || (c == '-' && i == start)) {
+					break
+				}
+				i += n
+			}
+			tokens = append(tokens, dotToken{text: src[start:i], isID: true, pos: start})
+		default:
+			return nil, importErrorf("unexpected %q at offset %d", r, start)
+		}
+	}
+	return tokens, nil
+}
+
+func stripHTMLLabel(label string) string {
+	label = strings.NewReplacer("<br/>", " ", "<br>", " ", "<BR/>", " ", "<BR>", " ").Replace(label)
+	return strings.Join(strings.Fields(htmlTagPattern.ReplaceAllString(label, "")), " ")
+}
+
+// dotParser builds an ixGraph while walking the token stream.
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	directed bool
+	graph    ixGraph
+	nodes    map[string]int
+	// members collects the node ids mentioned in each open subgraph, so
+	// that a subgraph used as an edge endpoint expands to its nodes.
+	members []map[string]struct{}
+	order   [][]string
+	// clusters maps cluster subgraph ids to their group node ids. A node id
+	// equal to a cluster id is that cluster's anchor and stands for the group.
+	clusters map[string]string
+}
+
+type dotScope struct {
+	clusterID string
+	nodeAttrs map[string]string
+	edgeAttrs map[string]string
+	graphAttr map[string]string
+}
+
+func (s dotScope) child() dotScope {
+	return dotScope{
+		clusterID: s.clusterID,
+		nodeAttrs: cloneStringMap(s.nodeAttrs),
+		edgeAttrs: cloneStringMap(s.edgeAttrs),
+		graphAttr: map[string]string{},
+	}
+}
+
+func cloneStringMap(values map[string]string) map[string]string {
+	cloned := make(map[string]string, len(values))
+	for key, value := range values {
+		cloned[key] = value
+	}
+	return cloned
+}
+
+func importDOT(data []byte, _ url.Values) (ixGraph, error) {
+	tokens, err := tokenizeDOT(string(data))
+	if err != nil {
+		return ixGraph{}, err
+	}
+	p := &dotParser{tokens: tokens, nodes: map[string]int{}, clusters: map[string]string{}}
+	if err := p.parseGraph(); err != nil {
+		return ixGraph{}, err
+	}
+	p.graph.relativizePositions()
+	return p.graph, nil
+}
+
+func (p *dotParser) peek() (dotToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return dotToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *dotParser) next() (dotToken, error) {
+	token, ok := p.peek()
+	if !ok {
+		return dotToken{}, importErrorf("unexpected end of DOT input")
+	}
+	p.pos++
+	return token, nil
+}
+
+func (p *dotParser) accept(text string) bool {
+	token, ok := p.peek()
+	if ok && !token.quoted && strings.EqualFold(token.text, text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *dotParser) expect(text string) error {
+	if p.accept(text) {
+		return nil
+	}
+	token, ok := p.peek()
+	if !ok {
+		return importErrorf("expected %q at end of input", text)
+	}
+	return importErrorf("expected %q at offset %d, found %q", text, token.pos, token.text)
+}
+
+func (p *dotParser) parseGraph() error {
+	p.accept("strict")
+	switch {
+	case p.accept("digraph"):
+		p.directed = true
+	case p.accept("graph"):
+	default:
+		return importErrorf("DOT input must start with graph or digraph")
+	}
+	if token, ok := p.peek(); ok && token.isID {
+		p.graph.Name = token.text
+		p.pos++
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	scope := dotScope{nodeAttrs: map[string]string{}, edgeAttrs: map[string]string{}, graphAttr: map[string]string{}}
+	if err := p.parseStatements(scope); err != nil {
+		return err
+	}
+	if label := scope.graphAttr["label"]; label != "" && p.graph.Name == "" {
+		p.graph.Name = label
+	}
+	return nil
+}
+
+// parseStatements reads statements up to the closing brace.
+func (p *dotParser) parseStatements(scope dotScope) error {
+	for {
+		if p.accept("}") {
+			return nil
+		}
+		if err := p.parseStatement(scope); err != nil {
+			return err
+		}
+		p.accept(";")
+	}
+}
+
+func (p *dotParser) parseStatement(scope dotScope) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+	if !token.quoted {
+		switch strings.ToLower(token.text) {
+		case "graph", "node", "edge":
+			if next, ok := p.peek(); ok && next.text == "[" {
+				attrs, err := p.parseAttrList()
+				if err != nil {
+					return err
+				}
+				target := map[string]map[string]string{"graph": scope.graphAttr, "node": scope.nodeAttrs, "edge": scope.edgeAttrs}[strings.ToLower(token.text)]
+				for key, value := range attrs {
+					target[key] = value
+				}
+				return nil
+			}
+		case "subgraph", "{":
+			p.pos--
+			members, err := p.parseSubgraph(scope)
+			if err != nil {
+				return err
+			}
+			return p.parseEdgeTail(scope, members)
+		}
+	}
+	if !token.isID {
+		return importErrorf("unexpected %q at offset %d", token.text, token.pos)
+	}
+
+	if p.accept("=") {
+		value, err := p.next()
+		if err != nil {
+			return err
+		}
+		scope.graphAttr[strings.ToLower(token.text)] = value.text
+		return nil
+	}
+
+	p.skipPort()
+	if next, ok := p.peek(); ok && (next.text == "->" || next.text == "--") {
+		return p.parseEdgeTail(scope, []string{p.edgeEndpoint(token.text, scope)})
+	}
+
+	attrs := map[string]string{}
+	if next, ok := p.peek(); ok && next.text == "[" {
+		if attrs, err = p.parseAttrList(); err != nil {
+			return err
+		}
+	}
+	if _, anchor := p.clusters[token.text]; !anchor {
+		p.ensureNode(token.text, scope, attrs)
+	}
+	return nil
+}
+
+func (p *dotParser) skipPort() {
+	for p.accept(":") {
+		if token, ok := p.peek(); ok && token.isID {
+			p.pos++
+		}
+	}
+}
+
+// parseSubgraph parses "subgraph [id] { ... }" or "{ ... }" and returns the
+// ids of the nodes it mentions.
+func (p *dotParser) parseSubgraph(scope dotScope) ([]string, error) {
+	id := ""
+	if p.accept("subgraph") {
+		if token, ok := p.peek(); ok && token.isID {
+			id = token.text
+			p.pos++
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	inner := scope.child()
+	groupIndex := -1
+	if strings.HasPrefix(strings.ToLower(id), "cluster") {
+		groupID := strings.TrimPrefix(id, dotClusterPrefix)
+		if groupID == "" {
+			groupID = id
+		}
+		groupIndex = len(p.graph.Nodes)
+		p.graph.Nodes = append(p.graph.Nodes, ixNode{ID: groupID, Label: groupID, Group: true, ParentID: scope.clusterID})
+		p.nodes[groupID] = groupIndex
+		p.clusters[id] = groupID
+		inner.clusterID = groupID
+	}
+
+	p.members = append(p.members, map[string]struct{}{})
+	p.order = append(p.order, nil)
+	err := p.parseStatements(inner)
+	members := p.order[len(p.order)-1]
+	p.members = p.members[:len(p.members)-1]
+	p.order = p.order[:len(p.order)-1]
+	if err != nil {
+		return nil, err
+	}
+	if groupIndex >= 0 {
+		if label := inner.graphAttr["label"]; label != "" {
+			p.graph.Nodes[groupIndex].Label = label
+		}
+	}
+	return members, nil
+}
+
+// parseEdgeTail parses "-> rhs [-> rhs ...] [attrs]" starting from left.
+func (p *dotParser) parseEdgeTail(scope dotScope, left []string) error {
+	var chain [][]string
+	chain = append(chain, left)
+	for {
+		token, ok := p.peek()
+		if !ok || (token.text != "->" && token.text != "--") {
+			break
+		}
+		p.pos++
+		next, ok := p.peek()
+		if !ok {
+			return importErrorf("edge without target")
+		}
+		if !next.quoted && (strings.EqualFold(next.text, "subgraph") || next.text == "{") {
+			members, err := p.parseSubgraph(scope)
+			if err != nil {
+				return err
+			}
+			chain = append(chain, members)
+			continue
+		}
+		if !next.isID {
+			return importErrorf("unexpected %q at offset %d", next.text, next.pos)
+		}
+		p.pos++
+		p.skipPort()
+		chain = append(chain, []string{p.edgeEndpoint(next.text, scope)})
+	}
+	if len(chain) == 1 {
+		return nil
+	}
+
+	attrs := cloneStringMap(scope.edgeAttrs)
+	if token, ok := p.peek(); ok && token.text == "[" {
+		extra, err := p.parseAttrList()
+		if err != nil {
+			return err
+		}
+		for key, value := range extra {
+			attrs[key] = value
+		}
+	}
+	directed := p.directed
+	if dir, ok := attrs["dir"]; ok {
+		directed = dir != "none"
+	}
+
+	// ltail/lhead clip an edge at a cluster: it belongs to that group.
+	if group, ok := p.clusters[attrs["ltail"]]; ok {
+		for i := 0; i+1 < len(chain); i++ {
+			chain[i] = []string{group}
+		}
+	}
+	if group, ok := p.clusters[attrs["lhead"]]; ok {
+		for i := 1; i < len(chain); i++ {
+			chain[i] = []string{group}
+		}
+	}
+	for i := 0; i+1 < len(chain); i++ {
+		for _, source := range chain[i] {
+			for _, target := range chain[i+1] {
+				edge := ixEdge{Source: source, Target: target, Label: attrs["label"], Directed: directed}
+				if attrs["dir"] == "back" {
+					edge.Source, edge.Target = target, source
+				}
+				p.graph.Edges = append(p.graph.Edges, edge)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *dotParser) parseAttrList() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.accept("[") {
+		for !p.accept("]") {
+			key, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if !key.isID {
+				return nil, importErrorf("unexpected %q at offset %d", key.text, key.pos)
+			}
+			value := "true"
+			if p.accept("=") {
+				token, err := p.next()
+				if err != nil {
+					return nil, err
+				}
+				value = token.text
+			}
+			attrs[strings.ToLower(key.text)] = value
+			if !p.accept(",") {
+				p.accept(";")
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// edgeEndpoint returns the node an edge endpoint id refers to: the group
+// for a cluster anchor, otherwise id itself, registered with ensureNode.
+func (p *dotParser) edgeEndpoint(id string, scope dotScope) string {
+	if group, ok := p.clusters[id]; ok {
+		return group
+	}
+	p.ensureNode(id, scope, nil)
+	return id
+}
+
+// ensureNode registers id (placing it in the current cluster the first time
+// it is seen) and applies label/pos attributes.
+func (p *dotParser) ensureNode(id string, scope dotScope, attrs map[string]string) {
+	index, ok := p.nodes[id]
+	if !ok {
+		index = len(p.graph.Nodes)
+		p.nodes[id] = index
+		p.graph.Nodes = append(p.graph.Nodes, ixNode{ID: id, Label: id, ParentID: scope.clusterID})
+		if label := scope.nodeAttrs["label"]; label != "" && label != `\N` {
+			p.graph.Nodes[index].Label = label
+		}
+	} else if p.graph.Nodes[index].ParentID == "" && scope.clusterID != "" {
+		p.graph.Nodes[index].ParentID = scope.clusterID
+	}
+	for i, mentioned := range p.members {
+		if !hasKey(mentioned, id) {
+			mentioned[id] = struct{}{}
+			p.order[i] = append(p.order[i], id)
+		}
+	}
+	node := &p.graph.Nodes[index]
+	if label, ok := attrs["label"]; ok && label != `\N` {
+		node.Label = label
+	}
+	if pos, ok := attrs["pos"]; ok {
+		x, y, found := strings.Cut(strings.TrimSuffix(pos, "!"), ",")
+		fx, errX := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		fy, errY := strconv.ParseFloat(strings.TrimSpace(y), 64)
+		if found && errX == nil && errY == nil {
+			node.X, node.Y, node.HasPosition = fx, -fy, true
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDOTRoundTrip(t *testing.T) {
+	// DOT exports carry no items or notes; group positions come from layout.
+	testExportImportRoundTrip(t, "dot", roundTripKeeps{edges: true, positions: true})
+}
+
+func TestImportDOTRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "dot", []importCase{
+		{name: "empty"},
+		{name: "no header", data: `{}`},
+		{name: "binary", data: "PK\x03\x04"},
+		{name: "unterminated string", data: `digraph { a [label="x] }`},
+		{name: "missing brace", data: `digraph { a -> b`},
+	})
+}
+
+func TestImportDOTRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "dot", []importCase{
+		{name: "self loop", data: `digraph { a -> a }`},
+		{name: "repeated node", data: `digraph { a [label="A"]; a [label="B"]; a -> b }`},
+	})
+}
+
+func TestImportDOTMapsClippedEdgesToGroups(t *testing.T) {
+	g, err := importDOT([]byte(`digraph {
+  compound=true;
+  subgraph cluster_g { label="G"; b; }
+  a -> b [lhead=cluster_g];
+  b -> a [ltail=cluster_g];
+}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := shapeOf(g).Edges
+	want := []string{`G->a "" directed=true`, `a->G "" directed=true`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("edges = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+// FreeMind / Freeplane .mm import: nested <node> elements with TEXT (or an
+// HTML richcontent body), HTML notes and <arrowlink> cross links.
+package main
+
+import (
+	"encoding/xml"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type freeMindMap struct {
+	XMLName xml.Name       `xml:"map"`
+	Nodes   []freeMindNode `xml:"node"`
+}
+
+type freeMindNode struct {
+	ID            string `xml:"ID,attr"`
+	Text          string `xml:"TEXT,attr"`
+	LocalizedText string `xml:"LOCALIZED_TEXT,attr"`
+	RichContent   []struct {
+		Type  string `xml:"TYPE,attr"`
+		Inner string `xml:",innerxml"`
+	} `xml:"richcontent"`
+	// FreeMind 0.8 stored notes in a NodeNote plugin hook.
+	Hooks []struct {
+		Name string `xml:"NAME,attr"`
+		Text string `xml:"text"`
+	} `xml:"hook"`
+	ArrowLinks []struct {
+		Destination string `xml:"DESTINATION,attr"`
+		Label       string `xml:"MIDDLE_LABEL,attr"`
+	} `xml:"arrowlink"`
+	Nodes []freeMindNode `xml:"node"`
+}
+
+var (
+	freeMindBodyPattern  = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	freeMindBlockPattern = regexp.MustCompile(`(?i)</(p|div|h[1-6]|ul|ol|table|tr)>`)
+	freeMindItemPattern  = regexp.MustCompile(`(?i)<li[^>]*>`)
+)
+
+// importFreeMind reads a .mm file. Floating nodes (siblings of the root
+// under <map>) are laid out as separate trees.
+func importFreeMind(data []byte, opts url.Values) (ixGraph, error) {
+	var doc freeMindMap
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return ixGraph{}, importErrorf("invalid FreeMind map: %v", err)
+	}
+	var m mindMap
+	var toTopic func(node freeMindNode) mindTopic
+	toTopic = func(node freeMindNode) mindTopic {
+		topic := mindTopic{ID: node.ID, Title: firstNonEmpty(node.Text, node.LocalizedText)}
+		var notes []string
+		for _, rich := range node.RichContent {
+			switch strings.ToUpper(rich.Type) {
+			case "NODE":
+				if topic.Title == "" {
+					topic.Title = strings.Join(strings.Fields(freeMindHTMLMarkdown(rich.Inner)), " ")
+				}
+			case "NOTE", "DETAILS":
+				notes = append(notes, freeMindHTMLMarkdown(rich.Inner))
+			}
+		}
+		for _, hook := range node.Hooks {
+			if strings.Contains(hook.Name, "NodeNote") && strings.TrimSpace(hook.Text) != "" {
+				notes = append(notes, strings.TrimSpace(hook.Text))
+			}
+		}
+		topic.Notes = strings.Join(notes, "\n\n")
+		for _, link := range node.ArrowLinks {
+			m.Links = append(m.Links, mindLink{From: node.ID, To: link.Destination, Label: link.Label})
+		}
+		for _, child := range node.Nodes {
+			topic.Children = append(topic.Children, toTopic(child))
+		}
+		return topic
+	}
+	for _, node := range doc.Nodes {
+		m.Roots = append(m.Roots, toTopic(node))
+	}
+	return m.graph(opts)
+}
+
+// freeMindHTMLMarkdown converts the HTML of a richcontent element to
+// Markdown: block elements become paragraphs and list items bullets; inline
+// formatting is handled as for Editor.js text. Plain-text content (newer
+// Freeplane versions) is returned unchanged.
+func freeMindHTMLMarkdown(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.Contains(content, "<") {
+		return content
+	}
+	if match := freeMindBodyPattern.FindStringSubmatch(content); match != nil {
+		content = match[1]
+	}
+	content = freeMindItemPattern.ReplaceAllString(content, "\n- ")
+	content = freeMindBlockPattern.ReplaceAllString(content, "\n\n")
+	var paragraphs []string
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		// Source line breaks are not significant in HTML; only list items
+		// start a new line.
+		var kept []string
+		for _, line := range strings.Split(paragraph, "\n") {
+			line = strings.Join(strings.Fields(line), " ")
+			switch {
+			case line == "":
+			case len(kept) == 0 || strings.HasPrefix(line, "- "):
+				kept = append(kept, line)
+			default:
+				kept[len(kept)-1] += " " + line
+			}
+		}
+		if text := editorJSInlineMarkdown(strings.Join(kept, "\n")); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
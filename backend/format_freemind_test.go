@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestImportFreeMindRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "freemind", []importCase{
+		{name: "empty"},
+		{name: "not XML", data: "\x00\x01not a graph\xff"},
+		{name: "unbalanced", data: `<a><b></a>`},
+		{name: "no root node", data: `<map version="1.0.1"></map>`},
+	})
+}
+
+func TestImportFreeMindRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "freemind", []importCase{
+		{name: "topic without text", data: `<map><node><node TEXT="b"/></node></map>`},
+	})
+}
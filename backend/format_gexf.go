@@ -0,0 +1,247 @@
+// GEXF 1.3 import/export for Gephi. Groups use GEXF hierarchy (pid / nested
+// nodes); positions use the viz extension.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gexfNamespace    = "http://gexf.net/1.3"
+	gexfVizNamespace = "http://gexf.net/1.3/viz"
+)
+
+// GEXF node attributes written on export, by attribute id.
+var gexfNodeAttributes = []struct {
+	ID, Title, Type string
+}{
+	{"0", "itemCount", "integer"},
+	{"1", "group", "string"},
+	{"2", "isGroup", "boolean"},
+	{"3", "items", "string"},
+	{"4", "nodeNotes", "string"},
+	{"5", "width", "double"},
+	{"6", "height", "double"},
+}
+
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Meta    *gexfMeta `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	Description string `xml:"description"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           gexfNodes        `xml:"nodes"`
+	Edges           struct {
+		Edges []gexfEdge `xml:"edge"`
+	} `xml:"edges"`
+}
+
+type gexfAttributes struct {
+	Class      string `xml:"class,attr"`
+	Attributes []struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"attribute"`
+}
+
+type gexfNodes struct {
+	Nodes []gexfNode `xml:"node"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	PID       string         `xml:"pid,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+	Position  *struct {
+		X float64 `xml:"x,attr"`
+		Y float64 `xml:"y,attr"`
+	} `xml:"position"`
+	Children *gexfNodes `xml:"nodes"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	ID    string `xml:"id,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Type   string `xml:"type,attr"`
+	Label  string `xml:"label,attr"`
+}
+
+// Gephi's y axis points up while the canvas' points down, so y is negated
+// in both directions.
+func exportGEXF(g ixGraph) ([]byte, error) {
+	labels := make(map[string]string, len(g.Nodes))
+	hasChildren := make(map[string]bool)
+	for _, node := range g.Nodes {
+		labels[node.ID] = node.Label
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+	positions := g.absolutePositions()
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, "<gexf xmlns=\"%s\" xmlns:viz=\"%s\" version=\"1.3\">\n", gexfNamespace, gexfVizNamespace)
+	fmt.Fprintf(&buf, "  <meta lastmodifieddate=\"%s\">\n    <creator>GWeb</creator>\n    <description>%s</description>\n  </meta>\n",
+		time.Now().UTC().Format("2006-01-02"), xmlEscape(g.Name))
+	buf.WriteString("  <graph mode=\"static\" defaultedgetype=\"undirected\">\n")
+	buf.WriteString("    <attributes class=\"node\">\n")
+	for _, attribute := range gexfNodeAttributes {
+		fmt.Fprintf(&buf, "      <attribute id=\"%s\" title=\"%s\" type=\"%s\"/>\n", attribute.ID, attribute.Title, attribute.Type)
+	}
+	buf.WriteString("    </attributes>\n    <nodes>\n")
+	for _, node := range g.Nodes {
+		group := node.Group || hasChildren[node.ID]
+		position := positions[node.ID]
+		fmt.Fprintf(&buf, "      <node id=\"%s\" label=\"%s\"", xmlEscape(node.ID), xmlEscape(node.Label))
+		if node.ParentID != "" {
+			fmt.Fprintf(&buf, " pid=\"%s\"", xmlEscape(node.ParentID))
+		}
+		buf.WriteString(">\n        <attvalues>\n")
+		values := []string{
+			strconv.Itoa(countItems(node.Items)),
+			labels[node.ParentID],
+			strconv.FormatBool(group),
+		}
+		if len(node.Items) > 0 {
+			items, err := json.Marshal(node.Items)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, string(items))
+		} else {
+			values = append(values, "")
+		}
+		values = append(values, node.NodeNotes)
+		if group {
+			values = append(values, formatFloat(node.Width), formatFloat(node.Height))
+		}
+		for i, value := range values {
+			if value == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, "          <attvalue for=\"%s\" value=\"%s\"/>\n", gexfNodeAttributes[i].ID, xmlEscape(value))
+		}
+		buf.WriteString("        </attvalues>\n")
+		fmt.Fprintf(&buf, "        <viz:position x=\"%s\" y=\"%s\" z=\"0\"/>\n", formatFloat(position.X), formatFloat(-position.Y))
+		buf.WriteString("      </node>\n")
+	}
+	buf.WriteString("    </nodes>\n    <edges>\n")
+	for _, edge := range g.Edges {
+		edgeType := "undirected"
+		if edge.Directed {
+			edgeType = "directed"
+		}
+		fmt.Fprintf(&buf, "      <edge id=\"%s\" source=\"%s\" target=\"%s\" type=\"%s\"",
+			xmlEscape(edge.ID), xmlEscape(edge.Source), xmlEscape(edge.Target), edgeType)
+		if edge.Label != "" {
+			fmt.Fprintf(&buf, " label=\"%s\"", xmlEscape(edge.Label))
+		}
+		buf.WriteString("/>\n")
+	}
+	buf.WriteString("    </edges>\n  </graph>\n</gexf>\n")
+	return buf.Bytes(), nil
+}
+
+func importGEXF(data []byte, _ url.Values) (ixGraph, error) {
+	var doc gexfDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return ixGraph{}, importErrorf("invalid GEXF: %v", err)
+	}
+
+	titles := map[string]string{}
+	for _, attributes := range doc.Graph.Attributes {
+		if attributes.Class != "node" {
+			continue
+		}
+		for _, attribute := range attributes.Attributes {
+			titles[attribute.ID] = strings.ToLower(attribute.Title)
+		}
+	}
+
+	g := ixGraph{}
+	if doc.Meta != nil {
+		g.Name = strings.TrimSpace(doc.Meta.Description)
+	}
+
+	var walk func(nodes []gexfNode, parentID string)
+	walk = func(nodes []gexfNode, parentID string) {
+		for _, node := range nodes {
+			values := map[string]string{}
+			for _, value := range node.AttValues {
+				key := value.For
+				if key == "" {
+					key = value.ID
+				}
+				if title, ok := titles[key]; ok {
+					key = title
+				}
+				values[strings.ToLower(key)] = value.Value
+			}
+			converted := ixNode{
+				ID:        node.ID,
+				Label:     firstNonEmpty(node.Label, values["label"], node.ID),
+				ParentID:  firstNonEmpty(node.PID, parentID),
+				Group:     values["isgroup"] == "true" || (node.Children != nil && len(node.Children.Nodes) > 0),
+				NodeNotes: values["nodenotes"],
+			}
+			if node.Position != nil {
+				converted.X, converted.Y, converted.HasPosition = node.Position.X, -node.Position.Y, true
+			}
+			if width, err := strconv.ParseFloat(values["width"], 64); err == nil {
+				converted.Width = width
+			}
+			if height, err := strconv.ParseFloat(values["height"], 64); err == nil {
+				converted.Height = height
+			}
+			if raw := values["items"]; raw != "" {
+				_ = json.Unmarshal([]byte(raw), &converted.Items)
+			}
+			g.Nodes = append(g.Nodes, converted)
+			if node.Children != nil {
+				walk(node.Children.Nodes, node.ID)
+			}
+		}
+	}
+	walk(doc.Graph.Nodes.Nodes, "")
+
+	defaultDirected := doc.Graph.DefaultEdgeType == "directed"
+	for _, edge := range doc.Graph.Edges.Edges {
+		directed := defaultDirected
+		if edge.Type != "" {
+			directed = edge.Type == "directed"
+		}
+		g.Edges = append(g.Edges, ixEdge{
+			ID:       edge.ID,
+			Source:   edge.Source,
+			Target:   edge.Target,
+			Label:    edge.Label,
+			Directed: directed,
+		})
+	}
+
+	g.relativizePositions()
+	return g, nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestGEXFRoundTrip(t *testing.T) {
+	testExportImportRoundTrip(t, "gexf", roundTripKeeps{edges: true, items: true, notes: true, positions: true})
+}
+
+func TestImportGEXFRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "gexf", []importCase{
+		{name: "empty"},
+		{name: "not XML", data: "\x00\x01not a graph\xff"},
+		{name: "wrong root", data: `<graphml/>`},
+	})
+}
+
+func TestImportGEXFRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "gexf", []importCase{
+		{name: "edge to a missing node", data: `<gexf><graph><nodes><node id="a" label="A"/></nodes><edges><edge source="a" target="zz"/></edges></graph></gexf>`},
+		{name: "parent cycle", data: `<gexf><graph><nodes><node id="a" pid="b"/><node id="b" pid="a"/></nodes></graph></gexf>`},
+		{name: "node without id", data: `<gexf><graph><nodes><node label="A"/><node label="B"/></nodes></graph></gexf>`},
+	})
+}
@@ -0,0 +1,385 @@
+// GraphML import/export. Groups become nested graphs; yEd node graphics are
+// written alongside plain data keys so files open in yEd and Gephi.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	graphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+	yFilesNamespace  = "http://www.yworks.com/xml/graphml"
+)
+
+type graphMLDocument struct {
+	XMLName xml.Name       `xml:"graphml"`
+	Keys    []graphMLKey   `xml:"key"`
+	Graphs  []graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID     string `xml:"id,attr"`
+	For    string `xml:"for,attr"`
+	Name   string `xml:"attr.name,attr"`
+	YFiles string `xml:"yfiles.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Data        []graphMLData `xml:"data"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID     string         `xml:"id,attr"`
+	Data   []graphMLData  `xml:"data"`
+	Graphs []graphMLGraph `xml:"graph"`
+}
+
+type graphMLEdge struct {
+	ID       string        `xml:"id,attr"`
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed string        `xml:"directed,attr"`
+	Data     []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Text  string `xml:",chardata"`
+	Inner []byte `xml:",innerxml"`
+}
+
+// graphMLKeyNames maps key ids to what they mean for one element kind.
+type graphMLKeyNames struct {
+	names    map[string]string
+	graphics map[string]bool
+}
+
+func newGraphMLKeyNames(keys []graphMLKey, kind string) graphMLKeyNames {
+	names := graphMLKeyNames{names: map[string]string{}, graphics: map[string]bool{}}
+	for _, key := range keys {
+		if key.For != kind && key.For != "all" {
+			continue
+		}
+		if key.YFiles == "nodegraphics" || key.YFiles == "edgegraphics" {
+			names.graphics[key.ID] = true
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(key.Name))
+		if name == "" {
+			name = strings.ToLower(key.ID)
+		}
+		names.names[key.ID] = name
+	}
+	return names
+}
+
+func (k graphMLKeyNames) values(data []graphMLData) map[string]string {
+	values := map[string]string{}
+	for _, entry := range data {
+		if name, ok := k.names[entry.Key]; ok {
+			values[name] = strings.TrimSpace(entry.Text)
+		} else if _, known := k.graphics[entry.Key]; !known {
+			values[strings.ToLower(entry.Key)] = strings.TrimSpace(entry.Text)
+		}
+	}
+	return values
+}
+
+// yEdGraphics is the geometry and label of a y:ShapeNode / y:GroupNode / edge.
+type yEdGraphics struct {
+	X, Y, Width, Height float64
+	HasGeometry         bool
+	Label               string
+}
+
+// parseYEdGraphics reads the first Geometry and NodeLabel/EdgeLabel of a
+// yfiles graphics element.
+func parseYEdGraphics(inner []byte) yEdGraphics {
+	var graphics yEdGraphics
+	decoder := xml.NewDecoder(bytes.NewReader(inner))
+	inLabel := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return graphics
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Geometry":
+				if !graphics.HasGeometry {
+					for _, attr := range t.Attr {
+						value, _ := strconv.ParseFloat(attr.Value, 64)
+						switch attr.Name.Local {
+						case "x":
+							graphics.X = value
+						case "y":
+							graphics.Y = value
+						case "width":
+							graphics.Width = value
+						case "height":
+							graphics.Height = value
+						}
+					}
+					graphics.HasGeometry = true
+				}
+			case "NodeLabel", "EdgeLabel":
+				inLabel = graphics.Label == ""
+			}
+		case xml.CharData:
+			if inLabel {
+				graphics.Label += string(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "NodeLabel" || t.Name.Local == "EdgeLabel" {
+				inLabel = false
+				graphics.Label = strings.TrimSpace(graphics.Label)
+			}
+		}
+	}
+}
+
+func importGraphML(data []byte, _ url.Values) (ixGraph, error) {
+	var doc graphMLDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return ixGraph{}, importErrorf("invalid GraphML: %v", err)
+	}
+	if len(doc.Graphs) == 0 {
+		return ixGraph{}, importErrorf("GraphML file has no graph")
+	}
+
+	nodeKeys := newGraphMLKeyNames(doc.Keys, "node")
+	edgeKeys := newGraphMLKeyNames(doc.Keys, "edge")
+	graphKeys := newGraphMLKeyNames(doc.Keys, "graph")
+	root := doc.Graphs[0]
+
+	g := ixGraph{Name: graphKeys.values(root.Data)["name"], Kind: graphKeys.values(root.Data)["kind"]}
+	if g.Name == "" {
+		g.Name = root.ID
+	}
+	// Positions from data keys and yEd geometry are absolute.
+	var walk func(graph graphMLGraph, parentID string, directed bool)
+	walk = func(graph graphMLGraph, parentID string, directed bool) {
+		if graph.EdgeDefault != "" {
+			directed = graph.EdgeDefault == "directed"
+		}
+		for _, node := range graph.Nodes {
+			values := nodeKeys.values(node.Data)
+			converted := ixNode{
+				ID:        node.ID,
+				Label:     firstNonEmpty(values["label"], values["name"]),
+				ParentID:  parentID,
+				Group:     len(node.Graphs) > 0,
+				NodeNotes: values["nodenotes"],
+			}
+			for _, entry := range node.Data {
+				if nodeKeys.graphics[entry.Key] {
+					graphics := parseYEdGraphics(entry.Inner)
+					if converted.Label == "" {
+						converted.Label = graphics.Label
+					}
+					if graphics.HasGeometry {
+						converted.X, converted.Y, converted.HasPosition = graphics.X, graphics.Y, true
+						converted.Width, converted.Height = graphics.Width, graphics.Height
+					}
+				}
+			}
+			if x, errX := strconv.ParseFloat(values["x"], 64); errX == nil {
+				if y, errY := strconv.ParseFloat(values["y"], 64); errY == nil {
+					converted.X, converted.Y, converted.HasPosition = x, y, true
+				}
+			}
+			if width, err := strconv.ParseFloat(values["width"], 64); err == nil {
+				converted.Width = width
+			}
+			if height, err := strconv.ParseFloat(values["height"], 64); err == nil {
+				converted.Height = height
+			}
+			if raw := values["items"]; raw != "" {
+				_ = json.Unmarshal([]byte(raw), &converted.Items)
+			}
+			g.Nodes = append(g.Nodes, converted)
+			for _, nested := range node.Graphs {
+				walk(nested, node.ID, directed)
+			}
+		}
+		for _, edge := range graph.Edges {
+			values := edgeKeys.values(edge.Data)
+			converted := ixEdge{
+				ID:       edge.ID,
+				Source:   edge.Source,
+				Target:   edge.Target,
+				Label:    values["label"],
+				Directed: directed,
+			}
+			if edge.Directed != "" {
+				converted.Directed = edge.Directed == "true"
+			}
+			for _, entry := range edge.Data {
+				if edgeKeys.graphics[entry.Key] && converted.Label == "" {
+					converted.Label = parseYEdGraphics(entry.Inner).Label
+				}
+			}
+			g.Edges = append(g.Edges, converted)
+		}
+	}
+	walk(root, "", root.EdgeDefault == "directed")
+	g.relativizePositions()
+	return g, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// graphMLWriter emits GraphML with indentation, escaping all text.
+type graphMLWriter struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (w *graphMLWriter) line(format string, args ...any) {
+	w.buf.WriteString(strings.Repeat("  ", w.indent))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			args[i] = xmlEscape(v)
+		case rawXML:
+			args[i] = string(v)
+		}
+	}
+	w.buf.WriteString(fmt.Sprintf(format, args...))
+	w.buf.WriteByte('\n')
+}
+
+func xmlEscape(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func exportGraphML(g ixGraph) ([]byte, error) {
+	w := &graphMLWriter{}
+	w.buf.WriteString(xml.Header)
+	w.line(`<graphml xmlns="%s" xmlns:y="%s">`, graphMLNamespace, yFilesNamespace)
+	w.indent++
+	for _, key := range []string{
+		`<key id="g_name" for="graph" attr.name="name" attr.type="string"/>`,
+		`<key id="g_kind" for="graph" attr.name="kind" attr.type="string"/>`,
+		`<key id="d_label" for="node" attr.name="label" attr.type="string"/>`,
+		`<key id="d_x" for="node" attr.name="x" attr.type="double"/>`,
+		`<key id="d_y" for="node" attr.name="y" attr.type="double"/>`,
+		`<key id="d_width" for="node" attr.name="width" attr.type="double"/>`,
+		`<key id="d_height" for="node" attr.name="height" attr.type="double"/>`,
+		`<key id="d_items" for="node" attr.name="items" attr.type="string"/>`,
+		`<key id="d_item_count" for="node" attr.name="itemCount" attr.type="int"/>`,
+		`<key id="d_notes" for="node" attr.name="nodeNotes" attr.type="string"/>`,
+		`<key id="d_graphics" for="node" yfiles.type="nodegraphics"/>`,
+		`<key id="e_label" for="edge" attr.name="label" attr.type="string"/>`,
+		`<key id="e_graphics" for="edge" yfiles.type="edgegraphics"/>`,
+	} {
+		w.buf.WriteString(strings.Repeat("  ", w.indent) + key + "\n")
+	}
+
+	children := map[string][]ixNode{}
+	for _, node := range g.Nodes {
+		children[node.ParentID] = append(children[node.ParentID], node)
+	}
+	positions := g.absolutePositions()
+
+	w.line(`<graph id="G" edgedefault="undirected">`)
+	w.indent++
+	w.line(`<data key="g_name">%s</data>`, g.Name)
+	if g.Kind != "" {
+		w.line(`<data key="g_kind">%s</data>`, g.Kind)
+	}
+	var writeNodes func(parentID string)
+	writeNodes = func(parentID string) {
+		for _, node := range children[parentID] {
+			position := positions[node.ID]
+			group := node.Group || len(children[node.ID]) > 0
+			if group {
+				w.line(`<node id="%s" yfiles.foldertype="group">`, node.ID)
+			} else {
+				w.line(`<node id="%s">`, node.ID)
+			}
+			w.indent++
+			w.line(`<data key="d_label">%s</data>`, node.Label)
+			w.line(`<data key="d_x">%s</data>`, formatFloat(position.X))
+			w.line(`<data key="d_y">%s</data>`, formatFloat(position.Y))
+			width, height := defaultNodeWidth, defaultNodeHeight
+			if group {
+				width, height = node.Width, node.Height
+				w.line(`<data key="d_width">%s</data>`, formatFloat(width))
+				w.line(`<data key="d_height">%s</data>`, formatFloat(height))
+			}
+			if len(node.Items) > 0 {
+				items, err := json.Marshal(node.Items)
+				if err == nil {
+					w.line(`<data key="d_items">%s</data>`, string(items))
+				}
+			}
+			w.line(`<data key="d_item_count">%d</data>`, countItems(node.Items))
+			if strings.TrimSpace(node.NodeNotes) != "" {
+				w.line(`<data key="d_notes">%s</data>`, node.NodeNotes)
+			}
+			geometry := fmt.Sprintf(`<y:Geometry x="%s" y="%s" width="%s" height="%s"/>`,
+				formatFloat(position.X), formatFloat(position.Y), formatFloat(width), formatFloat(height))
+			if group {
+				w.line(`<data key="d_graphics"><y:ProxyAutoBoundsNode><y:Realizers active="0"><y:GroupNode>%s<y:NodeLabel>%s</y:NodeLabel></y:GroupNode></y:Realizers></y:ProxyAutoBoundsNode></data>`, rawXML(geometry), node.Label)
+				w.line(`<graph id="%s" edgedefault="undirected">`, node.ID+":")
+				w.indent++
+				writeNodes(node.ID)
+				w.indent--
+				w.line(`</graph>`)
+			} else {
+				w.line(`<data key="d_graphics"><y:ShapeNode>%s<y:NodeLabel>%s</y:NodeLabel></y:ShapeNode></data>`, rawXML(geometry), node.Label)
+			}
+			w.indent--
+			w.line(`</node>`)
+		}
+	}
+	writeNodes("")
+
+	for _, edge := range g.Edges {
+		w.line(`<edge id="%s" source="%s" target="%s" directed="%s">`, edge.ID, edge.Source, edge.Target, strconv.FormatBool(edge.Directed))
+		w.indent++
+		if edge.Label != "" {
+			w.line(`<data key="e_label">%s</data>`, edge.Label)
+		}
+		arrow := "none"
+		if edge.Directed {
+			arrow = "standard"
+		}
+		w.line(`<data key="e_graphics"><y:PolyLineEdge><y:Arrows source="none" target="%s"/><y:EdgeLabel>%s</y:EdgeLabel></y:PolyLineEdge></data>`, rawXML(arrow), edge.Label)
+		w.indent--
+		w.line(`</edge>`)
+	}
+	w.indent--
+	w.line(`</graph>`)
+	w.indent--
+	w.line(`</graphml>`)
+	return w.buf.Bytes(), nil
+}
+
+// rawXML marks pre-built markup that graphMLWriter.line must not escape.
+type rawXML string
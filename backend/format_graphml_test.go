@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestGraphMLRoundTrip(t *testing.T) {
+	testExportImportRoundTrip(t, "graphml", roundTripKeeps{edges: true, items: true, notes: true, positions: true})
+}
+
+func TestImportGraphMLRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "graphml", []importCase{
+		{name: "empty"},
+		{name: "not XML", data: "\x00\x01not a graph\xff"},
+		{name: "truncated", data: `<graphml><graph>`},
+		{name: "no graph", data: `<graphml></graphml>`},
+	})
+}
+
+func TestImportGraphMLRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "graphml", []importCase{
+		{name: "edge to a missing node", data: `<graphml><graph edgedefault="directed"><node id="a"/><edge source="a" target="zz"/></graph></graphml>`},
+		{name: "duplicate ids", data: `<graphml><graph><node id="a"/><node id="a"/><node/></graph></graphml>`},
+		{name: "nested duplicate", data: `<graphml><graph><node id="a"><graph><node id="a"/></graph></node></graph></graphml>`},
+	})
+}
@@ -0,0 +1,669 @@
+// Markdown vault import/export: one file per node, groups as folders, edges
+// as [[wikilinks]] (Obsidian/Logseq style).
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	markdownItemsHeading = "## Items"
+	markdownLinksHeading = "## Links"
+	markdownNotePrefix   = "Note: "
+	markdownDirected     = "→"
+	markdownUndirected   = "↔"
+)
+
+func exportMarkdownVault(g ixGraph) ([]byte, error) {
+	files, err := markdownVaultFiles(g, "", map[string]struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	return zipArchive(files)
+}
+
+// markdownVaultFiles writes one note per node under dir. Group nodes become
+// folders holding a folder note of the same name. names holds the lower-cased
+// note names already used in the archive, since wikilinks resolve by name.
+func markdownVaultFiles(g ixGraph, dir string, names map[string]struct{}) ([]archiveFile, error) {
+	byID := make(map[string]ixNode, len(g.Nodes))
+	hasChildren := map[string]bool{}
+	for _, node := range g.Nodes {
+		byID[node.ID] = node
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+
+	noteNames := make(map[string]string, len(g.Nodes))
+	for _, node := range g.Nodes {
+		noteNames[node.ID] = uniqueFileName(firstNonEmpty(vaultFileName(node.Label), vaultFileName(node.ID), "Untitled"), names)
+	}
+	var folderOf func(id string, depth int) string
+	folderOf = func(id string, depth int) string {
+		node, ok := byID[id]
+		if !ok || depth > len(g.Nodes) {
+			return dir
+		}
+		return path.Join(folderOf(node.ParentID, depth+1), noteNames[id])
+	}
+
+	outgoing := map[string][]ixEdge{}
+	for _, edge := range g.Edges {
+		if _, ok := byID[edge.Target]; ok {
+			outgoing[edge.Source] = append(outgoing[edge.Source], edge)
+		}
+	}
+
+	files := make([]archiveFile, 0, len(g.Nodes))
+	positions := g.absolutePositions()
+	for _, node := range g.Nodes {
+		group := node.Group || hasChildren[node.ID]
+		var b strings.Builder
+
+		b.WriteString("---\n")
+		fmt.Fprintf(&b, "id: %s\n", yamlString(node.ID))
+		if group {
+			b.WriteString("type: group\n")
+		}
+		position := positions[node.ID]
+		fmt.Fprintf(&b, "position:\n  x: %s\n  y: %s\n", formatFloat(position.X), formatFloat(position.Y))
+		if parent, ok := byID[node.ParentID]; ok {
+			fmt.Fprintf(&b, "group: %s\n", yamlString(noteNames[parent.ID]))
+		}
+		if group {
+			fmt.Fprintf(&b, "width: %s\nheight: %s\n", formatFloat(node.Width), formatFloat(node.Height))
+		}
+		b.WriteString("---\n\n")
+
+		writeMarkdownNoteBody(&b, node)
+		if edges := outgoing[node.ID]; len(edges) > 0 {
+			b.WriteString("\n" + markdownLinksHeading + "\n\n")
+			for _, edge := range edges {
+				arrow := markdownDirected
+				if !edge.Directed {
+					arrow = markdownUndirected
+				}
+				fmt.Fprintf(&b, "- %s [[%s]]", arrow, noteNames[edge.Target])
+				if label := strings.TrimSpace(edge.Label); label != "" {
+					b.WriteString(": " + strings.ReplaceAll(label, "\n", " "))
+				}
+				b.WriteString("\n")
+			}
+		}
+
+		name := path.Join(folderOf(node.ParentID, 0), noteNames[node.ID]+".md")
+		if group {
+			name = path.Join(folderOf(node.ID, 0), noteNames[node.ID]+".md")
+		}
+		files = append(files, archiveFile{Name: name, Data: []byte(b.String())})
+	}
+	return files, nil
+}
+
+// writeMarkdownNoteBody writes the title, notes and items of a node.
+func writeMarkdownNoteBody(b *strings.Builder, node ixNode) {
+	fmt.Fprintf(b, "# %s\n", strings.ReplaceAll(strings.TrimSpace(firstNonEmpty(node.Label, node.ID)), "\n", " "))
+	if notes := editorJSToMarkdown(node.NodeNotes); notes != "" {
+		b.WriteString("\n" + notes + "\n")
+	}
+	if len(node.Items) > 0 {
+		b.WriteString("\n" + markdownItemsHeading + "\n\n")
+		writeMarkdownItems(b, node.Items, 0)
+	}
+}
+
+// writeMarkdownItems renders items as nested lists; notes become "Note:"
+// entries and itemNotes an indented paragraph under the item.
+func writeMarkdownItems(b *strings.Builder, items []ixItem, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, item := range items {
+		fmt.Fprintf(b, "%s- %s\n", indent, strings.ReplaceAll(strings.TrimSpace(item.Title), "\n", " "))
+		if notes := editorJSToMarkdown(item.ItemNotes); notes != "" {
+			b.WriteString("\n")
+			for _, line := range strings.Split(notes, "\n") {
+				if line == "" {
+					b.WriteString("\n")
+					continue
+				}
+				b.WriteString(indent + "  " + line + "\n")
+			}
+			b.WriteString("\n")
+		}
+		for _, note := range item.Notes {
+			fmt.Fprintf(b, "%s  - %s%s\n", indent, markdownNotePrefix, strings.ReplaceAll(strings.TrimSpace(note.Title), "\n", " "))
+		}
+		writeMarkdownItems(b, item.Children, depth+1)
+	}
+}
+
+// yamlString quotes a YAML scalar; JSON strings are valid YAML.
+func yamlString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// vaultFileName drops characters that are invalid in file names or that
+// would break a [[wikilink]].
+func vaultFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return ' '
+		case strings.ContainsRune(`/\:*?"<>|#^[]`, r):
+			return '-'
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.Trim(name, ". ")
+	if len([]rune(name)) > 120 {
+		name = strings.TrimSpace(string([]rune(name)[:120]))
+	}
+	return name
+}
+
+// uniqueFileName appends " (2)", " (3)", ... until name is unused.
+func uniqueFileName(name string, used map[string]struct{}) string {
+	candidate := name
+	for i := 2; hasKey(used, strings.ToLower(candidate)); i++ {
+		candidate = name + " (" + strconv.Itoa(i) + ")"
+	}
+	used[strings.ToLower(candidate)] = struct{}{}
+	return candidate
+}
+
+var (
+	markdownWikiLinkPattern = regexp.MustCompile(`(!?)\[\[([^\[\]|#^]*)(?:[#^][^\[\]|]*)?(?:\|([^\[\]]*))?\]\]`)
+	markdownFileLinkPattern = regexp.MustCompile(`\[[^\]]*\]\(([^)\s]+\.md)\)`)
+	markdownLinkLinePattern = regexp.MustCompile(`^\s*[-*+]\s+(→|↔|->|<->)?\s*\[\[([^\[\]|#^]+)[^\[\]]*\]\](?::\s*(.*))?$`)
+	logseqPropertyPattern   = regexp.MustCompile(`^\s*([\w-]+)::\s*(.*)$`)
+)
+
+// vaultNote is one parsed Markdown file.
+type vaultNote struct {
+	path   string
+	dir    string
+	base   string
+	title  string
+	fields map[string]string
+	node   *ixNode
+	links  []vaultLink
+}
+
+type vaultLink struct {
+	target   string
+	label    string
+	directed bool
+}
+
+// importMarkdownVault reads a zip of Markdown files (an Obsidian or Logseq
+// vault, or a plain folder). Folders become groups unless folders=false.
+func importMarkdownVault(data []byte, opts url.Values) (ixGraph, error) {
+	files, err := readZipArchive(data)
+	if err != nil {
+		return ixGraph{}, err
+	}
+	useFolders := opts.Get("folders") != "false"
+
+	var paths []string
+	logseq := false
+	for _, file := range files {
+		if strings.HasPrefix(file.Name, "logseq/") || strings.Contains(file.Name, "/logseq/") {
+			logseq = true
+		}
+	}
+
+	g := ixGraph{}
+	skipped := 0
+	contents := map[string][]byte{}
+	for _, file := range files {
+		hidden := false
+		for _, part := range strings.Split(file.Name, "/") {
+			if strings.HasPrefix(part, ".") || part == "logseq" {
+				hidden = true
+			}
+		}
+		if hidden {
+			continue
+		}
+		if !strings.EqualFold(path.Ext(file.Name), ".md") && !strings.EqualFold(path.Ext(file.Name), ".markdown") {
+			skipped++
+			continue
+		}
+		paths = append(paths, file.Name)
+		contents[file.Name] = file.Data
+	}
+	if len(paths) == 0 {
+		return ixGraph{}, importErrorf("archive contains no Markdown files")
+	}
+	if skipped > 0 {
+		g.Warnings = append(g.Warnings, fmt.Sprintf("skipped %d non-Markdown files", skipped))
+	}
+	sort.Strings(paths)
+
+	// A single top-level folder (zipped vault directory) names the graph.
+	root := ""
+	if first, _, found := strings.Cut(paths[0], "/"); found {
+		root = first + "/"
+		for _, name := range paths {
+			if !strings.HasPrefix(name, root) {
+				root = ""
+				break
+			}
+		}
+	}
+	g.Name = "Imported vault"
+	if root != "" {
+		g.Name = strings.TrimSuffix(root, "/")
+	}
+
+	groups := map[string]*ixNode{}
+	var groupOrder []string
+	var groupFor func(dir string) string
+	groupFor = func(dir string) string {
+		if dir == "" || dir == "." || !useFolders {
+			return ""
+		}
+		if _, ok := groups[dir]; !ok {
+			parentID := groupFor(path.Dir(dir))
+			groups[dir] = &ixNode{ID: newID("group"), Label: path.Base(dir), Group: true, ParentID: parentID}
+			groupOrder = append(groupOrder, dir)
+		}
+		return groups[dir].ID
+	}
+
+	var notes []*vaultNote
+	for _, name := range paths {
+		relative := strings.TrimPrefix(name, root)
+		dir := path.Dir(relative)
+		if dir == "." {
+			dir = ""
+		}
+		// Logseq keeps every page in pages/ and journals/.
+		if logseq && (dir == "pages" || dir == "journals") {
+			dir = ""
+		}
+		base := strings.TrimSuffix(path.Base(relative), path.Ext(relative))
+		note := parseVaultNote(string(contents[name]))
+		note.path, note.dir, note.base = strings.TrimSuffix(relative, path.Ext(relative)), dir, base
+		if logseq {
+			if decoded, err := url.PathUnescape(base); err == nil {
+				base = decoded
+			}
+			base = strings.ReplaceAll(base, "___", "/")
+		}
+		note.node.Label = firstNonEmpty(note.fields["title"], note.title, base)
+
+		// A folder note (Folder/Folder.md) describes the folder's group.
+		if useFolders && dir != "" && strings.EqualFold(path.Base(dir), note.base) {
+			groupFor(dir)
+			group := groups[dir]
+			note.node.Group, note.node.ParentID = true, group.ParentID
+			if id := note.fields["id"]; id != "" {
+				note.node.ID = id
+			} else {
+				note.node.ID = group.ID
+			}
+			groups[dir] = note.node
+		} else {
+			note.node.ParentID = groupFor(dir)
+			if note.node.ID == "" {
+				note.node.ID = newID("node")
+			}
+		}
+		notes = append(notes, note)
+	}
+
+	// Folder notes may change a group's id after its children were parsed.
+	groupIDs := map[string]string{}
+	for _, dir := range groupOrder {
+		groupIDs[dir] = groups[dir].ID
+	}
+	for _, dir := range groupOrder {
+		group := groups[dir]
+		group.ParentID = groupIDs[path.Dir(dir)]
+		if !hasFolderNote(notes, group) {
+			g.Nodes = append(g.Nodes, *group)
+		}
+	}
+	for _, note := range notes {
+		if !note.node.Group {
+			note.node.ParentID = groupIDs[note.dir]
+		}
+	}
+
+	lookup := map[string]string{}
+	for _, note := range notes {
+		for _, key := range []string{note.path, note.base, note.node.Label} {
+			if _, taken := lookup[strings.ToLower(key)]; !taken {
+				lookup[strings.ToLower(key)] = note.node.ID
+			}
+		}
+		for _, alias := range splitYAMLList(note.fields["aliases"]) {
+			if _, taken := lookup[strings.ToLower(alias)]; !taken {
+				lookup[strings.ToLower(alias)] = note.node.ID
+			}
+		}
+	}
+
+	unresolved := map[string]struct{}{}
+	seen := map[[2]string]struct{}{}
+	for _, note := range notes {
+		g.Nodes = append(g.Nodes, *note.node)
+		for _, link := range note.links {
+			target := strings.TrimSpace(strings.TrimSuffix(link.target, ".md"))
+			targetID, ok := lookup[strings.ToLower(target)]
+			if !ok {
+				targetID, ok = lookup[strings.ToLower(path.Base(target))]
+			}
+			if !ok {
+				if !hasKey(unresolved, target) && len(unresolved) < 50 {
+					g.Warnings = append(g.Warnings, fmt.Sprintf("unresolved link [[%s]] in %s", target, note.path))
+				}
+				unresolved[target] = struct{}{}
+				continue
+			}
+			if targetID == note.node.ID {
+				continue
+			}
+			key := [2]string{note.node.ID, targetID}
+			reverse := [2]string{targetID, note.node.ID}
+			if hasEdgeKey(seen, key) || (!link.directed && hasEdgeKey(seen, reverse)) {
+				continue
+			}
+			seen[key] = struct{}{}
+			g.Edges = append(g.Edges, ixEdge{Source: note.node.ID, Target: targetID, Label: link.label, Directed: link.directed})
+		}
+	}
+
+	positioned := true
+	for _, node := range g.Nodes {
+		if !node.HasPosition {
+			positioned = false
+			break
+		}
+	}
+	if positioned {
+		g.relativizePositions()
+	} else {
+		g.layoutLayered("TB")
+	}
+	return g, nil
+}
+
+func hasEdgeKey(seen map[[2]string]struct{}, key [2]string) bool {
+	_, ok := seen[key]
+	return ok
+}
+
+func hasFolderNote(notes []*vaultNote, group *ixNode) bool {
+	for _, note := range notes {
+		if note.node == group {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVaultNote splits a note into front matter, title, items, links and
+// the remaining body, which becomes nodeNotes.
+func parseVaultNote(content string) *vaultNote {
+	note := &vaultNote{fields: map[string]string{}, node: &ixNode{}}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				parseFrontMatter(lines[1:i], note.fields)
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+	// Logseq page properties ("title:: Name") precede the content.
+	for len(lines) > 0 {
+		match := logseqPropertyPattern.FindStringSubmatch(strings.TrimPrefix(strings.TrimSpace(lines[0]), "- "))
+		if match == nil {
+			break
+		}
+		note.fields[strings.ToLower(match[1])] = strings.TrimSpace(match[2])
+		lines = lines[1:]
+	}
+
+	node := note.node
+	node.ID = note.fields["id"]
+	node.Group = note.fields["type"] == "group"
+	x, errX := strconv.ParseFloat(note.fields["position.x"], 64)
+	y, errY := strconv.ParseFloat(note.fields["position.y"], 64)
+	if errX == nil && errY == nil {
+		node.X, node.Y, node.HasPosition = x, y, true
+	}
+	node.Width, _ = strconv.ParseFloat(note.fields["width"], 64)
+	node.Height, _ = strconv.ParseFloat(note.fields["height"], 64)
+
+	hasItemsHeading := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == markdownItemsHeading {
+			hasItemsHeading = true
+		}
+	}
+
+	var body, itemLines []string
+	section := ""
+	fenced := false
+	titled := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		if !fenced {
+			if !titled && trimmed != "" {
+				titled = true
+				if title, ok := strings.CutPrefix(trimmed, "# "); ok {
+					note.title = strings.TrimSpace(title)
+					continue
+				}
+			}
+			if match := markdownHeadingPattern.FindStringSubmatch(trimmed); match != nil && len(match[1]) <= 2 {
+				switch trimmed {
+				case markdownItemsHeading, markdownLinksHeading:
+					section = trimmed
+					continue
+				}
+				section = ""
+			}
+			if section == markdownLinksHeading {
+				if match := markdownLinkLinePattern.FindStringSubmatch(line); match != nil {
+					note.links = append(note.links, vaultLink{target: match[2], label: strings.TrimSpace(match[3]), directed: match[1] != "↔" && match[1] != "<->"})
+					continue
+				}
+			}
+			startsList := markdownListItemPattern.MatchString(line) && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t")
+			if (section == markdownItemsHeading || (!hasItemsHeading && section == "")) && startsList {
+				end := i + 1
+				for end < len(lines) {
+					next := lines[end]
+					if markdownListItemPattern.MatchString(next) || strings.HasPrefix(next, " ") || strings.HasPrefix(next, "\t") {
+						end++
+						continue
+					}
+					// Blank lines stay in the list when indented content follows.
+					if strings.TrimSpace(next) == "" && end+1 < len(lines) && (strings.HasPrefix(lines[end+1], " ") || strings.HasPrefix(lines[end+1], "\t")) {
+						end++
+						continue
+					}
+					break
+				}
+				itemLines = append(itemLines, lines[i:end]...)
+				i = end - 1
+				continue
+			}
+		}
+		body = append(body, line)
+	}
+
+	text := strings.Join(body, "\n")
+	note.collectLinks(text)
+	note.collectLinks(strings.Join(itemLines, "\n"))
+	node.NodeNotes = markdownToEditorJS(text)
+	node.Items = parseMarkdownItems(itemLines)
+	return note
+}
+
+// collectLinks records [[wikilinks]] and links to .md files as directed
+// edges; embeds of non-Markdown files (images) are ignored.
+func (note *vaultNote) collectLinks(text string) {
+	for _, match := range markdownWikiLinkPattern.FindAllStringSubmatch(text, -1) {
+		target := strings.TrimSpace(match[2])
+		if target == "" || (match[1] == "!" && path.Ext(target) != "" && !strings.EqualFold(path.Ext(target), ".md")) {
+			continue
+		}
+		note.links = append(note.links, vaultLink{target: target, directed: true})
+	}
+	for _, match := range markdownFileLinkPattern.FindAllStringSubmatch(text, -1) {
+		if strings.Contains(match[1], "://") {
+			continue
+		}
+		target, err := url.PathUnescape(match[1])
+		if err != nil {
+			target = match[1]
+		}
+		note.links = append(note.links, vaultLink{target: strings.TrimPrefix(target, "./"), directed: true})
+	}
+}
+
+// parseMarkdownItems turns list lines into the items tree. "Note:" entries
+// become item notes and indented paragraphs become itemNotes.
+func parseMarkdownItems(lines []string) []ixItem {
+	type level struct {
+		indent int
+		items  *[]ixItem
+		item   *ixItem
+	}
+	var root []ixItem
+	stack := []level{{indent: -1, items: &root}}
+	var last *ixItem
+	var lastNotes []string
+	flushNotes := func() {
+		if last != nil && len(lastNotes) > 0 {
+			last.ItemNotes = markdownToEditorJS(strings.Join(lastNotes, "\n"))
+		}
+		lastNotes = nil
+	}
+
+	for _, line := range lines {
+		match := markdownListItemPattern.FindStringSubmatch(line)
+		if match == nil {
+			if last != nil && !logseqPropertyPattern.MatchString(line) {
+				lastNotes = append(lastNotes, strings.TrimSpace(line))
+			}
+			continue
+		}
+		indent := len(strings.ReplaceAll(match[1], "\t", "    "))
+		for len(stack) > 1 && indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		title := markdownWikiLinkPattern.ReplaceAllStringFunc(match[4], func(link string) string {
+			parts := markdownWikiLinkPattern.FindStringSubmatch(link)
+			return firstNonEmpty(parts[3], parts[2])
+		})
+		title = strings.TrimSpace(title)
+
+		if parent := stack[len(stack)-1].item; parent != nil {
+			if noteTitle, ok := strings.CutPrefix(title, markdownNotePrefix); ok {
+				parent.Notes = append(parent.Notes, ixNote{ID: newID("note"), Title: strings.TrimSpace(noteTitle)})
+				continue
+			}
+		}
+		flushNotes()
+		if title == "" {
+			continue
+		}
+		items := stack[len(stack)-1].items
+		*items = append(*items, ixItem{ID: newID("item"), Title: title, Notes: []ixNote{}, Children: []ixItem{}})
+		last = &(*items)[len(*items)-1]
+		stack = append(stack, level{indent: indent, items: &last.Children, item: last})
+	}
+	flushNotes()
+	return root
+}
+
+// parseFrontMatter reads the simple YAML written by exportMarkdownVault and
+// common Obsidian properties: scalars, one level of nesting ("position.x")
+// and inline or block lists (joined with commas).
+func parseFrontMatter(lines []string, fields map[string]string) {
+	parent := ""
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		trimmed := strings.TrimSpace(line)
+		if indented && parent != "" {
+			if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+				fields[parent] = strings.TrimPrefix(fields[parent]+","+yamlScalar(item), ",")
+				continue
+			}
+			if key, value, ok := strings.Cut(trimmed, ":"); ok {
+				fields[parent+"."+strings.ToLower(strings.TrimSpace(key))] = yamlScalar(value)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		parent = ""
+		if value == "" {
+			parent = key
+			continue
+		}
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			var parts []string
+			for _, part := range strings.Split(value[1:len(value)-1], ",") {
+				if part = yamlScalar(part); part != "" {
+					parts = append(parts, part)
+				}
+			}
+			fields[key] = strings.Join(parts, ",")
+			continue
+		}
+		fields[key] = yamlScalar(value)
+	}
+}
+
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) {
+		var decoded string
+		if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+			return decoded
+		}
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+func splitYAMLList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestMarkdownVaultRoundTrip(t *testing.T) {
+	testExportImportRoundTrip(t, "markdown", roundTripKeeps{edges: true, items: true, notes: true, positions: true})
+}
+
+func TestImportMarkdownVaultRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "markdown", []importCase{
+		{name: "empty"},
+		{name: "not a zip", data: "\x00\x01not a graph\xff"},
+		{name: "no notes", data: testZip(t, map[string]string{"readme.txt": "x"})},
+		{name: "path traversal", data: testZip(t, map[string]string{"../a.md": "# A"})},
+	})
+}
+
+func TestImportMarkdownVaultRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "markdown", []importCase{
+		{name: "bad front matter", data: testZip(t, map[string]string{"a.md": "---\nx: [\n---\n[[b]] and [[missing]]", "b.md": "---\n"})},
+		{name: "same name in two folders", data: testZip(t, map[string]string{"x/a.md": "[[a]]", "y/a.md": "[[x/a]]"})},
+	})
+}
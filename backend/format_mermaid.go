@@ -0,0 +1,455 @@
+// Mermaid flowchart import/export. Subgraphs map to group nodes; Mermaid has
+// no coordinates, so imports are laid out along the chart direction.
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	mermaidHeaderPattern   = regexp.MustCompile(`^(?i)(flowchart|graph)(?:\s+(TB|TD|BT|LR|RL))?\s*$`)
+	mermaidEntityPattern   = regexp.MustCompile(`#(\w+);`)
+	mermaidBreakPattern    = regexp.MustCompile(`(?i)<br\s*/?>`)
+	mermaidSubgraphPattern = regexp.MustCompile(`^([^\s\["]+)\s*\[(.*)\]$`)
+	// mermaidLinkPattern matches a link without inline text, e.g. -->, ---,
+	// -.->, ==>, <-->, --o or ~~~.
+	mermaidLinkPattern = regexp.MustCompile(`^([<ox])?(-{2,}|={2,}|-\.+-|~{3,})([>ox])?`)
+	// mermaidTextLinkPattern matches the opening of "-- text -->" style links.
+	mermaidTextLinkPattern = regexp.MustCompile(`^([<ox])?(--|==|-\.)\s`)
+	mermaidTextLinkEnds    = map[string]*regexp.Regexp{
+		"--": regexp.MustCompile(`\s(-{2,}[>ox]|-{3,})`),
+		"==": regexp.MustCompile(`\s(={2,}[>ox]|={3,})`),
+		"-.": regexp.MustCompile(`\s(\.-+[>ox]?)`),
+	}
+)
+
+// mermaidShapes lists node shape delimiters, longest openers first.
+var mermaidShapes = []struct{ open, close string }{
+	{"(((", ")))"},
+	{"((", "))"},
+	{"([", "])"},
+	{"[[", "]]"},
+	{"[(", ")]"},
+	{"{{", "}}"},
+	{"[/", "/]"},
+	{"[/", `\]`},
+	{`[\`, `\]`},
+	{`[\`, "/]"},
+	{"[", "]"},
+	{"(", ")"},
+	{"{", "}"},
+	{">", "]"},
+}
+
+var mermaidKeywords = map[string]bool{
+	"end": true, "graph": true, "flowchart": true, "subgraph": true, "style": true,
+	"class": true, "classdef": true, "click": true, "linkstyle": true, "direction": true,
+}
+
+func exportMermaid(g ixGraph) ([]byte, error) {
+	ids := make(map[string]string, len(g.Nodes))
+	used := map[string]struct{}{}
+	for _, node := range g.Nodes {
+		ids[node.ID] = mermaidID(node.ID, used)
+	}
+	children := map[string][]ixNode{}
+	hasChildren := map[string]bool{}
+	for _, node := range g.Nodes {
+		children[node.ParentID] = append(children[node.ParentID], node)
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+
+	var b strings.Builder
+	if name := strings.TrimSpace(g.Name); name != "" {
+		fmt.Fprintf(&b, "---\ntitle: %s\n---\n", strings.ReplaceAll(name, "\n", " "))
+	}
+	b.WriteString("flowchart TD\n")
+	var writeNodes func(parentID, indent string)
+	writeNodes = func(parentID, indent string) {
+		for _, node := range children[parentID] {
+			if node.Group || hasChildren[node.ID] {
+				fmt.Fprintf(&b, "%ssubgraph %s[%s]\n", indent, ids[node.ID], mermaidLabel(node.Label))
+				writeNodes(node.ID, indent+"  ")
+				fmt.Fprintf(&b, "%send\n", indent)
+				continue
+			}
+			fmt.Fprintf(&b, "%s%s[%s]\n", indent, ids[node.ID], mermaidLabel(node.Label))
+		}
+	}
+	writeNodes("", "  ")
+	for _, edge := range g.Edges {
+		link := "---"
+		if edge.Directed {
+			link = "-->"
+		}
+		if edge.Label != "" {
+			link += "|" + mermaidLabel(edge.Label) + "|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[edge.Source], link, ids[edge.Target])
+	}
+	return []byte(b.String()), nil
+}
+
+// mermaidID turns a stored id into a Mermaid-safe identifier.
+func mermaidID(id string, used map[string]struct{}) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, id)
+	if safe == "" || mermaidKeywords[strings.ToLower(safe)] || (safe[0] >= '0' && safe[0] <= '9') {
+		safe = "n_" + safe
+	}
+	candidate := safe
+	for i := 2; hasKey(used, candidate); i++ {
+		candidate = safe + "_" + strconv.Itoa(i)
+	}
+	used[candidate] = struct{}{}
+	return candidate
+}
+
+// mermaidLabelEscaper writes characters Mermaid would read as markup as
+// entity codes. "#" is escaped only where it would start an entity.
+var mermaidLabelEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "&", "#amp;", "\n", "<br>")
+
+// mermaidLabel quotes a label, using Mermaid entity codes for quotes, angle
+// brackets and ampersands.
+func mermaidLabel(label string) string {
+	label = mermaidEntityPattern.ReplaceAllStringFunc(label, func(entity string) string {
+		return "#35;" + entity[1:]
+	})
+	return `"` + mermaidLabelEscaper.Replace(label) + `"`
+}
+
+func decodeMermaidText(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		text = text[1 : len(text)-1]
+	}
+	text = strings.Trim(text, "`")
+	// Markup is removed before entity codes are decoded, so escaped angle
+	// brackets come back as text rather than as tags.
+	text = mermaidBreakPattern.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, ""))
+	text = mermaidEntityPattern.ReplaceAllStringFunc(text, func(entity string) string {
+		name := entity[1 : len(entity)-1]
+		if code, err := strconv.Atoi(name); err == nil {
+			return string(rune(code))
+		}
+		return html.UnescapeString("&" + name + ";")
+	})
+	return strings.TrimSpace(text)
+}
+
+type mermaidParser struct {
+	graph   ixGraph
+	nodes   map[string]int
+	parents []string
+}
+
+func importMermaid(data []byte, _ url.Values) (ixGraph, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	p := &mermaidParser{nodes: map[string]int{}}
+
+	// Optional front matter carrying the title.
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start < len(lines) && strings.TrimSpace(lines[start]) == "---" {
+		for i := start + 1; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if line == "---" {
+				start = i + 1
+				break
+			}
+			if title, ok := strings.CutPrefix(line, "title:"); ok {
+				p.graph.Name = strings.Trim(strings.TrimSpace(title), `"'`)
+			}
+		}
+	}
+
+	direction := ""
+	for number := start; number < len(lines); number++ {
+		for _, statement := range splitMermaidStatements(lines[number]) {
+			if direction == "" {
+				match := mermaidHeaderPattern.FindStringSubmatch(statement)
+				if match == nil {
+					return ixGraph{}, importErrorf("line %d: expected a flowchart or graph header", number+1)
+				}
+				direction = strings.ToUpper(match[2])
+				if direction == "" || direction == "TD" {
+					direction = "TB"
+				}
+				continue
+			}
+			if err := p.parseStatement(statement); err != nil {
+				return ixGraph{}, importErrorf("line %d: %v", number+1, err)
+			}
+		}
+	}
+	if direction == "" {
+		return ixGraph{}, importErrorf("empty Mermaid document")
+	}
+	if len(p.parents) > 0 {
+		return ixGraph{}, importErrorf("subgraph %q is missing its end", p.parents[len(p.parents)-1])
+	}
+	p.graph.layoutLayered(direction)
+	return p.graph, nil
+}
+
+// splitMermaidStatements strips comments and splits a line on semicolons
+// outside labels.
+func splitMermaidStatements(line string) []string {
+	if strings.HasPrefix(strings.TrimSpace(line), "%%") {
+		return nil
+	}
+	var statements []string
+	depth, quoted, last := 0, false, 0
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case strings.ContainsRune("[({", r):
+			depth++
+		case strings.ContainsRune("])}", r):
+			depth = max(depth-1, 0)
+		case r == ';' && depth == 0:
+			statements = append(statements, line[last:i])
+			last = i + 1
+		}
+	}
+	statements = append(statements, line[last:])
+	kept := statements[:0]
+	for _, statement := range statements {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			kept = append(kept, statement)
+		}
+	}
+	return kept
+}
+
+func (p *mermaidParser) parseStatement(statement string) error {
+	keyword, rest, _ := strings.Cut(statement, " ")
+	switch strings.ToLower(keyword) {
+	case "subgraph":
+		p.openSubgraph(strings.TrimSpace(rest))
+		return nil
+	case "end":
+		if len(p.parents) == 0 {
+			return fmt.Errorf("end without subgraph")
+		}
+		p.parents = p.parents[:len(p.parents)-1]
+		return nil
+	case "direction", "style", "classdef", "class", "click", "linkstyle":
+		return nil
+	}
+	return p.parseChain(statement)
+}
+
+func (p *mermaidParser) openSubgraph(header string) {
+	id, title := header, header
+	if match := mermaidSubgraphPattern.FindStringSubmatch(header); match != nil {
+		id, title = match[1], match[2]
+	} else if strings.HasPrefix(header, `"`) || strings.ContainsRune(header, ' ') {
+		id = ""
+	}
+	title = decodeMermaidText(title)
+	if id == "" {
+		id = newID("group")
+	}
+	index := p.ensureNode(id)
+	node := &p.graph.Nodes[index]
+	node.Group = true
+	if title != "" {
+		node.Label = title
+	}
+	p.parents = append(p.parents, id)
+}
+
+// parseChain parses "A[x] & B --> C -->|label| D" statements.
+func (p *mermaidParser) parseChain(statement string) error {
+	rest := statement
+	var previous []string
+	var pending *mermaidLink
+	for {
+		var group []string
+		for {
+			id, remaining, err := p.parseNode(rest)
+			if err != nil {
+				return err
+			}
+			group = append(group, id)
+			rest = strings.TrimSpace(remaining)
+			if !strings.HasPrefix(rest, "&") {
+				break
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+
+		if pending != nil && !pending.invisible {
+			for _, source := range previous {
+				for _, target := range group {
+					p.graph.Edges = append(p.graph.Edges, ixEdge{Source: source, Target: target, Label: pending.label, Directed: pending.directed})
+				}
+			}
+		}
+		if rest == "" {
+			return nil
+		}
+		link, remaining, err := parseMermaidLink(rest)
+		if err != nil {
+			return err
+		}
+		pending, previous = &link, group
+		rest = strings.TrimSpace(remaining)
+		if rest == "" {
+			return fmt.Errorf("link without target")
+		}
+	}
+}
+
+// parseNode reads "id", "id[label]" (any shape), "id@{ label: ... }" and
+// an optional ":::class" suffix, returning the node id and the rest.
+func (p *mermaidParser) parseNode(text string) (string, string, error) {
+	end := 0
+	for end < len(text) {
+		r := rune(text[end])
+		if r >= 0x80 || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			end++
+			continue
+		}
+		// Hyphens and dots are allowed inside ids but not as a link start.
+		if (r == '-' || r == '.') && end > 0 && end+1 < len(text) && text[end+1] != '-' && text[end+1] != '.' && text[end+1] != '>' && !unicode.IsSpace(rune(text[end+1])) {
+			end++
+			continue
+		}
+		break
+	}
+	if end == 0 {
+		return "", "", fmt.Errorf("expected a node at %q", text)
+	}
+	id, rest := text[:end], text[end:]
+	label, hasLabel := "", false
+
+	if strings.HasPrefix(rest, "@{") {
+		closing := strings.Index(rest, "}")
+		if closing < 0 {
+			return "", "", fmt.Errorf("unterminated shape data for %q", id)
+		}
+		for _, field := range strings.Split(rest[2:closing], ",") {
+			key, value, _ := strings.Cut(field, ":")
+			if strings.TrimSpace(key) == "label" {
+				label, hasLabel = decodeMermaidText(value), true
+			}
+		}
+		rest = rest[closing+1:]
+	} else {
+		for _, shape := range mermaidShapes {
+			if !strings.HasPrefix(rest, shape.open) {
+				continue
+			}
+			body := rest[len(shape.open):]
+			var closing int
+			if strings.HasPrefix(body, `"`) {
+				quoteEnd := strings.Index(body[1:], `"`)
+				if quoteEnd < 0 {
+					return "", "", fmt.Errorf("unterminated label for %q", id)
+				}
+				closing = strings.Index(body[quoteEnd+2:], shape.close)
+				if closing >= 0 {
+					closing += quoteEnd + 2
+				}
+			} else {
+				closing = strings.Index(body, shape.close)
+			}
+			if closing < 0 {
+				continue
+			}
+			label, hasLabel = decodeMermaidText(body[:closing]), true
+			rest = body[closing+len(shape.close):]
+			break
+		}
+	}
+	if strings.HasPrefix(rest, ":::") {
+		classEnd := 3
+		for classEnd < len(rest) && !unicode.IsSpace(rune(rest[classEnd])) && rest[classEnd] != '&' && rest[classEnd] != '-' && rest[classEnd] != '=' {
+			classEnd++
+		}
+		rest = rest[classEnd:]
+	}
+
+	index := p.ensureNode(id)
+	if hasLabel && label != "" {
+		p.graph.Nodes[index].Label = label
+	}
+	return id, rest, nil
+}
+
+type mermaidLink struct {
+	label     string
+	directed  bool
+	invisible bool
+}
+
+func parseMermaidLink(text string) (mermaidLink, string, error) {
+	var link mermaidLink
+	var start, arrow string
+	if match := mermaidTextLinkPattern.FindStringSubmatch(text); match != nil {
+		end := mermaidTextLinkEnds[match[2]].FindStringSubmatchIndex(text[len(match[0])-1:])
+		if end == nil {
+			return link, "", fmt.Errorf("unterminated link text at %q", text)
+		}
+		offset := len(match[0]) - 1
+		link.label = decodeMermaidText(text[len(match[0]) : offset+end[0]])
+		closing := text[offset+end[2] : offset+end[3]]
+		start, arrow = match[1], closing[len(closing)-1:]
+		text = text[offset+end[1]:]
+	} else if match := mermaidLinkPattern.FindStringSubmatch(text); match != nil {
+		start, arrow = match[1], match[3]
+		link.invisible = strings.HasPrefix(match[2], "~")
+		text = text[len(match[0]):]
+	} else {
+		return link, "", fmt.Errorf("expected a link at %q", text)
+	}
+	link.directed = start != "" || strings.ContainsAny(arrow, ">ox")
+
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "|") {
+		closing := strings.Index(text[1:], "|")
+		if closing < 0 {
+			return link, "", fmt.Errorf("unterminated link label at %q", text)
+		}
+		link.label = decodeMermaidText(text[1 : closing+1])
+		text = text[closing+2:]
+	}
+	return link, text, nil
+}
+
+// ensureNode registers id, placing it in the innermost open subgraph unless
+// it already belongs to one.
+func (p *mermaidParser) ensureNode(id string) int {
+	parentID := ""
+	if len(p.parents) > 0 {
+		parentID = p.parents[len(p.parents)-1]
+	}
+	index, ok := p.nodes[id]
+	if !ok {
+		index = len(p.graph.Nodes)
+		p.nodes[id] = index
+		p.graph.Nodes = append(p.graph.Nodes, ixNode{ID: id, Label: id, ParentID: parentID})
+	} else if p.graph.Nodes[index].ParentID == "" && parentID != id {
+		p.graph.Nodes[index].ParentID = parentID
+	}
+	return index
+}
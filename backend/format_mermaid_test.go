@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMermaidLabelRoundTrip(t *testing.T) {
+	labels := []string{
+		"plain",
+		"a <b> & c",
+		`say "hi"`,
+		"two\nlines",
+		"literal <br> tag",
+		"C# and #quot; and #35;",
+		"&amp; stays",
+		"x > y < z",
+	}
+	for _, label := range labels {
+		t.Run(label, func(t *testing.T) {
+			g := ixGraph{
+				Nodes: []ixNode{{ID: "a", Label: label}, {ID: "b", Label: "b"}},
+				Edges: []ixEdge{{Source: "a", Target: "b", Label: label, Directed: true}},
+			}
+			data, err := exportMermaid(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			imported, err := importMermaid(data, nil)
+			if err != nil {
+				t.Fatalf("import %s: %v", data, err)
+			}
+			if len(imported.Nodes) != 2 || len(imported.Edges) != 1 {
+				t.Fatalf("imported %d nodes and %d edges from %s", len(imported.Nodes), len(imported.Edges), data)
+			}
+			if got := imported.Nodes[0].Label; got != label {
+				t.Fatalf("node label = %q, want %q (exported %s)", got, label, data)
+			}
+			if got := imported.Edges[0].Label; got != label {
+				t.Fatalf("edge label = %q, want %q (exported %s)", got, label, data)
+			}
+		})
+	}
+}
+
+func TestMermaidLabelEscapesMarkup(t *testing.T) {
+	got := mermaidLabel("a <b> & c")
+	if want := `"a #lt;b#gt; #amp; c"`; got != want {
+		t.Fatalf("mermaidLabel = %s, want %s", got, want)
+	}
+	if strings.ContainsAny(strings.Trim(got, `"`), `<>&"`) {
+		t.Fatalf("label still has markup characters: %s", got)
+	}
+}
+
+func TestMermaidRoundTrip(t *testing.T) {
+	// Mermaid has no coordinates, items or notes; imports are laid out again.
+	testExportImportRoundTrip(t, "mermaid", roundTripKeeps{edges: true})
+}
+
+func TestImportMermaidRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "mermaid", []importCase{
+		{name: "empty"},
+		{name: "no header", data: "a --> b\n"},
+		{name: "unclosed bracket", data: "flowchart TD\n  a[label\n"},
+		{name: "subgraph without end", data: "flowchart TD\n subgraph g[G]\n a --> b\n"},
+		{name: "end without subgraph", data: "flowchart TD\n a --> b\nend\n"},
+	})
+}
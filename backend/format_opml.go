@@ -0,0 +1,191 @@
+// OPML 2.0 outline import/export: graph → nodes → items → notes. Nodes and
+// groups are marked with type="node"/"group" and item notes with type="note";
+// Editor.js notes travel as Markdown in the _note attribute.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Head    struct {
+		Title string `xml:"title"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr"`
+	Type     string        `xml:"type,attr"`
+	Note     string        `xml:"_note,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+func exportOPML(g ixGraph) ([]byte, error) {
+	children := map[string][]ixNode{}
+	hasChildren := map[string]bool{}
+	for _, node := range g.Nodes {
+		children[node.ParentID] = append(children[node.ParentID], node)
+		if node.ParentID != "" {
+			hasChildren[node.ParentID] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<opml version=\"2.0\">\n  <head>\n")
+	fmt.Fprintf(&buf, "    <title>%s</title>\n", xmlEscape(g.Name))
+	fmt.Fprintf(&buf, "    <dateCreated>%s</dateCreated>\n", time.Now().UTC().Format(time.RFC1123Z))
+	buf.WriteString("  </head>\n  <body>\n")
+
+	outline := func(indent, text, outlineType, markdown string) {
+		fmt.Fprintf(&buf, "%s<outline text=\"%s\"", indent, xmlEscape(text))
+		if outlineType != "" {
+			fmt.Fprintf(&buf, " type=\"%s\"", outlineType)
+		}
+		if markdown != "" {
+			fmt.Fprintf(&buf, " _note=\"%s\"", xmlEscape(markdown))
+		}
+	}
+	var writeItems func(items []ixItem, indent string)
+	writeItems = func(items []ixItem, indent string) {
+		for _, item := range items {
+			outline(indent, item.Title, "", editorJSToMarkdown(item.ItemNotes))
+			if len(item.Notes) == 0 && len(item.Children) == 0 {
+				buf.WriteString("/>\n")
+				continue
+			}
+			buf.WriteString(">\n")
+			for _, note := range item.Notes {
+				outline(indent+"  ", note.Title, "note", "")
+				buf.WriteString("/>\n")
+			}
+			writeItems(item.Children, indent+"  ")
+			fmt.Fprintf(&buf, "%s</outline>\n", indent)
+		}
+	}
+	var writeNodes func(parentID, indent string)
+	writeNodes = func(parentID, indent string) {
+		for _, node := range children[parentID] {
+			outlineType := "node"
+			if node.Group || hasChildren[node.ID] {
+				outlineType = "group"
+			}
+			outline(indent, node.Label, outlineType, editorJSToMarkdown(node.NodeNotes))
+			if len(node.Items) == 0 && len(children[node.ID]) == 0 {
+				buf.WriteString("/>\n")
+				continue
+			}
+			buf.WriteString(">\n")
+			writeItems(node.Items, indent+"  ")
+			writeNodes(node.ID, indent+"  ")
+			fmt.Fprintf(&buf, "%s</outline>\n", indent)
+		}
+	}
+	writeNodes("", "    ")
+	buf.WriteString("  </body>\n</opml>\n")
+	return buf.Bytes(), nil
+}
+
+// importOPML maps typed outlines as exported above. Untyped outlines (from
+// other outliners) become groups above ?level= (default 1), nodes at that
+// depth and items below it.
+func importOPML(data []byte, opts url.Values) (ixGraph, error) {
+	var doc opmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return ixGraph{}, importErrorf("invalid OPML: %v", err)
+	}
+	level := 1
+	if value := opts.Get("level"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return ixGraph{}, importErrorf("level must be a positive number")
+		}
+		level = parsed
+	}
+
+	g := ixGraph{Name: strings.TrimSpace(doc.Head.Title)}
+	var toItems func(outlines []opmlOutline) []ixItem
+	toItems = func(outlines []opmlOutline) []ixItem {
+		items := []ixItem{}
+		for _, outline := range outlines {
+			item := ixItem{
+				ID:        newID("item"),
+				Title:     firstNonEmpty(outline.Text, outline.Title),
+				Notes:     []ixNote{},
+				ItemNotes: markdownToEditorJS(outline.Note),
+			}
+			var children []opmlOutline
+			for _, child := range outline.Outlines {
+				if child.Type == "note" {
+					item.Notes = append(item.Notes, ixNote{ID: newID("note"), Title: firstNonEmpty(child.Text, child.Title)})
+					continue
+				}
+				children = append(children, child)
+			}
+			item.Children = toItems(children)
+			items = append(items, item)
+		}
+		return items
+	}
+
+	// Files written by exportOPML mark every node; then only marked outlines
+	// are nodes and the rest are items.
+	var typed func(outlines []opmlOutline) bool
+	typed = func(outlines []opmlOutline) bool {
+		for _, outline := range outlines {
+			if outline.Type == "node" || outline.Type == "group" || typed(outline.Outlines) {
+				return true
+			}
+		}
+		return false
+	}
+	marked := typed(doc.Body.Outlines)
+
+	var walk func(outlines []opmlOutline, parentID string, depth int)
+	walk = func(outlines []opmlOutline, parentID string, depth int) {
+		for _, outline := range outlines {
+			outlineType := outline.Type
+			if !marked {
+				outlineType = "node"
+				if depth < level && len(outline.Outlines) > 0 {
+					outlineType = "group"
+				}
+			}
+			node := ixNode{
+				ID:        newID("node"),
+				Label:     firstNonEmpty(outline.Text, outline.Title),
+				Group:     outlineType == "group",
+				ParentID:  parentID,
+				NodeNotes: markdownToEditorJS(outline.Note),
+			}
+			var nested, items []opmlOutline
+			for _, child := range outline.Outlines {
+				if (marked && (child.Type == "node" || child.Type == "group")) || (!marked && node.Group) {
+					nested = append(nested, child)
+				} else {
+					items = append(items, child)
+				}
+			}
+			node.Items = toItems(items)
+			g.Nodes = append(g.Nodes, node)
+			walk(nested, node.ID, depth+1)
+		}
+	}
+	walk(doc.Body.Outlines, "", 1)
+	if len(g.Nodes) == 0 {
+		return ixGraph{}, importErrorf("OPML outline is empty")
+	}
+	g.layoutLayered("TB")
+	return g, nil
+}
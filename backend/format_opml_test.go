@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestOPMLRoundTrip(t *testing.T) {
+	// OPML is an outline: edges are dropped and imports are laid out again.
+	testExportImportRoundTrip(t, "opml", roundTripKeeps{items: true, notes: true})
+}
+
+func TestImportOPMLRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "opml", []importCase{
+		{name: "empty"},
+		{name: "not XML", data: "\x00\x01not a graph\xff"},
+		{name: "no outlines", data: `<opml version="2.0"><head/><body/></opml>`},
+	})
+}
@@ -0,0 +1,156 @@
+// Roam Research JSON export import: pages become nodes, block trees become
+// items, and page links and block references become edges between pages.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type roamPage struct {
+	Title      string      `json:"title"`
+	UID        string      `json:"uid"`
+	Children   []roamBlock `json:"children"`
+	CreateTime int64       `json:"create-time"`
+	EditTime   int64       `json:"edit-time"`
+}
+
+type roamBlock struct {
+	String   string      `json:"string"`
+	UID      string      `json:"uid"`
+	Children []roamBlock `json:"children"`
+}
+
+var (
+	roamPageLinkPattern  = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+	roamTagPattern       = regexp.MustCompile(`(?:^|\s)#([^\s\[\]#,.;:!?()]+)`)
+	roamAttributePattern = regexp.MustCompile(`^([^:\n\[\]]+)::`)
+	roamBlockRefPattern  = regexp.MustCompile(`\(\(([\w-]+)\)\)`)
+)
+
+func importRoam(data []byte, opts url.Values) (ixGraph, error) {
+	return importRoamProgress(data, opts, nil)
+}
+
+// importRoamProgress converts a Roam export (a JSON array of pages). Block
+// references are shown with the referenced block's text. Links to pages that
+// are not in the export are skipped. Page create/edit times are kept in
+// data.metadata. progress, when not nil, is called after each page.
+func importRoamProgress(data []byte, _ url.Values, progress func(done, total int)) (ixGraph, error) {
+	var pages []roamPage
+	if err := json.Unmarshal(data, &pages); err != nil {
+		return ixGraph{}, importErrorf("invalid Roam JSON: %v", err)
+	}
+	if len(pages) == 0 {
+		return ixGraph{}, importErrorf("Roam export has no pages")
+	}
+
+	g := ixGraph{Name: "Roam import", Nodes: make([]ixNode, 0, len(pages))}
+	pageNodes := make(map[string]string, len(pages))
+	blockNodes := map[string]string{}
+	blockText := map[string]string{}
+	var indexBlocks func(blocks []roamBlock, nodeID string)
+	indexBlocks = func(blocks []roamBlock, nodeID string) {
+		for _, block := range blocks {
+			if block.UID != "" {
+				blockNodes[block.UID] = nodeID
+				blockText[block.UID], _, _ = strings.Cut(strings.TrimSpace(block.String), "\n")
+			}
+			indexBlocks(block.Children, nodeID)
+		}
+	}
+	for _, page := range pages {
+		node := ixNode{
+			ID:       newID("node"),
+			Label:    strings.TrimSpace(page.Title),
+			Metadata: roamMetadata(page),
+		}
+		// Titles are unique in Roam; a repeated one keeps the first page.
+		if _, ok := pageNodes[node.Label]; !ok {
+			pageNodes[node.Label] = node.ID
+		}
+		indexBlocks(page.Children, node.ID)
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	seen := map[[2]string]struct{}{}
+	missing := 0
+	link := func(source, target string) {
+		if target == "" {
+			missing++
+			return
+		}
+		key := [2]string{source, target}
+		if _, ok := seen[key]; ok || source == target {
+			return
+		}
+		seen[key] = struct{}{}
+		g.Edges = append(g.Edges, ixEdge{Source: source, Target: target, Directed: true})
+	}
+	var toItems func(blocks []roamBlock, nodeID string) []ixItem
+	toItems = func(blocks []roamBlock, nodeID string) []ixItem {
+		items := make([]ixItem, 0, len(blocks))
+		for _, block := range blocks {
+			text := strings.TrimSpace(block.String)
+			for _, match := range roamPageLinkPattern.FindAllStringSubmatch(text, -1) {
+				link(nodeID, pageNodes[strings.TrimSpace(match[1])])
+			}
+			for _, match := range roamTagPattern.FindAllStringSubmatch(text, -1) {
+				link(nodeID, pageNodes[match[1]])
+			}
+			if match := roamAttributePattern.FindStringSubmatch(text); match != nil {
+				link(nodeID, pageNodes[strings.TrimSpace(match[1])])
+			}
+			text = roamBlockRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+				uid := roamBlockRefPattern.FindStringSubmatch(ref)[1]
+				link(nodeID, blockNodes[uid])
+				if resolved, ok := blockText[uid]; ok {
+					return resolved
+				}
+				return ref
+			})
+
+			title, rest, _ := strings.Cut(text, "\n")
+			items = append(items, ixItem{
+				ID:        newID("item"),
+				Title:     strings.TrimSpace(title),
+				Notes:     []ixNote{},
+				ItemNotes: markdownToEditorJS(strings.TrimSpace(rest)),
+				Children:  toItems(block.Children, nodeID),
+			})
+		}
+		return items
+	}
+	for i := range g.Nodes {
+		g.Nodes[i].Items = toItems(pages[i].Children, g.Nodes[i].ID)
+		if progress != nil {
+			progress(i+1, len(pages))
+		}
+	}
+	if missing > 0 {
+		g.Warnings = append(g.Warnings, fmt.Sprintf("skipped %d links to pages or blocks that are not in the export", missing))
+	}
+
+	g.layoutLayered("TB")
+	return g, nil
+}
+
+// roamMetadata records where a page came from and when it was created and
+// last edited (Roam stores milliseconds since the epoch).
+func roamMetadata(page roamPage) map[string]any {
+	metadata := map[string]any{"source": "roam"}
+	if page.UID != "" {
+		metadata["sourceId"] = page.UID
+	}
+	if page.CreateTime > 0 {
+		metadata["createdAt"] = time.UnixMilli(page.CreateTime).UTC().Format(time.RFC3339)
+	}
+	if page.EditTime > 0 {
+		metadata["updatedAt"] = time.UnixMilli(page.EditTime).UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
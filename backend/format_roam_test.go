@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestImportRoamRejectsMalformedInput(t *testing.T) {
+	testImportRejects(t, "roam", []importCase{
+		{name: "empty"},
+		{name: "object", data: `{}`},
+		{name: "no pages", data: `[]`},
+		{name: "page not an object", data: `[1,2]`},
+		{name: "children not an array", data: `[{"title":"a","children":{}}]`},
+	})
+}
+
+func TestImportRoamRepairsInconsistentInput(t *testing.T) {
+	testImportRepairs(t, "roam", []importCase{
+		{name: "untitled pages", data: `[{"title":""},{"title":""}]`},
+		{name: "unknown references", data: `[{"title":"a","children":[{"string":"[[missing]] ((nope))","uid":"x"}]}]`},
+	})
+}
@@ -0,0 +1,160 @@
+// XMind import: a zip with content.json (XMind Zen / 2020 and later) or
+// content.xml (XMind 8). One sheet is imported, chosen with ?sheet=.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+type xmindSheet struct {
+	Title         string     `json:"title"`
+	RootTopic     xmindTopic `json:"rootTopic"`
+	Relationships []struct {
+		End1ID string `json:"end1Id"`
+		End2ID string `json:"end2Id"`
+		Title  string `json:"title"`
+	} `json:"relationships"`
+}
+
+type xmindTopic struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Notes struct {
+		Plain struct {
+			Content string `json:"content"`
+		} `json:"plain"`
+	} `json:"notes"`
+	Children struct {
+		Attached []xmindTopic `json:"attached"`
+		Detached []xmindTopic `json:"detached"`
+	} `json:"children"`
+}
+
+// XMind 8 content.xml. Elements are matched by local name, so the xmap
+// namespace does not need to be spelled out.
+type xmindXMLContent struct {
+	Sheets []xmindXMLSheet `xml:"sheet"`
+}
+
+type xmindXMLSheet struct {
+	Title         string        `xml:"title"`
+	Topic         xmindXMLTopic `xml:"topic"`
+	Relationships []struct {
+		End1  string `xml:"end1,attr"`
+		End2  string `xml:"end2,attr"`
+		Title string `xml:"title"`
+	} `xml:"relationships>relationship"`
+}
+
+type xmindXMLTopic struct {
+	ID     string `xml:"id,attr"`
+	Title  string `xml:"title"`
+	Notes  string `xml:"notes>plain"`
+	Topics []struct {
+		Type   string          `xml:"type,attr"`
+		Topics []xmindXMLTopic `xml:"topic"`
+	} `xml:"children>topics"`
+}
+
+// importXMind reads the sheet at ?sheet= (1-based, default 1). Detached
+// (floating) topics of the central topic are laid out as separate trees.
+func importXMind(data []byte, opts url.Values) (ixGraph, error) {
+	files, err := readZipArchive(data)
+	if err != nil {
+		return ixGraph{}, err
+	}
+	contents := map[string][]byte{}
+	for _, file := range files {
+		contents[file.Name] = file.Data
+	}
+
+	sheet := 1
+	if value := opts.Get("sheet"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return ixGraph{}, importErrorf("sheet must be a positive number")
+		}
+		sheet = parsed
+	}
+
+	var m mindMap
+	var sheets int
+	if content, ok := contents["content.json"]; ok {
+		var doc []xmindSheet
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return ixGraph{}, importErrorf("invalid XMind content.json: %v", err)
+		}
+		sheets = len(doc)
+		if sheet > sheets {
+			return ixGraph{}, importErrorf("XMind file has %d sheets", sheets)
+		}
+		m = xmindJSONMap(doc[sheet-1])
+	} else if content, ok := contents["content.xml"]; ok {
+		var doc xmindXMLContent
+		if err := xml.Unmarshal(content, &doc); err != nil {
+			return ixGraph{}, importErrorf("invalid XMind content.xml: %v", err)
+		}
+		sheets = len(doc.Sheets)
+		if sheet > sheets {
+			return ixGraph{}, importErrorf("XMind file has %d sheets", sheets)
+		}
+		m = xmindXMLMap(doc.Sheets[sheet-1])
+	} else {
+		return ixGraph{}, importErrorf("XMind file has no content.json or content.xml")
+	}
+	if sheets > 1 {
+		m.Warnings = append(m.Warnings, fmt.Sprintf("imported sheet %d of %d", sheet, sheets))
+	}
+	return m.graph(opts)
+}
+
+func xmindJSONMap(sheet xmindSheet) mindMap {
+	var toTopic func(topic xmindTopic) mindTopic
+	toTopic = func(topic xmindTopic) mindTopic {
+		converted := mindTopic{ID: topic.ID, Title: topic.Title, Notes: topic.Notes.Plain.Content}
+		for _, child := range topic.Children.Attached {
+			converted.Children = append(converted.Children, toTopic(child))
+		}
+		return converted
+	}
+	m := mindMap{Name: sheet.Title, Roots: []mindTopic{toTopic(sheet.RootTopic)}}
+	for _, topic := range sheet.RootTopic.Children.Detached {
+		m.Roots = append(m.Roots, toTopic(topic))
+	}
+	for _, relationship := range sheet.Relationships {
+		m.Links = append(m.Links, mindLink{From: relationship.End1ID, To: relationship.End2ID, Label: relationship.Title})
+	}
+	return m
+}
+
+func xmindXMLMap(sheet xmindXMLSheet) mindMap {
+	var toTopic func(topic xmindXMLTopic) mindTopic
+	toTopic = func(topic xmindXMLTopic) mindTopic {
+		converted := mindTopic{ID: topic.ID, Title: topic.Title, Notes: topic.Notes}
+		for _, group := range topic.Topics {
+			if group.Type != "" && group.Type != "attached" {
+				continue
+			}
+			for _, child := range group.Topics {
+				converted.Children = append(converted.Children, toTopic(child))
+			}
+		}
+		return converted
+	}
+	m := mindMap{Name: sheet.Title, Roots: []mindTopic{toTopic(sheet.Topic)}}
+	for _, group := range sheet.Topic.Topics {
+		if group.Type == "detached" {
+			for _, topic := range group.Topics {
+				m.Roots = append(m.Roots, toTopic(topic))
+			}
+		}
+	}
+	for _, relationship := range sheet.Relationships {
+		m.Links = append(m.Links, mindLink{From: relationship.End1, To: relationship.End2, Label: relationship.Title})
+	}
+	return m
+}
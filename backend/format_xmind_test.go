@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestImportXMindRejectsMalformedInput(t *testing.T) {
+	oneSheet := testZip(t, map[string]string{"content.json": `[{"title":"s","rootTopic":{"id":"r","title":"R"}}]`})
+	testImportRejects(t, "xmind", []importCase{
+		{name: "empty"},
+		{name: "not a zip", data: "\x00\x01not a graph\xff"},
+		{name: "no content", data: testZip(t, map[string]string{"meta.json": "{}"})},
+		{name: "invalid content.json", data: testZip(t, map[string]string{"content.json": "{"})},
+		{name: "invalid content.xml", data: testZip(t, map[string]string{"content.xml": "<xmap-content><sheet>"})},
+		{name: "no sheets", data: testZip(t, map[string]string{"content.json": `[]`})},
+		{name: "sheet out of range", data: oneSheet, query: url.Values{"sheet": {"2"}}},
+		{name: "bad sheet number", data: oneSheet, query: url.Values{"sheet": {"x"}}},
+	})
+}
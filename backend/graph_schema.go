@@ -0,0 +1,448 @@
+// Authoritative JSON Schema for GraphPayload: served to clients, used to
+// validate saves and imports, and narrowed into the AI output schema.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// graphSchemaVersion is bumped whenever the schema changes in a way that
+// rejects payloads an earlier version accepted.
+const graphSchemaVersion = 1
+
+const maxSchemaViolations = 20
+
+// graphPayloadSchema describes what the frontend stores (graphTypes.ts).
+// Unknown properties are allowed everywhere so React Flow state (selected,
+// width, zIndex, ...) survives a round trip; only known fields are checked.
+func graphPayloadSchema() map[string]any {
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "/api/schema/graph.json",
+		"title":   "GraphPayload",
+		"version": graphSchemaVersion,
+		"type":    "object",
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+			"kind": map[string]any{
+				"type":     "string",
+				"examples": []string{"note", "application", "graph3d"},
+			},
+			"nodes": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"$ref": "#/$defs/node"},
+			},
+			"edges": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"$ref": "#/$defs/edge"},
+			},
+		},
+		"required": []string{"name", "nodes", "edges"},
+		"$defs": map[string]any{
+			"node": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":   map[string]any{"type": "string", "minLength": 1},
+					"type": map[string]any{"type": "string"},
+					"position": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"x": map[string]any{"type": "number"},
+							"y": map[string]any{"type": "number"},
+						},
+						"required": []string{"x", "y"},
+					},
+					"parentNode": map[string]any{"type": []string{"string", "null"}},
+					"extent":     map[string]any{"type": []string{"string", "array", "null"}},
+					"style": map[string]any{
+						"type": []string{"object", "null"},
+						"properties": map[string]any{
+							// CSS sizes such as "240px" are kept as strings.
+							"width":  map[string]any{"type": []string{"number", "string"}},
+							"height": map[string]any{"type": []string{"number", "string"}},
+						},
+					},
+					"width":  map[string]any{"type": []string{"number", "null"}},
+					"height": map[string]any{"type": []string{"number", "null"}},
+					"data":   map[string]any{"$ref": "#/$defs/nodeData"},
+				},
+				"required": []string{"id", "position", "data"},
+			},
+			"nodeData": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"label": map[string]any{"type": "string"},
+					"items": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"$ref": "#/$defs/item"},
+					},
+					"nodeNotes": map[string]any{
+						"type":        "string",
+						"description": "Editor.js JSON document",
+					},
+					"position3d": map[string]any{
+						"type": []string{"object", "null"},
+						"properties": map[string]any{
+							"x": map[string]any{"type": "number"},
+							"y": map[string]any{"type": "number"},
+							"z": map[string]any{"type": "number"},
+						},
+						"required": []string{"x", "y", "z"},
+					},
+					"progress":   map[string]any{"type": []string{"number", "null"}},
+					"scriptName": map[string]any{"type": "string"},
+					"metadata": map[string]any{
+						"type":        "object",
+						"description": "Import metadata such as source IDs and timestamps",
+					},
+					"links": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"$ref": "#/$defs/nodeLink"},
+					},
+				},
+				"required": []string{"label"},
+			},
+			"nodeLink": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"graphId": map[string]any{"type": "string"},
+					"nodeId":  map[string]any{"type": "string", "minLength": 1},
+				},
+				"required": []string{"graphId", "nodeId"},
+			},
+			"item": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":    map[string]any{"type": "string", "minLength": 1},
+					"title": map[string]any{"type": "string"},
+					"notes": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"$ref": "#/$defs/note"},
+					},
+					"itemNotes": map[string]any{
+						"type":        "string",
+						"description": "Editor.js JSON document",
+					},
+					"children": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"$ref": "#/$defs/item"},
+					},
+				},
+				"required": []string{"id", "title"},
+			},
+			"note": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":    map[string]any{"type": "string", "minLength": 1},
+					"title": map[string]any{"type": "string"},
+				},
+				"required": []string{"id", "title"},
+			},
+			"edge": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":           map[string]any{"type": "string", "minLength": 1},
+					"source":       map[string]any{"type": "string", "minLength": 1},
+					"target":       map[string]any{"type": "string", "minLength": 1},
+					"type":         map[string]any{"type": "string"},
+					"label":        map[string]any{"type": []string{"string", "null"}},
+					"sourceHandle": map[string]any{"type": []string{"string", "null"}},
+					"targetHandle": map[string]any{"type": []string{"string", "null"}},
+					"markerEnd":    map[string]any{"type": []string{"string", "object", "null"}},
+					"data": map[string]any{
+						"type": []string{"object", "null"},
+						"properties": map[string]any{
+							"directed": map[string]any{"type": "boolean"},
+						},
+					},
+				},
+				"required": []string{"id", "source", "target"},
+			},
+		},
+	}
+}
+
+// storedGraphSchema is the parsed schema used for validation.
+var storedGraphSchema = graphPayloadSchema()
+
+// schemaViolation is one place where a payload does not match the schema;
+// Path is a JSON Pointer.
+type schemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// invalidGraphError rejects a save or import that does not match the
+// GraphPayload schema. Nothing is written when it is returned.
+type invalidGraphError struct {
+	Violations []schemaViolation
+}
+
+func (e *invalidGraphError) Error() string {
+	if len(e.Violations) == 0 {
+		return "graph does not match the schema"
+	}
+	first := e.Violations[0]
+	return fmt.Sprintf("graph does not match the schema: %s: %s", firstNonEmpty(first.Path, "/"), first.Message)
+}
+
+// writeInvalidGraph writes a 422 for invalidGraphError and reports whether it did.
+func writeInvalidGraph(w http.ResponseWriter, err error) bool {
+	var graphErr *invalidGraphError
+	if !errors.As(err, &graphErr) {
+		return false
+	}
+	writeJSONStatus(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":         "invalid_graph",
+		"message":       "graph does not match the GraphPayload schema",
+		"schema":        "/api/schema/graph.json",
+		"schemaVersion": graphSchemaVersion,
+		"violations":    graphErr.Violations,
+	})
+	return true
+}
+
+// validateGraphData checks an encoded GraphPayload against the schema and
+// returns *invalidGraphError when it does not match.
+func validateGraphData(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &invalidGraphError{Violations: []schemaViolation{{Path: "", Message: "invalid JSON"}}}
+	}
+	v := schemaValidator{root: storedGraphSchema}
+	v.validate(storedGraphSchema, value, "")
+	if len(v.violations) > 0 {
+		return &invalidGraphError{Violations: v.violations}
+	}
+	return nil
+}
+
+// schemaValidator implements the JSON Schema keywords graphPayloadSchema
+// uses: $ref into $defs, type, properties, required, items and minLength.
+type schemaValidator struct {
+	root       map[string]any
+	violations []schemaViolation
+}
+
+func (v *schemaValidator) fail(path, format string, args ...any) {
+	if len(v.violations) < maxSchemaViolations {
+		v.violations = append(v.violations, schemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (v *schemaValidator) validate(schema map[string]any, value any, path string) {
+	if len(v.violations) >= maxSchemaViolations {
+		return
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		defs, _ := v.root["$defs"].(map[string]any)
+		target, _ := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if target == nil {
+			v.fail(path, "unknown schema reference %s", ref)
+			return
+		}
+		schema = target
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonTypeOf(value)
+		if !slices.Contains(types, actual) {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), actual)
+			return
+		}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		if minLength, ok := schema["minLength"].(int); ok && len([]rune(typed)) < minLength {
+			v.fail(path, "must not be empty")
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, entry := range typed {
+				v.validate(items, entry, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+	case map[string]any:
+		required, _ := schema["required"].([]string)
+		for _, key := range required {
+			if _, ok := typed[key]; !ok {
+				v.fail(path+"/"+schemaPointerEscape(key), "is required")
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		// Sorted so the reported violations are stable.
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			entry, ok := typed[key]
+			if !ok {
+				continue
+			}
+			if property, ok := properties[key].(map[string]any); ok {
+				v.validate(property, entry, path+"/"+schemaPointerEscape(key))
+			}
+		}
+	}
+}
+
+func schemaTypes(value any) []string {
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}
+	case []string:
+		return typed
+	}
+	return nil
+}
+
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+func schemaPointerEscape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// aiOmittedProperties lists schema properties the AI is not asked to
+// produce, by $defs name ("" is the root). The server fills them in or the
+// editor manages them.
+var aiOmittedProperties = map[string][]string{
+	"":         {"kind"},
+	"node":     {"extent", "width", "height"},
+	"nodeData": {"nodeNotes", "progress", "scriptName", "links", "metadata"},
+	"item":     {"itemNotes", "children"},
+	"edge":     {"sourceHandle", "targetHandle", "markerEnd"},
+}
+
+// aiGraphSchema narrows graphPayloadSchema to the strict form OpenAI
+// structured output requires: $refs are inlined, every object is closed and
+// lists all of its properties as required, and optional properties become
+// nullable.
+func aiGraphSchema() map[string]any {
+	schema := graphPayloadSchema()
+	defs, _ := schema["$defs"].(map[string]any)
+	var convert func(node map[string]any, defName string, stack []string) map[string]any
+	convert = func(node map[string]any, defName string, stack []string) map[string]any {
+		if ref, ok := node["$ref"].(string); ok {
+			name := strings.TrimPrefix(ref, "#/$defs/")
+			target, _ := defs[name].(map[string]any)
+			if target == nil || slices.Contains(stack, name) {
+				return nil
+			}
+			return convert(target, name, append(stack, name))
+		}
+		out := map[string]any{}
+		for key, value := range node {
+			switch key {
+			case "$schema", "$id", "$defs", "title", "version", "examples", "description", "minLength":
+				continue
+			}
+			out[key] = value
+		}
+		if items, ok := node["items"].(map[string]any); ok {
+			if converted := convert(items, "", stack); converted != nil {
+				out["items"] = converted
+			}
+		}
+		properties, ok := node["properties"].(map[string]any)
+		if !ok {
+			return out
+		}
+		required, _ := node["required"].([]string)
+		convertedProperties := map[string]any{}
+		var names []string
+		for key, value := range properties {
+			if slices.Contains(aiOmittedProperties[defName], key) {
+				continue
+			}
+			property, _ := value.(map[string]any)
+			converted := convert(property, "", stack)
+			if converted == nil {
+				continue
+			}
+			if !slices.Contains(required, key) {
+				converted = schemaNullable(converted)
+			}
+			convertedProperties[key] = converted
+			names = append(names, key)
+		}
+		slices.Sort(names)
+		out["properties"] = convertedProperties
+		out["required"] = names
+		out["additionalProperties"] = false
+		return out
+	}
+	converted := convert(schema, "", nil)
+	// Generated nodes get numeric sizes only (aiNodeStyle).
+	style := schemaProperty(converted, "nodes", "[]", "style")
+	for _, key := range []string{"width", "height"} {
+		if size := schemaProperty(style, key); size != nil {
+			size["type"] = []string{"number", "null"}
+		}
+	}
+	return converted
+}
+
+// schemaProperty follows property names ("[]" for array items) from schema
+// and returns the nested schema, or nil when the path does not exist.
+func schemaProperty(schema map[string]any, path ...string) map[string]any {
+	for _, key := range path {
+		if schema == nil {
+			return nil
+		}
+		if key == "[]" {
+			schema, _ = schema["items"].(map[string]any)
+			continue
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		schema, _ = properties[key].(map[string]any)
+	}
+	return schema
+}
+
+// schemaNullable adds "null" to a schema's type.
+func schemaNullable(schema map[string]any) map[string]any {
+	types := schemaTypes(schema["type"])
+	if len(types) == 0 || slices.Contains(types, "null") {
+		return schema
+	}
+	schema["type"] = append(slices.Clone(types), "null")
+	return schema
+}
+
+// GET /api/schema/graph.json: the GraphPayload schema enforced on saves.
+func (s *server) handleGraphSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("X-Schema-Version", fmt.Sprint(graphSchemaVersion))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(storedGraphSchema)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateGraphDataAcceptsCSSStyleSizes(t *testing.T) {
+	data := `{"name":"G","nodes":[{"id":"a","position":{"x":0,"y":0},"style":{"width":"240px","height":120},"data":{"label":"A"}}],"edges":[]}`
+	if err := validateGraphData([]byte(data)); err != nil {
+		t.Fatalf("string style sizes rejected: %v", err)
+	}
+	data = `{"name":"G","nodes":[{"id":"a","position":{"x":0,"y":0},"style":{"width":true},"data":{"label":"A"}}],"edges":[]}`
+	if err := validateGraphData([]byte(data)); err == nil {
+		t.Fatal("boolean style width accepted")
+	}
+}
+
+func TestAIGraphSchemaKeepsNumericStyleSizes(t *testing.T) {
+	style := schemaProperty(aiGraphSchema(), "nodes", "[]", "style")
+	if style == nil {
+		t.Fatal("AI schema has no node style")
+	}
+	for _, key := range []string{"width", "height"} {
+		if got := schemaProperty(style, key)["type"]; !reflect.DeepEqual(got, []string{"number", "null"}) {
+			t.Fatalf("AI style %s type = %v, want number or null", key, got)
+		}
+	}
+}
+
+func TestValidateGraphData(t *testing.T) {
+	const node = `{"id":"a","position":{"x":0,"y":0},"data":{"label":"A"}}`
+	tests := []struct {
+		name string
+		data string
+		path string // path of the first violation
+		ok   bool
+	}{
+		{name: "minimal", data: `{"name":"G","nodes":[],"edges":[]}`, ok: true},
+		{name: "node and edge", data: `{"name":"G","nodes":[` + node + `],"edges":[{"id":"e","source":"a","target":"a"}]}`, ok: true},
+		{name: "unknown fields", data: `{"name":"G","nodes":[],"edges":[],"viewport":{"zoom":1}}`, ok: true},
+		{name: "not JSON", data: `{"name":`, path: ""},
+		{name: "not an object", data: `[]`, path: ""},
+		{name: "missing nodes", data: `{"name":"G","edges":[]}`, path: "/nodes"},
+		{name: "nodes not an array", data: `{"name":"G","nodes":{},"edges":[]}`, path: "/nodes"},
+		{name: "node without id", data: `{"name":"G","nodes":[{"position":{"x":0,"y":0},"data":{"label":"A"}}],"edges":[]}`, path: "/nodes/0/id"},
+		{name: "node without label", data: `{"name":"G","nodes":[{"id":"a","position":{"x":0,"y":0},"data":{}}],"edges":[]}`, path: "/nodes/0/data/label"},
+		{name: "string position", data: `{"name":"G","nodes":[{"id":"a","position":{"x":"0","y":0},"data":{"label":"A"}}],"edges":[]}`, path: "/nodes/0/position/x"},
+		{name: "edge without target", data: `{"name":"G","nodes":[` + node + `],"edges":[{"id":"e","source":"a"}]}`, path: "/edges/0/target"},
+		{name: "numeric item title", data: `{"name":"G","nodes":[{"id":"a","position":{"x":0,"y":0},"data":{"label":"A","items":[{"id":"i","title":1}]}}],"edges":[]}`, path: "/nodes/0/data/items/0/title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGraphData([]byte(tt.data))
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("valid graph rejected: %v", err)
+				}
+				return
+			}
+			var graphErr *invalidGraphError
+			if !errors.As(err, &graphErr) {
+				t.Fatalf("err = %v, want an invalid graph error", err)
+			}
+			if got := graphErr.Violations[0].Path; got != tt.path {
+				t.Fatalf("first violation at %q, want %q: %+v", got, tt.path, graphErr.Violations)
+			}
+		})
+	}
+}
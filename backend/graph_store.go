@@ -0,0 +1,165 @@
+// Shared persistence helpers for graph rows.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx so helpers can run
+// inside or outside a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// upsertGraph stores the encoded payload, its extracted node notes and its
+// indexes (see indexGraph). The payload is checked against the schema
+// (*invalidGraphError) and new links are validated (*invalidLinksError) first.
+func upsertGraph(ctx context.Context, q querier, id, userID string, payload graphPayload, data []byte) (time.Time, error) {
+	if err := validateGraphData(data); err != nil {
+		return time.Time{}, err
+	}
+	links := extractNodeLinks(id, payload.Nodes)
+	if err := checkNodeLinks(ctx, q, userID, id, payload.Nodes, links); err != nil {
+		return time.Time{}, err
+	}
+
+	var updatedAt time.Time
+	err := q.QueryRow(
+		ctx,
+		`INSERT INTO graphs (id, user_id, name, kind, data, node_notes, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now())
+		 ON CONFLICT (id) DO UPDATE
+		 SET name = EXCLUDED.name, kind = EXCLUDED.kind, data = EXCLUDED.data, node_notes = EXCLUDED.node_notes, updated_at = now()
+		 RETURNING updated_at`,
+		id,
+		userID,
+		payload.Name,
+		payload.Kind,
+		data,
+		extractNodeNotes(payload.Nodes),
+	).Scan(&updatedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return updatedAt, indexGraph(ctx, q, userID, id, payload.Nodes, links)
+}
+
+// createGraph inserts payload as a new graph after applying defaults and the
+// caller's quota. A non-nil quotaError means nothing was stored; the payload
+// and its links are validated as in upsertGraph.
+func createGraph(ctx context.Context, q querier, userID, plan string, limits planLimits, payload graphPayload) (graphSummary, *quotaError, error) {
+	normalizeGraphPayload(&payload)
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return graphSummary{}, nil, err
+	}
+	if err := validateGraphData(data); err != nil {
+		return graphSummary{}, nil, err
+	}
+
+	id, err := generateID()
+	if err != nil {
+		return graphSummary{}, nil, err
+	}
+
+	quotaErr, err := checkGraphQuota(ctx, q, userID, id, plan, limits, payload, len(data))
+	if err != nil || quotaErr != nil {
+		return graphSummary{}, quotaErr, err
+	}
+
+	links := extractNodeLinks(id, payload.Nodes)
+	if err := checkNodeLinks(ctx, q, userID, id, payload.Nodes, links); err != nil {
+		return graphSummary{}, nil, err
+	}
+
+	var updatedAt time.Time
+	err = q.QueryRow(
+		ctx,
+		`INSERT INTO graphs (id, user_id, name, kind, data, node_notes, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now())
+		 RETURNING updated_at`,
+		id,
+		userID,
+		payload.Name,
+		payload.Kind,
+		data,
+		extractNodeNotes(payload.Nodes),
+	).Scan(&updatedAt)
+	if err != nil {
+		return graphSummary{}, nil, err
+	}
+	if err := indexGraph(ctx, q, userID, id, payload.Nodes, links); err != nil {
+		return graphSummary{}, nil, err
+	}
+
+	return graphSummary{
+		ID:        id,
+		Name:      payload.Name,
+		UpdatedAt: updatedAt,
+	}, nil, nil
+}
+
+// createGraphTx runs createGraph in its own transaction, so the graph row and
+// its indexes are stored together or not at all.
+func (s *server) createGraphTx(ctx context.Context, userID, plan string, limits planLimits, payload graphPayload) (graphSummary, *quotaError, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return graphSummary{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	summary, quotaErr, err := createGraph(ctx, tx, userID, plan, limits, payload)
+	if err != nil || quotaErr != nil {
+		return graphSummary{}, quotaErr, err
+	}
+	return summary, nil, tx.Commit(ctx)
+}
+
+// indexGraph rebuilds the per-graph lookup tables derived from node data
+// (cross-graph links and wiki-links) after a save and drops comment threads
+// on removed nodes.
+func indexGraph(ctx context.Context, q querier, userID, id string, nodes json.RawMessage, links []nodeLinkRow) error {
+	if err := replaceNodeLinks(ctx, q, userID, id, links); err != nil {
+		return err
+	}
+	if err := replaceWikiLinks(ctx, q, userID, id, nodes); err != nil {
+		return err
+	}
+	return pruneComments(ctx, q, userID, id, nodes)
+}
+
+// deleteGraphDependents removes rows keyed by deleted graphs (attachments,
+// comments, link indexes and sync client ids). Returned keys must be passed to deleteBlobs
+// once the change is committed.
+func deleteGraphDependents(ctx context.Context, q querier, userID string, graphIDs []string) ([]string, error) {
+	for _, statement := range []string{
+		"DELETE FROM graph_comments WHERE user_id = $1 AND graph_id = ANY($2)",
+		"DELETE FROM node_links WHERE user_id = $1 AND source_graph_id = ANY($2)",
+		"DELETE FROM graph_wikilinks WHERE user_id = $1 AND graph_id = ANY($2)",
+		"DELETE FROM graph_client_ids WHERE user_id = $1 AND graph_id = ANY($2)",
+	} {
+		if _, err := q.Exec(ctx, statement, userID, graphIDs); err != nil {
+			return nil, err
+		}
+	}
+	return deleteGraphAttachments(ctx, q, userID, graphIDs)
+}
+
+// normalizeGraphPayload fills the defaults applied to newly stored graphs.
+func normalizeGraphPayload(payload *graphPayload) {
+	if strings.TrimSpace(payload.Name) == "" {
+		payload.Name = "Untitled Graph"
+	}
+	if strings.TrimSpace(payload.Kind) == "" {
+		payload.Kind = "note"
+	}
+}
@@ -0,0 +1,293 @@
+// HTTP handler for transactional bulk operations on a user's graphs.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxBulkGraphs = 500
+
+	bulkOpDelete = "delete"
+	bulkOpKind   = "kind"
+	bulkOpRename = "rename"
+	bulkOpTag    = "tag"
+	bulkOpMove   = "move"
+)
+
+// bulkRequest applies one operation to every listed graph.
+type bulkRequest struct {
+	IDs []string `json:"ids"`
+	Op  string   `json:"op"`
+	// Kind is the target kind for "kind".
+	Kind string `json:"kind,omitempty"`
+	// Pattern (Go regexp) and Replacement drive "rename".
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// AddTags/RemoveTags drive "tag".
+	AddTags    []string `json:"addTags,omitempty"`
+	RemoveTags []string `json:"removeTags,omitempty"`
+	// Folder is the destination for "move"; empty moves graphs to the root.
+	Folder string `json:"folder,omitempty"`
+}
+
+type bulkResult struct {
+	ID     string   `json:"id"`
+	Status string   `json:"status"`
+	Name   string   `json:"name,omitempty"`
+	Kind   string   `json:"kind,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Folder string   `json:"folder,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type bulkResponse struct {
+	// Applied is false when any item failed; the transaction is then rolled back.
+	Applied bool         `json:"applied"`
+	Results []bulkResult `json:"results"`
+}
+
+type bulkGraphRow struct {
+	name   string
+	kind   string
+	tags   []string
+	folder string
+}
+
+// POST /api/graphs/bulk: all-or-nothing batch update limited to the caller's graphs.
+func (s *server) handleBulkGraphs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var req bulkRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]string, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		id = strings.TrimSpace(id)
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "ids are required", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > maxBulkGraphs {
+		http.Error(w, "too many graphs in one request", http.StatusBadRequest)
+		return
+	}
+
+	var pattern *regexp.Regexp
+	switch req.Op {
+	case bulkOpDelete, bulkOpMove:
+	case bulkOpKind:
+		req.Kind = strings.TrimSpace(req.Kind)
+		if req.Kind == "" {
+			http.Error(w, "kind is required", http.StatusBadRequest)
+			return
+		}
+	case bulkOpRename:
+		if req.Pattern == "" {
+			http.Error(w, "pattern is required", http.StatusBadRequest)
+			return
+		}
+		pattern, err = regexp.Compile(req.Pattern)
+		if err != nil {
+			http.Error(w, "invalid pattern", http.StatusBadRequest)
+			return
+		}
+	case bulkOpTag:
+		req.AddTags = normalizeTags(req.AddTags)
+		req.RemoveTags = normalizeTags(req.RemoveTags)
+		if len(req.AddTags) == 0 && len(req.RemoveTags) == 0 {
+			http.Error(w, "addTags or removeTags is required", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "invalid op", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin bulk update: %v", err)
+		http.Error(w, "failed to update graphs", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT id, name, kind, tags, folder
+		 FROM graphs
+		 WHERE user_id = $1 AND id = ANY($2)
+		 FOR UPDATE`,
+		userID,
+		ids,
+	)
+	if err != nil {
+		log.Printf("failed to lock graphs: %v", err)
+		http.Error(w, "failed to update graphs", http.StatusInternalServerError)
+		return
+	}
+	owned := make(map[string]bulkGraphRow, len(ids))
+	for rows.Next() {
+		var id string
+		var row bulkGraphRow
+		if err := rows.Scan(&id, &row.name, &row.kind, &row.tags, &row.folder); err != nil {
+			rows.Close()
+			log.Printf("failed to scan graph: %v", err)
+			http.Error(w, "failed to update graphs", http.StatusInternalServerError)
+			return
+		}
+		owned[id] = row
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to lock graphs: %v", err)
+		http.Error(w, "failed to update graphs", http.StatusInternalServerError)
+		return
+	}
+
+	var blobKeys []string
+	response := bulkResponse{Applied: true, Results: make([]bulkResult, 0, len(ids))}
+	for _, id := range ids {
+		row, ok := owned[id]
+		if !ok {
+			response.Applied = false
+			response.Results = append(response.Results, bulkResult{ID: id, Status: "not_found", Error: "graph not found"})
+			continue
+		}
+
+		result := bulkResult{ID: id, Status: "ok"}
+		switch req.Op {
+		case bulkOpDelete:
+			_, err = tx.Exec(ctx, "DELETE FROM graphs WHERE id=$1 AND user_id=$2", id, userID)
+			if err == nil {
+				var keys []string
+				keys, err = deleteGraphDependents(ctx, tx, userID, []string{id})
+				blobKeys = append(blobKeys, keys...)
+			}
+		case bulkOpKind:
+			result.Kind = req.Kind
+			_, err = tx.Exec(
+				ctx,
+				`UPDATE graphs
+				 SET kind = $3, data = jsonb_set(data, '{kind}', to_jsonb($3::text)), updated_at = now()
+				 WHERE id = $1 AND user_id = $2`,
+				id,
+				userID,
+				req.Kind,
+			)
+		case bulkOpRename:
+			result.Name = strings.TrimSpace(pattern.ReplaceAllString(row.name, req.Replacement))
+			if result.Name == "" {
+				response.Applied = false
+				result.Status = "error"
+				result.Error = "name would be empty"
+				response.Results = append(response.Results, result)
+				continue
+			}
+			_, err = tx.Exec(
+				ctx,
+				`UPDATE graphs
+				 SET name = $3, data = jsonb_set(data, '{name}', to_jsonb($3::text)), updated_at = now()
+				 WHERE id = $1 AND user_id = $2`,
+				id,
+				userID,
+				result.Name,
+			)
+		case bulkOpTag:
+			result.Tags = applyTagChanges(row.tags, req.AddTags, req.RemoveTags)
+			_, err = tx.Exec(
+				ctx,
+				"UPDATE graphs SET tags = $3, updated_at = now() WHERE id = $1 AND user_id = $2",
+				id,
+				userID,
+				result.Tags,
+			)
+		case bulkOpMove:
+			result.Folder = strings.TrimSpace(req.Folder)
+			_, err = tx.Exec(
+				ctx,
+				"UPDATE graphs SET folder = $3, updated_at = now() WHERE id = $1 AND user_id = $2",
+				id,
+				userID,
+				result.Folder,
+			)
+		}
+		if err != nil {
+			log.Printf("failed to apply bulk %s to %s: %v", req.Op, id, err)
+			http.Error(w, "failed to update graphs", http.StatusInternalServerError)
+			return
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if !response.Applied {
+		writeJSONStatus(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("failed to commit bulk update: %v", err)
+		http.Error(w, "failed to update graphs", http.StatusInternalServerError)
+		return
+	}
+	s.deleteBlobs(ctx, blobKeys)
+
+	writeJSON(w, response)
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+func applyTagChanges(current, add, remove []string) []string {
+	tags := make([]string, 0, len(current)+len(add))
+	for _, tag := range current {
+		if !slices.Contains(remove, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range add {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -2,7 +2,6 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -65,20 +64,11 @@ func (s *server) handlePutGraph(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
-	defer cancel()
-
-	body, err := readBody(r)
-	if err != nil {
-		http.Error(w, "invalid body", http.StatusBadRequest)
-		return
-	}
-
-	var payload graphPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+	upload, ok := s.readGraphUpload(w, r, userID)
+	if !ok {
 		return
 	}
+	plan, limits, payload := upload.plan, upload.limits, upload.payload
 
 	if payload.Nodes == nil || payload.Edges == nil {
 		http.Error(w, "nodes and edges are required", http.StatusBadRequest)
@@ -87,35 +77,21 @@ func (s *server) handlePutGraph(w http.ResponseWriter, r *http.Request) {
 
 	if strings.TrimSpace(payload.Name) == "" {
 		payload.Name = "Default Graph"
-		body, _ = json.Marshal(payload)
 	}
 	if strings.TrimSpace(payload.Kind) == "" {
 		payload.Kind = "note"
-		body, _ = json.Marshal(payload)
 	}
-	nodeNotesData := extractNodeNotes(payload.Nodes)
-
-	graphID := userGraphID(userID, s.graphID)
-	_, err = s.pool.Exec(
-		ctx,
-		`INSERT INTO graphs (id, user_id, name, kind, data, node_notes, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, now())
-		 ON CONFLICT (id) DO UPDATE
-		 SET name = EXCLUDED.name, kind = EXCLUDED.kind, data = EXCLUDED.data, node_notes = EXCLUDED.node_notes, updated_at = now()`,
-		graphID,
-		userID,
-		payload.Name,
-		payload.Kind,
-		body,
-		nodeNotesData,
-	)
+	body, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("failed to save graph: %v", err)
-		http.Error(w, "failed to save graph", http.StatusInternalServerError)
+		http.Error(w, "failed to encode graph", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	graphID := userGraphID(userID, s.graphID)
+	s.saveGraphAndRespond(ctx, w, userID, graphID, plan, limits, payload, body)
 }
 
 // Multi-graph collection endpoint (list + create).
@@ -124,6 +100,10 @@ func (s *server) handleGraphs(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		s.handleListGraphs(w, r)
 	case http.MethodPost:
+		if templateID := strings.TrimSpace(r.URL.Query().Get("template")); templateID != "" {
+			s.handleCreateGraphFromTemplate(w, r, templateID)
+			return
+		}
 		s.handleCreateGraph(w, r)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -132,11 +112,15 @@ func (s *server) handleGraphs(w http.ResponseWriter, r *http.Request) {
 
 // Per-graph CRUD handler.
 func (s *server) handleGraphByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/graphs/")
-	if id == "" || strings.Contains(id, "/") {
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/graphs/"), "/")
+	if id == "" {
 		http.Error(w, "graph id required", http.StatusBadRequest)
 		return
 	}
+	if sub != "" {
+		s.handleGraphSubresource(w, r, id, sub)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -150,6 +134,34 @@ func (s *server) handleGraphByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Routes /api/graphs/:id/<sub> endpoints that operate on a stored graph.
+func (s *server) handleGraphSubresource(w http.ResponseWriter, r *http.Request, id, sub string) {
+	switch sub {
+	case "merge":
+		s.handleMergeGraph(w, r, id)
+	case "wikilinks":
+		s.handleWikiLinks(w, r, id, "")
+	case "wikilinks/edges":
+		s.handleWikiLinks(w, r, id, "edges")
+	case "comments":
+		s.handleComments(w, r, id, "")
+	case "export":
+		s.handleExportGraph(w, r, id)
+	case "render.svg":
+		s.handleRenderSVG(w, r, id)
+	default:
+		if commentID, ok := strings.CutPrefix(sub, "comments/"); ok {
+			s.handleComments(w, r, id, commentID)
+			return
+		}
+		if rest, ok := strings.CutPrefix(sub, "nodes/"); ok {
+			s.handleNodeSubresource(w, r, id, rest)
+			return
+		}
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
 // Lists graphs filtered by kind (defaults to "note").
 func (s *server) handleListGraphs(w http.ResponseWriter, r *http.Request) {
 	userID, err := s.requireUserID(r)
@@ -166,14 +178,24 @@ func (s *server) handleListGraphs(w http.ResponseWriter, r *http.Request) {
 		kind = "note"
 	}
 
+	// Optional filters set by bulk "move" and "tag" operations.
+	query := r.URL.Query()
+	folder, filterFolder := query.Get("folder"), query.Has("folder")
+	tag := strings.TrimSpace(query.Get("tag"))
+
 	rows, err := s.pool.Query(
 		ctx,
-		`SELECT id, name, updated_at
+		`SELECT id, name, updated_at, tags, folder
 		 FROM graphs
 		 WHERE user_id = $1 AND kind = $2
+		   AND ($3::boolean IS FALSE OR folder = $4)
+		   AND ($5 = '' OR $5 = ANY(tags))
 		 ORDER BY updated_at DESC`,
 		userID,
 		kind,
+		filterFolder,
+		strings.TrimSpace(folder),
+		tag,
 	)
 	if err != nil {
 		log.Printf("failed to list graphs: %v", err)
@@ -185,7 +207,7 @@ func (s *server) handleListGraphs(w http.ResponseWriter, r *http.Request) {
 	var summaries []graphSummary
 	for rows.Next() {
 		var summary graphSummary
-		if err := rows.Scan(&summary.ID, &summary.Name, &summary.UpdatedAt); err != nil {
+		if err := rows.Scan(&summary.ID, &summary.Name, &summary.UpdatedAt, &summary.Tags, &summary.Folder); err != nil {
 			log.Printf("failed to scan graph: %v", err)
 			http.Error(w, "failed to list graphs", http.StatusInternalServerError)
 			return
@@ -209,77 +231,86 @@ func (s *server) handleCreateGraph(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	upload, ok := s.readGraphUpload(w, r, userID)
+	if !ok {
+		return
+	}
+	plan, limits, payload := upload.plan, upload.limits, upload.payload
+
+	if upload.empty {
+		payload = graphPayload{
+			Name:  "Untitled Graph",
+			Nodes: []byte("[]"),
+			Edges: []byte("[]"),
+			Kind:  "note",
+		}
+	} else if payload.Nodes == nil || payload.Edges == nil {
+		http.Error(w, "nodes and edges are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	body, err := readBody(r)
+	s.createGraphAndRespond(ctx, w, userID, plan, limits, payload)
+}
+
+// saveGraphAndRespond checks the quota and stores payload under id in one
+// transaction, so a failed index update leaves the previous version intact.
+func (s *server) saveGraphAndRespond(ctx context.Context, w http.ResponseWriter, userID, id, plan string, limits planLimits, payload graphPayload, body []byte) {
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		http.Error(w, "invalid body", http.StatusBadRequest)
+		log.Printf("failed to begin save: %v", err)
+		http.Error(w, "failed to save graph", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback(ctx)
 
-	payload := graphPayload{
-		Name:  "Untitled Graph",
-		Nodes: []byte("[]"),
-		Edges: []byte("[]"),
-		Kind:  "note",
+	quotaErr, err := checkGraphQuota(ctx, tx, userID, id, plan, limits, payload, len(body))
+	if err != nil {
+		log.Printf("failed to check quota: %v", err)
+		http.Error(w, "failed to save graph", http.StatusInternalServerError)
+		return
 	}
-
-	if len(bytes.TrimSpace(body)) > 0 {
-		if err := json.Unmarshal(body, &payload); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
-			return
-		}
-		if payload.Nodes == nil || payload.Edges == nil {
-			http.Error(w, "nodes and edges are required", http.StatusBadRequest)
-			return
-		}
+	if quotaErr != nil {
+		writeQuotaError(w, quotaErr)
+		return
 	}
-
-	if strings.TrimSpace(payload.Name) == "" {
-		payload.Name = "Untitled Graph"
+	_, err = upsertGraph(ctx, tx, id, userID, payload, body)
+	if writeInvalidGraph(w, err) || writeInvalidLinks(w, err) {
+		return
 	}
-	if strings.TrimSpace(payload.Kind) == "" {
-		payload.Kind = "note"
+	if err == nil {
+		err = tx.Commit(ctx)
 	}
-
-	data, err := json.Marshal(payload)
 	if err != nil {
-		http.Error(w, "failed to encode graph", http.StatusInternalServerError)
+		log.Printf("failed to save graph: %v", err)
+		http.Error(w, "failed to save graph", http.StatusInternalServerError)
 		return
 	}
-	nodeNotesData := extractNodeNotes(payload.Nodes)
+	s.pruneNodeAttachments(ctx, userID, id, payload.Nodes)
 
-	id, err := generateID()
-	if err != nil {
-		http.Error(w, "failed to create graph", http.StatusInternalServerError)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createGraphAndRespond stores payload as a new graph and writes its summary.
+// Graph creation paths (templates, imports) share it so defaults and quotas match.
+func (s *server) createGraphAndRespond(ctx context.Context, w http.ResponseWriter, userID, plan string, limits planLimits, payload graphPayload) {
+	summary, quotaErr, err := s.createGraphTx(ctx, userID, plan, limits, payload)
+	if writeInvalidGraph(w, err) || writeInvalidLinks(w, err) {
 		return
 	}
-
-	var updatedAt time.Time
-	err = s.pool.QueryRow(
-		ctx,
-		`INSERT INTO graphs (id, user_id, name, kind, data, node_notes, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, now())
-		 RETURNING updated_at`,
-		id,
-		userID,
-		payload.Name,
-		payload.Kind,
-		data,
-		nodeNotesData,
-	).Scan(&updatedAt)
 	if err != nil {
 		log.Printf("failed to create graph: %v", err)
 		http.Error(w, "failed to create graph", http.StatusInternalServerError)
 		return
 	}
+	if quotaErr != nil {
+		writeQuotaError(w, quotaErr)
+		return
+	}
 
-	writeJSON(w, graphSummary{
-		ID:        id,
-		Name:      payload.Name,
-		UpdatedAt: updatedAt,
-	})
+	writeJSON(w, summary)
 }
 
 func (s *server) handleGetGraphByID(w http.ResponseWriter, r *http.Request, id string) {
@@ -315,20 +346,11 @@ func (s *server) handlePutGraphByID(w http.ResponseWriter, r *http.Request, id s
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
-	defer cancel()
-
-	body, err := readBody(r)
-	if err != nil {
-		http.Error(w, "invalid body", http.StatusBadRequest)
-		return
-	}
-
-	var payload graphPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+	upload, ok := s.readGraphUpload(w, r, userID)
+	if !ok {
 		return
 	}
+	plan, limits, payload := upload.plan, upload.limits, upload.payload
 
 	if payload.Nodes == nil || payload.Edges == nil {
 		http.Error(w, "nodes and edges are required", http.StatusBadRequest)
@@ -337,34 +359,20 @@ func (s *server) handlePutGraphByID(w http.ResponseWriter, r *http.Request, id s
 
 	if strings.TrimSpace(payload.Name) == "" {
 		payload.Name = "Untitled Graph"
-		body, _ = json.Marshal(payload)
 	}
 	if strings.TrimSpace(payload.Kind) == "" {
 		payload.Kind = "note"
-		body, _ = json.Marshal(payload)
 	}
-	nodeNotesData := extractNodeNotes(payload.Nodes)
-
-	_, err = s.pool.Exec(
-		ctx,
-		`INSERT INTO graphs (id, user_id, name, kind, data, node_notes, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, now())
-		 ON CONFLICT (id) DO UPDATE
-		 SET name = EXCLUDED.name, kind = EXCLUDED.kind, data = EXCLUDED.data, node_notes = EXCLUDED.node_notes, updated_at = now()`,
-		id,
-		userID,
-		payload.Name,
-		payload.Kind,
-		body,
-		nodeNotesData,
-	)
+	body, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("failed to save graph: %v", err)
-		http.Error(w, "failed to save graph", http.StatusInternalServerError)
+		http.Error(w, "failed to encode graph", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	s.saveGraphAndRespond(ctx, w, userID, id, plan, limits, payload, body)
 }
 
 func (s *server) handleDeleteGraphByID(w http.ResponseWriter, r *http.Request, id string) {
@@ -389,5 +397,11 @@ func (s *server) handleDeleteGraphByID(w http.ResponseWriter, r *http.Request, i
 		return
 	}
 
+	keys, err := deleteGraphDependents(ctx, s.pool, userID, []string{id})
+	if err != nil {
+		log.Printf("failed to delete graph dependents: %v", err)
+	}
+	s.deleteBlobs(ctx, keys)
+
 	w.WriteHeader(http.StatusNoContent)
 }
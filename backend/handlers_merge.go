@@ -0,0 +1,135 @@
+// HTTP handler for merging offline edits into a stored graph.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// graphMergeRequest carries the version the client started from and the
+// client's current copy; the server copy is read from the database.
+type graphMergeRequest struct {
+	Base   json.RawMessage `json:"base"`
+	Client json.RawMessage `json:"client"`
+}
+
+type graphMergeResponse struct {
+	Graph     json.RawMessage `json:"graph"`
+	Conflicts []mergeConflict `json:"conflicts"`
+	Saved     bool            `json:"saved"`
+	UpdatedAt *time.Time      `json:"updatedAt,omitempty"`
+}
+
+// POST /api/graphs/:id/merge: three-way merge; saves only when conflict-free.
+func (s *server) handleMergeGraph(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, ok := s.readRequestJSON(w, r, defaultMaxBodyBytes)
+	if !ok {
+		return
+	}
+
+	var req graphMergeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if !isJSONObject(req.Base) || !isJSONObject(req.Client) {
+		http.Error(w, "base and client graphs are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin merge: %v", err)
+		http.Error(w, "failed to merge graph", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var current []byte
+	err = tx.QueryRow(ctx, "SELECT data FROM graphs WHERE id=$1 AND user_id=$2 FOR UPDATE", id, userID).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to merge graph", http.StatusInternalServerError)
+		return
+	}
+
+	merged, conflicts := mergeGraphJSON(req.Base, req.Client, current)
+	response := graphMergeResponse{Graph: merged, Conflicts: conflicts}
+	if len(conflicts) > 0 {
+		writeJSONStatus(w, http.StatusConflict, response)
+		return
+	}
+
+	var payload graphPayload
+	if err := json.Unmarshal(merged, &payload); err != nil || payload.Nodes == nil || payload.Edges == nil {
+		http.Error(w, "merged graph is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	normalizeGraphPayload(&payload)
+	// Stored bytes must match the normalized name/kind columns.
+	data, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, "merged graph is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	response.Graph = data
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to merge graph", http.StatusInternalServerError)
+		return
+	}
+	quotaErr, err := checkGraphQuota(ctx, tx, userID, id, plan, limits, payload, len(data))
+	if err != nil {
+		log.Printf("failed to check quota: %v", err)
+		http.Error(w, "failed to merge graph", http.StatusInternalServerError)
+		return
+	}
+	if quotaErr != nil {
+		writeQuotaError(w, quotaErr)
+		return
+	}
+
+	updatedAt, err := upsertGraph(ctx, tx, id, userID, payload, data)
+	if writeInvalidGraph(w, err) || writeInvalidLinks(w, err) {
+		return
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("failed to save merged graph: %v", err)
+		http.Error(w, "failed to save graph", http.StatusInternalServerError)
+		return
+	}
+
+	s.pruneNodeAttachments(ctx, userID, id, payload.Nodes)
+
+	response.Saved = true
+	response.UpdatedAt = &updatedAt
+	writeJSON(w, response)
+}
@@ -0,0 +1,383 @@
+// HTTP handler for batch syncing graphs created or edited while offline.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const maxSyncGraphs = 200
+
+// syncSinceOverlap is how far before `since` changed graphs are sent again.
+// updated_at is the writing transaction's start time, so a save that starts
+// before a sync reads and commits after it has an updated_at older than that
+// sync's serverTime. The longest graph-writing transactions (import jobs and
+// account restores) run for at most this long.
+const syncSinceOverlap = importJobTimeout
+
+// syncGraphEntry is one locally created/updated/deleted graph. ClientID is the
+// local ("local-...") id; ID is the server id when the client already has one.
+// Graph holds the name, kind, nodes, edges and any other top-level fields.
+type syncGraphEntry struct {
+	ClientID  string
+	ID        string
+	UpdatedAt time.Time
+	Deleted   bool
+	Graph     graphPayload
+}
+
+func (e *syncGraphEntry) UnmarshalJSON(data []byte) error {
+	var meta struct {
+		ClientID  string    `json:"clientId"`
+		ID        string    `json:"id"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Deleted   bool      `json:"deleted"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	var graph graphPayload
+	if err := json.Unmarshal(data, &graph); err != nil {
+		return err
+	}
+	for key := range graph.Extra {
+		switch strings.ToLower(key) {
+		case "clientid", "id", "updatedat", "deleted":
+			delete(graph.Extra, key)
+		}
+	}
+	if len(graph.Extra) == 0 {
+		graph.Extra = nil
+	}
+	*e = syncGraphEntry{ClientID: meta.ClientID, ID: meta.ID, UpdatedAt: meta.UpdatedAt, Deleted: meta.Deleted, Graph: graph}
+	return nil
+}
+
+type syncRequest struct {
+	// Kind limits which server graphs are reported back (defaults to "note").
+	Kind string `json:"kind,omitempty"`
+	// Since is the serverTime of the previous sync; graphs changed after it
+	// (less syncSinceOverlap) are returned.
+	Since *time.Time `json:"since,omitempty"`
+	// KnownIDs are server ids the client already holds.
+	KnownIDs []string         `json:"knownIds"`
+	Graphs   []syncGraphEntry `json:"graphs"`
+}
+
+type syncResult struct {
+	ClientID  string     `json:"clientId"`
+	ID        string     `json:"id,omitempty"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type syncGraph struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Kind      string          `json:"kind"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Graph     json.RawMessage `json:"graph"`
+}
+
+type syncResponse struct {
+	Mapping map[string]string `json:"mapping"`
+	Results []syncResult      `json:"results"`
+	// Missing holds server graphs the client does not have or has stale copies of.
+	Missing []syncGraph `json:"missing"`
+	// Removed lists known ids that no longer exist on the server.
+	Removed    []string  `json:"removed"`
+	ServerTime time.Time `json:"serverTime"`
+}
+
+// POST /api/sync: apply offline changes and report what the client is missing.
+func (s *server) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	plan, limits, body, ok := s.readGraphBody(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req syncRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if len(req.Graphs) > maxSyncGraphs {
+		http.Error(w, "too many graphs in one sync", http.StatusBadRequest)
+		return
+	}
+	kind := strings.TrimSpace(req.Kind)
+	if kind == "" {
+		kind = "note"
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin sync: %v", err)
+		http.Error(w, "failed to sync graphs", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	response := syncResponse{
+		Mapping: map[string]string{},
+		Results: make([]syncResult, 0, len(req.Graphs)),
+		Missing: []syncGraph{},
+		Removed: []string{},
+	}
+	var blobKeys []string
+	written := make(map[string]struct{})
+	conflicted := make(map[string]struct{})
+	for _, entry := range req.Graphs {
+		result, keys, err := syncGraphEntryTx(ctx, tx, userID, plan, limits, entry)
+		if err != nil {
+			log.Printf("failed to sync graph %q: %v", entry.ClientID, err)
+			http.Error(w, "failed to sync graphs", http.StatusInternalServerError)
+			return
+		}
+		blobKeys = append(blobKeys, keys...)
+		if result.ID != "" && strings.TrimSpace(entry.ClientID) != "" {
+			response.Mapping[entry.ClientID] = result.ID
+		}
+		switch result.Status {
+		case "created", "updated", "deleted":
+			written[result.ID] = struct{}{}
+		case "conflict":
+			conflicted[result.ID] = struct{}{}
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	known := make(map[string]struct{}, len(req.KnownIDs))
+	for _, id := range req.KnownIDs {
+		known[id] = struct{}{}
+	}
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT id, name, kind, updated_at, data, now()
+		 FROM graphs
+		 WHERE user_id = $1 AND kind = $2
+		 ORDER BY updated_at DESC`,
+		userID,
+		kind,
+	)
+	if err != nil {
+		log.Printf("failed to list graphs for sync: %v", err)
+		http.Error(w, "failed to sync graphs", http.StatusInternalServerError)
+		return
+	}
+	existing := make(map[string]struct{})
+	for rows.Next() {
+		var graph syncGraph
+		var data []byte
+		if err := rows.Scan(&graph.ID, &graph.Name, &graph.Kind, &graph.UpdatedAt, &data, &response.ServerTime); err != nil {
+			rows.Close()
+			log.Printf("failed to scan graph for sync: %v", err)
+			http.Error(w, "failed to sync graphs", http.StatusInternalServerError)
+			return
+		}
+		existing[graph.ID] = struct{}{}
+		if hasKey(written, graph.ID) {
+			continue
+		}
+		changed := req.Since != nil && graph.UpdatedAt.After(req.Since.Add(-syncSinceOverlap))
+		if !hasKey(known, graph.ID) || changed || hasKey(conflicted, graph.ID) {
+			graph.Graph = data
+			response.Missing = append(response.Missing, graph)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list graphs for sync: %v", err)
+		http.Error(w, "failed to sync graphs", http.StatusInternalServerError)
+		return
+	}
+
+	for _, id := range req.KnownIDs {
+		if !hasKey(existing, id) && !hasKey(written, id) {
+			response.Removed = append(response.Removed, id)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("failed to commit sync: %v", err)
+		http.Error(w, "failed to sync graphs", http.StatusInternalServerError)
+		return
+	}
+	s.deleteBlobs(ctx, blobKeys)
+	if response.ServerTime.IsZero() {
+		response.ServerTime = time.Now().UTC()
+	}
+
+	writeJSON(w, response)
+}
+
+// syncGraphEntryTx applies one entry. Client ids are remembered per user so a
+// retried sync maps to the same server graph instead of creating duplicates.
+// Returned errors are database failures; validation problems become results.
+// Blob keys of removed attachments are returned for deletion after commit.
+func syncGraphEntryTx(ctx context.Context, tx pgx.Tx, userID, plan string, limits planLimits, entry syncGraphEntry) (syncResult, []string, error) {
+	clientID := strings.TrimSpace(entry.ClientID)
+	result := syncResult{ClientID: entry.ClientID}
+	if clientID == "" && strings.TrimSpace(entry.ID) == "" {
+		result.Status = "invalid"
+		result.Error = "clientId or id is required"
+		return result, nil, nil
+	}
+
+	id := strings.TrimSpace(entry.ID)
+	if id == "" {
+		err := tx.QueryRow(
+			ctx,
+			"SELECT graph_id FROM graph_client_ids WHERE user_id=$1 AND client_id=$2",
+			userID,
+			clientID,
+		).Scan(&id)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return result, nil, err
+		}
+	}
+
+	var serverUpdatedAt time.Time
+	exists := false
+	if id != "" {
+		err := tx.QueryRow(
+			ctx,
+			"SELECT updated_at FROM graphs WHERE id=$1 AND user_id=$2 FOR UPDATE",
+			id,
+			userID,
+		).Scan(&serverUpdatedAt)
+		if err == nil {
+			exists = true
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return result, nil, err
+		}
+	}
+	result.ID = id
+
+	// Without updatedAt there is nothing to compare the server copy with.
+	if exists && entry.UpdatedAt.IsZero() {
+		result.Status = "invalid"
+		result.Error = "updatedAt is required to change an existing graph"
+		return result, nil, nil
+	}
+
+	// Deletes are checked too: a delete made offline must not discard edits
+	// saved on the server since.
+	if exists && serverUpdatedAt.After(entry.UpdatedAt) {
+		result.Status = "conflict"
+		result.Error = "server copy is newer"
+		result.UpdatedAt = &serverUpdatedAt
+		return result, nil, nil
+	}
+
+	if entry.Deleted {
+		if !exists {
+			result.Status = "not_found"
+			return result, nil, nil
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM graphs WHERE id=$1 AND user_id=$2", id, userID); err != nil {
+			return result, nil, err
+		}
+		keys, err := deleteGraphDependents(ctx, tx, userID, []string{id})
+		if err != nil {
+			return result, nil, err
+		}
+		result.Status = "deleted"
+		return result, keys, nil
+	}
+
+	payload := entry.Graph
+	if payload.Nodes == nil || payload.Edges == nil {
+		result.Status = "invalid"
+		result.Error = "nodes and edges are required"
+		return result, nil, nil
+	}
+	normalizeGraphPayload(&payload)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		result.Status = "invalid"
+		result.Error = "invalid graph"
+		return result, nil, nil
+	}
+
+	status := "updated"
+	if !exists {
+		if entry.ID != "" && clientID == "" {
+			// A server id the user does not own (or that was deleted) is never recreated.
+			result.Status = "not_found"
+			return result, nil, nil
+		}
+		if id, err = generateID(); err != nil {
+			return result, nil, err
+		}
+		status = "created"
+	}
+
+	quotaErr, err := checkGraphQuota(ctx, tx, userID, id, plan, limits, payload, len(data))
+	if err != nil {
+		return result, nil, err
+	}
+	if quotaErr != nil {
+		result.Status = "quota_exceeded"
+		result.Error = quotaErr.Message
+		return result, nil, nil
+	}
+
+	updatedAt, err := upsertGraph(ctx, tx, id, userID, payload, data)
+	var linksErr *invalidLinksError
+	var graphErr *invalidGraphError
+	if errors.As(err, &linksErr) || errors.As(err, &graphErr) {
+		result.Status = "invalid"
+		result.Error = err.Error()
+		return result, nil, nil
+	}
+	if err != nil {
+		return result, nil, err
+	}
+	if clientID != "" {
+		if _, err := tx.Exec(
+			ctx,
+			`INSERT INTO graph_client_ids (user_id, client_id, graph_id)
+			 VALUES ($1, $2, $3)
+			 ON CONFLICT (user_id, client_id) DO UPDATE SET graph_id = EXCLUDED.graph_id`,
+			userID,
+			clientID,
+			id,
+		); err != nil {
+			return result, nil, err
+		}
+	}
+
+	keys, err := pruneAttachments(ctx, tx, userID, id, payload.Nodes)
+	if err != nil {
+		return result, nil, err
+	}
+
+	result.ID = id
+	result.Status = status
+	result.UpdatedAt = &updatedAt
+	return result, keys, nil
+}
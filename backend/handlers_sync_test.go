@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSyncGraphEntryKeepsExtraFields(t *testing.T) {
+	var req syncRequest
+	err := json.Unmarshal([]byte(`{"graphs":[{
+		"clientId":"local-1","id":"g1","updatedAt":"2026-01-02T03:04:05Z","deleted":false,
+		"name":"G","kind":"note","nodes":[],"edges":[],"viewport":{"x":1,"y":2,"zoom":1.5}
+	}]}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := req.Graphs[0]
+	if entry.ClientID != "local-1" || entry.ID != "g1" || !entry.UpdatedAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if entry.Graph.Name != "G" || entry.Graph.Kind != "note" || string(entry.Graph.Nodes) != "[]" {
+		t.Fatalf("graph = %+v", entry.Graph)
+	}
+	if len(entry.Graph.Extra) != 1 || string(entry.Graph.Extra["viewport"]) != `{"x":1,"y":2,"zoom":1.5}` {
+		t.Fatalf("extra = %v, want only the viewport", entry.Graph.Extra)
+	}
+
+	data, err := json.Marshal(entry.Graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]json.RawMessage
+	json.Unmarshal(data, &stored)
+	if _, ok := stored["viewport"]; !ok {
+		t.Fatalf("stored graph lost the viewport: %s", data)
+	}
+	for _, key := range []string{"clientId", "id", "updatedAt", "deleted"} {
+		if _, ok := stored[key]; ok {
+			t.Fatalf("stored graph kept sync field %s: %s", key, data)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Asynchronous imports for large uploads: the file is read with the request,
+// then converted and stored in the background while the client polls.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Default cap on import job uploads (MAX_IMPORT_JOB_BYTES), which are
+	// larger than single graph saves.
+	defaultMaxImportJobBytes = 64 << 20
+	// Finished jobs are kept this long for polling.
+	importJobTTL = time.Hour
+	// maxRunningImportJobs bounds concurrent jobs per user.
+	maxRunningImportJobs = 2
+	importJobTimeout     = 5 * time.Minute
+	// A running job not updated for this long belongs to an instance that
+	// stopped; it is reported as failed and no longer counts as running.
+	importJobStaleAfter = 2 * importJobTimeout
+	// importJobProgressInterval throttles progress writes to the database.
+	importJobProgressInterval = time.Second
+)
+
+// importJob is the polled state of one import, stored in import_jobs so any
+// instance can answer a poll. Status is running, done or failed; Stage is
+// parsing, converting or saving while running. Done/Total count the format's
+// units (e.g. Roam pages) once it reports progress.
+type importJob struct {
+	ID        string        `json:"id"`
+	Format    string        `json:"format"`
+	Status    string        `json:"status"`
+	Stage     string        `json:"stage,omitempty"`
+	Done      int           `json:"done"`
+	Total     int           `json:"total"`
+	Graph     *graphSummary `json:"graph,omitempty"`
+	Warnings  []string      `json:"warnings,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Quota     *quotaError   `json:"quota,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	userID    string
+}
+
+// POST /api/graphs/import/jobs?format=[&name=][&kind=]: start an import of
+// the raw request body and return 202 with the job to poll.
+func (s *server) handleImportJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	formatName := strings.ToLower(strings.TrimSpace(query.Get("format")))
+	format, ok := graphFormats[formatName]
+	if !ok || format.Import == nil {
+		http.Error(w, "unsupported import format", http.StatusBadRequest)
+		return
+	}
+
+	body, ok := s.readImportJobBody(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to start import", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := generateID()
+	if err != nil {
+		log.Printf("failed to generate id: %v", err)
+		http.Error(w, "failed to start import", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	job := &importJob{
+		ID:        id,
+		Format:    formatName,
+		Status:    "running",
+		Stage:     "parsing",
+		CreatedAt: now,
+		UpdatedAt: now,
+		userID:    userID,
+	}
+
+	started, err := s.startImportJob(ctx, job)
+	if err != nil {
+		log.Printf("failed to start import job: %v", err)
+		http.Error(w, "failed to start import", http.StatusInternalServerError)
+		return
+	}
+	if !started {
+		http.Error(w, fmt.Sprintf("at most %d imports can run at once", maxRunningImportJobs), http.StatusTooManyRequests)
+		return
+	}
+	snapshot := *job
+
+	go s.runImportJob(job, body, query, plan, limits)
+
+	w.Header().Set("Location", "/api/graphs/import/jobs/"+id)
+	writeJSONStatus(w, http.StatusAccepted, snapshot)
+}
+
+// readImportJobBody reads a (possibly compressed) upload under
+// s.maxImportJobBytes. On failure it has already written the response.
+func (s *server) readImportJobBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := openRequestBody(w, r, s.maxImportJobBytes, s.maxImportJobBytes)
+	if errors.Is(err, errUnsupportedEncoding) {
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return nil, false
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, fmt.Sprintf("import exceeds %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return nil, false
+	}
+	return data, true
+}
+
+// startImportJob prunes expired jobs and records job unless the user already
+// has maxRunningImportJobs running. The count runs under the per-user lock
+// so concurrent requests cannot all pass it.
+func (s *server) startImportJob(ctx context.Context, job *importJob) (bool, error) {
+	state, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUserQuota(ctx, tx, job.userID); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(
+		ctx,
+		`DELETE FROM import_jobs
+		 WHERE user_id = $1 AND updated_at < now() - make_interval(secs => $2)`,
+		job.userID,
+		importJobTTL.Seconds(),
+	)
+	if err != nil {
+		return false, err
+	}
+	var running int
+	err = tx.QueryRow(
+		ctx,
+		`SELECT count(*) FROM import_jobs
+		 WHERE user_id = $1 AND status = 'running' AND updated_at > now() - make_interval(secs => $2)`,
+		job.userID,
+		importJobStaleAfter.Seconds(),
+	).Scan(&running)
+	if err != nil {
+		return false, err
+	}
+	if running >= maxRunningImportJobs {
+		return false, nil
+	}
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO import_jobs (id, user_id, status, state, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $5)`,
+		job.ID,
+		job.userID,
+		job.Status,
+		state,
+		job.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// GET /api/graphs/import/jobs/:id: progress and result of an import job.
+func (s *server) handleImportJobByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/graphs/import/jobs/"), "/")
+	var state []byte
+	err = s.pool.QueryRow(ctx, "SELECT state FROM import_jobs WHERE id=$1 AND user_id=$2", id, userID).Scan(&state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read import job: %v", err)
+		http.Error(w, "failed to load import job", http.StatusInternalServerError)
+		return
+	}
+
+	var job importJob
+	if err := json.Unmarshal(state, &job); err != nil {
+		log.Printf("failed to decode import job %s: %v", id, err)
+		http.Error(w, "failed to load import job", http.StatusInternalServerError)
+		return
+	}
+	if job.Status == "running" && time.Since(job.UpdatedAt) > importJobStaleAfter {
+		job.Status, job.Stage, job.Error = "failed", "", "import was interrupted"
+	}
+	writeJSON(w, job)
+}
+
+// saveImportJob writes the job's current state. Failures are logged: the
+// import itself carries on and a poll sees the last state that was saved.
+func (s *server) saveImportJob(job *importJob) {
+	job.UpdatedAt = time.Now().UTC()
+	state, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("failed to encode import job %s: %v", job.ID, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = s.pool.Exec(
+		ctx,
+		"UPDATE import_jobs SET status=$3, state=$4, updated_at=$5 WHERE id=$1 AND user_id=$2",
+		job.ID,
+		job.userID,
+		job.Status,
+		state,
+		job.UpdatedAt,
+	)
+	if err != nil {
+		log.Printf("failed to save import job %s: %v", job.ID, err)
+	}
+}
+
+// runImportJob converts and stores an upload like handleImportGraph, recording
+// progress and the outcome on the job instead of a response. It is the only
+// writer of job once started.
+func (s *server) runImportJob(job *importJob, data []byte, query url.Values, plan string, limits planLimits) {
+	format := graphFormats[job.Format]
+	fail := func(message string) {
+		job.Status, job.Stage, job.Error = "failed", "", message
+		s.saveImportJob(job)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("import job %s panicked: %v", job.ID, recovered)
+			fail("failed to import graph")
+		}
+	}()
+
+	var g ixGraph
+	var err error
+	if format.ImportProgress != nil {
+		var saved time.Time
+		g, err = format.ImportProgress(data, query, func(done, total int) {
+			job.Stage, job.Done, job.Total = "converting", done, total
+			if done == total || time.Since(saved) >= importJobProgressInterval {
+				s.saveImportJob(job)
+				saved = time.Now()
+			}
+		})
+	} else {
+		g, err = format.Import(data, query)
+	}
+	if err != nil {
+		var importErr *errImport
+		if errors.As(err, &importErr) {
+			fail(importErr.Error())
+			return
+		}
+		fail("invalid " + job.Format + " file")
+		return
+	}
+	applyImportOptions(&g, query)
+
+	job.Stage = "saving"
+	s.saveImportJob(job)
+	payload, err := g.toPayload()
+	if err != nil {
+		log.Printf("failed to convert graph: %v", err)
+		fail("failed to convert graph")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), importJobTimeout)
+	defer cancel()
+
+	summary, quotaErr, err := s.createGraphTx(ctx, job.userID, plan, limits, payload)
+	var graphErr *invalidGraphError
+	var linksErr *invalidLinksError
+	switch {
+	case errors.As(err, &graphErr) || errors.As(err, &linksErr):
+		fail(err.Error())
+	case err != nil:
+		log.Printf("failed to create graph: %v", err)
+		fail("failed to create graph")
+	case quotaErr != nil:
+		job.Quota = quotaErr
+		fail(quotaErr.Message)
+	default:
+		job.Status, job.Stage, job.Graph, job.Warnings = "done", "", &summary, g.Warnings
+		s.saveImportJob(job)
+	}
+}
@@ -0,0 +1,866 @@
+// Format-neutral graph model and registry behind the import/export endpoints.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ixGraph is the shape import/export formats work with. Node positions are
+// relative to the parent group, as in React Flow.
+type ixGraph struct {
+	Name  string
+	Kind  string
+	Nodes []ixNode
+	Edges []ixEdge
+	// Warnings lists recoverable import problems, shown in dry runs.
+	Warnings []string
+}
+
+type ixNode struct {
+	ID          string
+	Label       string
+	Group       bool
+	ParentID    string
+	X, Y        float64
+	HasPosition bool
+	// Width/Height are only kept for groups.
+	Width, Height float64
+	Items         []ixItem
+	NodeNotes     string
+	// Metadata is stored as data.metadata (source IDs and timestamps).
+	Metadata map[string]any
+}
+
+// ixItem and ixNote use the frontend Item/Note JSON shape.
+type ixItem struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Notes     []ixNote `json:"notes"`
+	ItemNotes string   `json:"itemNotes,omitempty"`
+	Children  []ixItem `json:"children"`
+}
+
+type ixNote struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type ixEdge struct {
+	ID       string
+	Source   string
+	Target   string
+	Label    string
+	Directed bool
+	// SourceHandle/TargetHandle name the React Flow handles ("right-out",
+	// "left-in", ...) when the edge is attached to a specific side.
+	SourceHandle string
+	TargetHandle string
+}
+
+type ixPoint struct {
+	X, Y float64
+}
+
+// graphFormat is one registered interchange format. Either side may be nil.
+type graphFormat struct {
+	ContentType string
+	Extension   string
+	Export      func(g ixGraph) ([]byte, error)
+	// Import parses an uploaded file; opts carries the request query string.
+	Import func(data []byte, opts url.Values) (ixGraph, error)
+	// ImportProgress, when set, is used by import jobs instead of Import and
+	// reports how many of total units (pages, rows, ...) are converted.
+	ImportProgress func(data []byte, opts url.Values, progress func(done, total int)) (ixGraph, error)
+	// Files, when set, writes the graph as several files under dir of an
+	// archive; names holds the file names already used in that archive.
+	Files func(g ixGraph, dir string, names map[string]struct{}) ([]archiveFile, error)
+}
+
+// archiveFile is one entry of a zip archive built for an export.
+type archiveFile struct {
+	Name string
+	Data []byte
+}
+
+var graphFormats = map[string]graphFormat{
+	"graphml":   {ContentType: "application/graphml+xml", Extension: "graphml", Export: exportGraphML, Import: importGraphML},
+	"gexf":      {ContentType: "application/gexf+xml", Extension: "gexf", Export: exportGEXF, Import: importGEXF},
+	"dot":       {ContentType: "text/vnd.graphviz", Extension: "dot", Export: exportDOT, Import: importDOT},
+	"mermaid":   {ContentType: "text/vnd.mermaid", Extension: "mmd", Export: exportMermaid, Import: importMermaid},
+	"csv":       {ContentType: "text/csv", Extension: "csv", Import: importCSV},
+	"opml":      {ContentType: "text/x-opml", Extension: "opml", Export: exportOPML, Import: importOPML},
+	"cytoscape": {ContentType: "application/json", Extension: "cyjs", Export: exportCytoscape, Import: importCytoscape},
+	"freemind":  {ContentType: "application/x-freemind", Extension: "mm", Import: importFreeMind},
+	"xmind":     {ContentType: "application/vnd.xmind.workbook", Extension: "xmind", Import: importXMind},
+	"roam":      {ContentType: "application/json", Extension: "json", Import: importRoam, ImportProgress: importRoamProgress},
+	"canvas":    {ContentType: "application/json", Extension: "canvas", Export: exportCanvas, Import: importCanvas},
+	"markdown":  {ContentType: "application/zip", Extension: "zip", Export: exportMarkdownVault, Import: importMarkdownVault, Files: markdownVaultFiles},
+}
+
+// errImport marks problems with an uploaded file (reported as 422).
+type errImport struct {
+	msg string
+}
+
+func (e *errImport) Error() string { return e.msg }
+
+func importErrorf(format string, args ...any) error {
+	return &errImport{msg: fmt.Sprintf(format, args...)}
+}
+
+const (
+	defaultGroupWidth  = 300.0
+	defaultGroupHeight = 180.0
+	defaultNodeWidth   = 150.0
+	defaultNodeHeight  = 52.0
+)
+
+type rfNode struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type,omitempty"`
+	Position   *aiPosition `json:"position,omitempty"`
+	ParentNode string      `json:"parentNode,omitempty"`
+	Style      *struct {
+		Width  any `json:"width"`
+		Height any `json:"height"`
+	} `json:"style,omitempty"`
+	Data struct {
+		Label     string   `json:"label"`
+		Items     []ixItem `json:"items"`
+		NodeNotes string   `json:"nodeNotes"`
+	} `json:"data"`
+}
+
+type rfEdge struct {
+	ID           string          `json:"id"`
+	Source       string          `json:"source"`
+	Target       string          `json:"target"`
+	Label        any             `json:"label"`
+	MarkerEnd    json.RawMessage `json:"markerEnd"`
+	SourceHandle string          `json:"sourceHandle"`
+	TargetHandle string          `json:"targetHandle"`
+	Data         struct {
+		Directed bool `json:"directed"`
+	} `json:"data"`
+}
+
+// ixGraphFromPayload converts a stored payload into the interchange model.
+func ixGraphFromPayload(payload graphPayload) (ixGraph, error) {
+	var nodes []rfNode
+	var edges []rfEdge
+	if err := json.Unmarshal(payload.Nodes, &nodes); err != nil {
+		return ixGraph{}, err
+	}
+	if err := json.Unmarshal(payload.Edges, &edges); err != nil {
+		return ixGraph{}, err
+	}
+
+	g := ixGraph{Name: payload.Name, Kind: payload.Kind}
+	for _, node := range nodes {
+		converted := ixNode{
+			ID:        node.ID,
+			Label:     node.Data.Label,
+			Group:     node.Type == "group",
+			ParentID:  node.ParentNode,
+			Items:     node.Data.Items,
+			NodeNotes: node.Data.NodeNotes,
+		}
+		if node.Position != nil {
+			converted.X, converted.Y, converted.HasPosition = node.Position.X, node.Position.Y, true
+		}
+		if converted.Group {
+			converted.Width, converted.Height = defaultGroupWidth, defaultGroupHeight
+			if node.Style != nil {
+				converted.Width = numberOr(node.Style.Width, defaultGroupWidth)
+				converted.Height = numberOr(node.Style.Height, defaultGroupHeight)
+			}
+		}
+		g.Nodes = append(g.Nodes, converted)
+	}
+	ids := make(map[string]struct{}, len(g.Nodes))
+	for _, node := range g.Nodes {
+		ids[node.ID] = struct{}{}
+	}
+	for i := range g.Nodes {
+		if !hasKey(ids, g.Nodes[i].ParentID) {
+			g.Nodes[i].ParentID = ""
+		}
+	}
+	for _, edge := range edges {
+		label, _ := edge.Label.(string)
+		g.Edges = append(g.Edges, ixEdge{
+			ID:           edge.ID,
+			Source:       edge.Source,
+			Target:       edge.Target,
+			Label:        label,
+			Directed:     edge.Data.Directed || (len(edge.MarkerEnd) > 0 && string(edge.MarkerEnd) != "null"),
+			SourceHandle: edge.SourceHandle,
+			TargetHandle: edge.TargetHandle,
+		})
+	}
+	return g, nil
+}
+
+func numberOr(value any, fallback float64) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		var parsed float64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(v, "px"), "%g", &parsed); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// toPayload builds a React Flow payload: ids are made unique, parents are
+// ordered before children, missing positions are laid out on a grid and
+// edges with unknown endpoints are dropped.
+func (g ixGraph) toPayload() (graphPayload, error) {
+	nodes := slices.Clone(g.Nodes)
+	seen := make(map[string]struct{}, len(nodes))
+	for i := range nodes {
+		// Edges and children referencing a duplicated id keep the first node.
+		id := strings.TrimSpace(nodes[i].ID)
+		if id == "" || hasKey(seen, id) {
+			id = newID("node")
+		}
+		seen[id] = struct{}{}
+		nodes[i].ID = id
+	}
+
+	byID := make(map[string]*ixNode, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ID] = &nodes[i]
+	}
+	for i := range nodes {
+		parent, ok := byID[nodes[i].ParentID]
+		if !ok || nodes[i].ParentID == nodes[i].ID || createsParentCycle(byID, nodes[i].ID, nodes[i].ParentID) {
+			nodes[i].ParentID = ""
+			continue
+		}
+		parent.Group = true
+	}
+
+	ordered := make([]ixNode, 0, len(nodes))
+	placed := make(map[string]struct{}, len(nodes))
+	var place func(node *ixNode)
+	place = func(node *ixNode) {
+		if hasKey(placed, node.ID) {
+			return
+		}
+		if node.ParentID != "" {
+			place(byID[node.ParentID])
+		}
+		placed[node.ID] = struct{}{}
+		ordered = append(ordered, *node)
+	}
+	for i := range nodes {
+		place(&nodes[i])
+	}
+
+	layoutIndex := make(map[string]int)
+	rawNodes := make([]map[string]any, 0, len(ordered))
+	for _, node := range ordered {
+		if !node.HasPosition {
+			index := layoutIndex[node.ParentID]
+			layoutIndex[node.ParentID]++
+			position := gridPosition(index)
+			if node.ParentID != "" {
+				position.X, position.Y = position.X/2+24, position.Y/2+48
+			}
+			node.X, node.Y = position.X, position.Y
+		}
+		data := map[string]any{
+			"label": strings.TrimSpace(node.Label),
+			"items": normalizeIXItems(node.Items),
+		}
+		if data["label"] == "" {
+			data["label"] = node.ID
+		}
+		if strings.TrimSpace(node.NodeNotes) != "" {
+			data["nodeNotes"] = node.NodeNotes
+		}
+		if len(node.Metadata) > 0 {
+			data["metadata"] = node.Metadata
+		}
+		raw := map[string]any{
+			"id":       node.ID,
+			"type":     "default",
+			"position": map[string]float64{"x": node.X, "y": node.Y},
+			"data":     data,
+		}
+		if node.Group {
+			raw["type"] = "group"
+			width, height := node.Width, node.Height
+			if width <= 0 {
+				width = defaultGroupWidth
+			}
+			if height <= 0 {
+				height = defaultGroupHeight
+			}
+			raw["style"] = map[string]float64{"width": width, "height": height}
+		}
+		if node.ParentID != "" {
+			raw["parentNode"] = node.ParentID
+			raw["extent"] = "parent"
+		}
+		rawNodes = append(rawNodes, raw)
+	}
+
+	rawEdges := make([]map[string]any, 0, len(g.Edges))
+	edgeIDs := make(map[string]struct{}, len(g.Edges))
+	for _, edge := range g.Edges {
+		source, target := strings.TrimSpace(edge.Source), strings.TrimSpace(edge.Target)
+		if !hasKey(placed, source) || !hasKey(placed, target) {
+			continue
+		}
+		id := strings.TrimSpace(edge.ID)
+		if id == "" || hasKey(edgeIDs, id) {
+			id = newID("edge")
+		}
+		edgeIDs[id] = struct{}{}
+		raw := map[string]any{
+			"id":     id,
+			"source": source,
+			"target": target,
+			"type":   "smoothstep",
+			"data":   map[string]any{"directed": edge.Directed},
+		}
+		if label := strings.TrimSpace(edge.Label); label != "" {
+			raw["label"] = label
+		}
+		if edge.SourceHandle != "" {
+			raw["sourceHandle"] = edge.SourceHandle
+		}
+		if edge.TargetHandle != "" {
+			raw["targetHandle"] = edge.TargetHandle
+		}
+		rawEdges = append(rawEdges, raw)
+	}
+
+	nodesJSON, err := json.Marshal(rawNodes)
+	if err != nil {
+		return graphPayload{}, err
+	}
+	edgesJSON, err := json.Marshal(rawEdges)
+	if err != nil {
+		return graphPayload{}, err
+	}
+	return graphPayload{Name: strings.TrimSpace(g.Name), Nodes: nodesJSON, Edges: edgesJSON, Kind: g.Kind}, nil
+}
+
+func createsParentCycle(byID map[string]*ixNode, id, parentID string) bool {
+	for steps := 0; parentID != "" && steps <= len(byID); steps++ {
+		if parentID == id {
+			return true
+		}
+		parent, ok := byID[parentID]
+		if !ok {
+			return false
+		}
+		parentID = parent.ParentID
+	}
+	return parentID != ""
+}
+
+// normalizeIXItems fills ids and replaces nil slices so items match the frontend shape.
+func normalizeIXItems(items []ixItem) []ixItem {
+	normalized := make([]ixItem, 0, len(items))
+	for _, item := range items {
+		if strings.TrimSpace(item.ID) == "" {
+			item.ID = newID("item")
+		}
+		if item.Notes == nil {
+			item.Notes = []ixNote{}
+		}
+		for i := range item.Notes {
+			if strings.TrimSpace(item.Notes[i].ID) == "" {
+				item.Notes[i].ID = newID("note")
+			}
+		}
+		item.Children = normalizeIXItems(item.Children)
+		normalized = append(normalized, item)
+	}
+	return normalized
+}
+
+// absolutePositions resolves group-relative positions to canvas coordinates.
+func (g ixGraph) absolutePositions() map[string]ixPoint {
+	byID := make(map[string]ixNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		byID[node.ID] = node
+	}
+	positions := make(map[string]ixPoint, len(g.Nodes))
+	for _, node := range g.Nodes {
+		point := ixPoint{X: node.X, Y: node.Y}
+		parentID := node.ParentID
+		for steps := 0; parentID != "" && steps < len(g.Nodes); steps++ {
+			parent, ok := byID[parentID]
+			if !ok {
+				break
+			}
+			point.X += parent.X
+			point.Y += parent.Y
+			parentID = parent.ParentID
+		}
+		positions[node.ID] = point
+	}
+	return positions
+}
+
+// relativizePositions converts absolute canvas positions (as most formats
+// store them) into the group-relative positions React Flow expects.
+func (g *ixGraph) relativizePositions() {
+	absolute := make(map[string]ixPoint, len(g.Nodes))
+	for _, node := range g.Nodes {
+		absolute[node.ID] = ixPoint{X: node.X, Y: node.Y}
+	}
+	for i := range g.Nodes {
+		node := &g.Nodes[i]
+		if parent, ok := absolute[node.ParentID]; ok && node.HasPosition {
+			node.X -= parent.X
+			node.Y -= parent.Y
+		}
+	}
+}
+
+// subgraph keeps one node and, for groups, everything inside it, plus the
+// edges between kept nodes. The graph takes the node's label as its name.
+func (g ixGraph) subgraph(nodeID string) (ixGraph, bool) {
+	kept := map[string]struct{}{nodeID: {}}
+	found := false
+	for _, node := range g.Nodes {
+		if node.ID == nodeID {
+			found = true
+		}
+	}
+	if !found {
+		return ixGraph{}, false
+	}
+	// Parents may follow their children, so repeat until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for _, node := range g.Nodes {
+			if hasKey(kept, node.ParentID) && !hasKey(kept, node.ID) {
+				kept[node.ID] = struct{}{}
+				changed = true
+			}
+		}
+	}
+
+	positions := g.absolutePositions()
+	sub := ixGraph{Kind: g.Kind}
+	for _, node := range g.Nodes {
+		if !hasKey(kept, node.ID) {
+			continue
+		}
+		if node.ID == nodeID {
+			sub.Name = node.Label
+			node.ParentID = ""
+			node.X, node.Y = positions[node.ID].X, positions[node.ID].Y
+		}
+		sub.Nodes = append(sub.Nodes, node)
+	}
+	for _, edge := range g.Edges {
+		if hasKey(kept, edge.Source) && hasKey(kept, edge.Target) {
+			sub.Edges = append(sub.Edges, edge)
+		}
+	}
+	return sub, true
+}
+
+// countItems counts items including nested children.
+func countItems(items []ixItem) int {
+	count := len(items)
+	for _, item := range items {
+		count += countItems(item.Children)
+	}
+	return count
+}
+
+// exportFileName builds a download name from the graph name.
+func exportFileName(name, extension string) string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\' || r == '"' || r < 0x20 || r == 0x7f:
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if base == "" {
+		base = "graph"
+	}
+	return base + "." + extension
+}
+
+// GET /api/graphs/:id/export?format=: download a stored graph in another format.
+func (s *server) handleExportGraph(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	formatName := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	format, ok := graphFormats[formatName]
+	if !ok || format.Export == nil {
+		http.Error(w, "unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	g, ok := s.loadIXGraph(ctx, w, userID, id)
+	if !ok {
+		return
+	}
+	if nodeID := strings.TrimSpace(r.URL.Query().Get("nodeId")); nodeID != "" {
+		if g, ok = g.subgraph(nodeID); !ok {
+			http.Error(w, "node not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	out, err := format.Export(g)
+	if err != nil {
+		log.Printf("failed to export graph as %s: %v", formatName, err)
+		http.Error(w, "failed to export graph", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": exportFileName(g.Name, format.Extension),
+	}))
+	_, _ = w.Write(out)
+}
+
+// loadIXGraph reads a stored graph as an ixGraph, writing 404/500 on failure.
+func (s *server) loadIXGraph(ctx context.Context, w http.ResponseWriter, userID, id string) (ixGraph, bool) {
+	var data []byte
+	err := s.pool.QueryRow(ctx, "SELECT data FROM graphs WHERE id=$1 AND user_id=$2", id, userID).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return ixGraph{}, false
+	} else if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to load graph", http.StatusInternalServerError)
+		return ixGraph{}, false
+	}
+
+	var payload graphPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		http.Error(w, "stored graph is invalid", http.StatusUnprocessableEntity)
+		return ixGraph{}, false
+	}
+	g, err := ixGraphFromPayload(payload)
+	if err != nil {
+		http.Error(w, "stored graph is invalid", http.StatusUnprocessableEntity)
+		return ixGraph{}, false
+	}
+	return g, true
+}
+
+// handleExportGraphs serves GET /api/graphs/export?format=&kind= as a zip
+// with every graph (optionally of one kind), laid out by graph folder.
+func (s *server) handleExportGraphs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	formatName := strings.ToLower(strings.TrimSpace(query.Get("format")))
+	format, ok := graphFormats[formatName]
+	if !ok || (format.Export == nil && format.Files == nil) {
+		http.Error(w, "unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT name, folder, data FROM graphs
+		 WHERE user_id = $1 AND ($2 = '' OR kind = $2)
+		 ORDER BY folder, name, id`,
+		userID,
+		strings.TrimSpace(query.Get("kind")),
+	)
+	if err != nil {
+		log.Printf("failed to list graphs for export: %v", err)
+		http.Error(w, "failed to export graphs", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var files []archiveFile
+	names := map[string]struct{}{}
+	graphNames := map[string]struct{}{}
+	for rows.Next() {
+		var name, folder string
+		var data []byte
+		if err := rows.Scan(&name, &folder, &data); err != nil {
+			log.Printf("failed to scan graph for export: %v", err)
+			http.Error(w, "failed to export graphs", http.StatusInternalServerError)
+			return
+		}
+		var payload graphPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			continue
+		}
+		g, err := ixGraphFromPayload(payload)
+		if err != nil {
+			continue
+		}
+		g.Name = name
+
+		dir := archiveFolder(folder)
+		base := uniqueFileName(firstNonEmpty(vaultFileName(name), "Untitled"), graphNames)
+		if format.Files != nil {
+			graphFiles, err := format.Files(g, path.Join(dir, base), names)
+			if err != nil {
+				log.Printf("failed to export graph as %s: %v", formatName, err)
+				http.Error(w, "failed to export graphs", http.StatusInternalServerError)
+				return
+			}
+			files = append(files, graphFiles...)
+			continue
+		}
+		out, err := format.Export(g)
+		if err != nil {
+			log.Printf("failed to export graph as %s: %v", formatName, err)
+			http.Error(w, "failed to export graphs", http.StatusInternalServerError)
+			return
+		}
+		files = append(files, archiveFile{Name: path.Join(dir, base+"."+format.Extension), Data: out})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list graphs for export: %v", err)
+		http.Error(w, "failed to export graphs", http.StatusInternalServerError)
+		return
+	}
+
+	archive, err := zipArchive(files)
+	if err != nil {
+		log.Printf("failed to build export archive: %v", err)
+		http.Error(w, "failed to export graphs", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "graphs-" + formatName + ".zip",
+	}))
+	_, _ = w.Write(archive)
+}
+
+// archiveFolder turns a graph folder ("a/b") into a safe archive path.
+func archiveFolder(folder string) string {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		if part = vaultFileName(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return path.Join(parts...)
+}
+
+func zipArchive(files []archiveFile) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		writer, err := zw.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// POST /api/graphs/import?format=[&name=][&kind=]: create a graph from an
+// uploaded file (raw request body).
+func (s *server) handleImportGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	formatName := strings.ToLower(strings.TrimSpace(query.Get("format")))
+	format, ok := graphFormats[formatName]
+	if !ok || format.Import == nil {
+		http.Error(w, "unsupported import format", http.StatusBadRequest)
+		return
+	}
+
+	plan, limits, body, ok := s.readGraphBody(w, r, userID)
+	if !ok {
+		return
+	}
+
+	g, err := format.Import(body, query)
+	if err != nil {
+		var importErr *errImport
+		if errors.As(err, &importErr) {
+			http.Error(w, importErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "invalid "+formatName+" file", http.StatusUnprocessableEntity)
+		return
+	}
+	applyImportOptions(&g, query)
+
+	payload, err := g.toPayload()
+	if err != nil {
+		http.Error(w, "failed to convert graph", http.StatusInternalServerError)
+		return
+	}
+
+	if dryRun, _ := strconv.ParseBool(query.Get("dryRun")); dryRun {
+		// createGraph validates real imports; a dry run reports the same error.
+		if data, err := json.Marshal(payload); err == nil && writeInvalidGraph(w, validateGraphData(data)) {
+			return
+		}
+		writeJSON(w, newImportPreview(g, payload))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	s.createGraphAndRespond(ctx, w, userID, plan, limits, payload)
+}
+
+// applyImportOptions applies the ?name= and ?kind= overrides of an import.
+func applyImportOptions(g *ixGraph, query url.Values) {
+	if name := strings.TrimSpace(query.Get("name")); name != "" {
+		g.Name = name
+	}
+	if kind := strings.TrimSpace(query.Get("kind")); kind != "" {
+		g.Kind = kind
+	}
+}
+
+// importPreview is the dry-run response of an import: what would be created.
+type importPreview struct {
+	DryRun   bool         `json:"dryRun"`
+	Name     string       `json:"name"`
+	Kind     string       `json:"kind,omitempty"`
+	Nodes    int          `json:"nodes"`
+	Groups   int          `json:"groups"`
+	Edges    int          `json:"edges"`
+	Items    int          `json:"items"`
+	Warnings []string     `json:"warnings"`
+	Graph    graphPayload `json:"graph"`
+}
+
+func newImportPreview(g ixGraph, payload graphPayload) importPreview {
+	preview := importPreview{DryRun: true, Name: payload.Name, Kind: payload.Kind, Warnings: g.Warnings, Graph: payload}
+	if preview.Warnings == nil {
+		preview.Warnings = []string{}
+	}
+	var nodes []rfNode
+	_ = json.Unmarshal(payload.Nodes, &nodes)
+	var edges []json.RawMessage
+	_ = json.Unmarshal(payload.Edges, &edges)
+	preview.Edges = len(edges)
+	for _, node := range g.Nodes {
+		preview.Items += countItems(node.Items)
+	}
+	for _, node := range nodes {
+		if node.Type == "group" {
+			preview.Groups++
+		} else {
+			preview.Nodes++
+		}
+	}
+	return preview
+}
+
+// Limits for uploaded zip archives, checked against the uncompressed sizes.
+const (
+	maxArchiveFiles = 10000
+	maxArchiveBytes = 64 << 20
+)
+
+// readZipArchive unpacks an uploaded zip, skipping directories and macOS
+// metadata, and rejects archives that expand beyond the limits.
+func readZipArchive(data []byte) ([]archiveFile, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, importErrorf("invalid zip archive: %v", err)
+	}
+	if len(reader.File) > maxArchiveFiles {
+		return nil, importErrorf("archive has more than %d files", maxArchiveFiles)
+	}
+	var files []archiveFile
+	var total int64
+	for _, file := range reader.File {
+		name := path.Clean(strings.ReplaceAll(file.Name, "\\", "/"))
+		if file.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store" {
+			continue
+		}
+		if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, importErrorf("invalid path %q in archive", file.Name)
+		}
+		opened, err := file.Open()
+		if err != nil {
+			return nil, importErrorf("failed to read %q: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(opened, maxArchiveBytes-total+1))
+		opened.Close()
+		if err != nil {
+			return nil, importErrorf("failed to read %q: %v", file.Name, err)
+		}
+		total += int64(len(content))
+		if total > maxArchiveBytes {
+			return nil, importErrorf("archive expands to more than %d MB", maxArchiveBytes>>20)
+		}
+		files = append(files, archiveFile{Name: name, Data: content})
+	}
+	return files, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// sampleIXGraph has a group with two children, nested items, notes, a
+// labeled edge and an edge to the group, which is what the format round
+// trips check.
+func sampleIXGraph() ixGraph {
+	return ixGraph{
+		Name: "Sample",
+		Kind: "note",
+		Nodes: []ixNode{
+			{ID: "g", Label: "Group", Group: true, HasPosition: true, Width: 500, Height: 300},
+			{ID: "a", Label: "Alpha", ParentID: "g", X: 40, Y: 60, HasPosition: true, Items: []ixItem{
+				{ID: "i1", Title: "First", Notes: []ixNote{}, Children: []ixItem{
+					{ID: "i2", Title: "Nested", Notes: []ixNote{}, Children: []ixItem{}},
+				}},
+			}},
+			{ID: "b", Label: "Beta & <co>", ParentID: "g", X: 260, Y: 60, HasPosition: true},
+			{ID: "c", Label: "Gamma", X: 700, Y: 100, HasPosition: true, NodeNotes: `{"blocks":[{"type":"paragraph","data":{"text":"note"}}]}`},
+		},
+		Edges: []ixEdge{
+			{ID: "e1", Source: "a", Target: "b", Label: "rel", Directed: true},
+			{ID: "e2", Source: "b", Target: "c", Directed: true},
+			{ID: "e3", Source: "c", Target: "g", Label: "into", Directed: true},
+		},
+	}
+}
+
+// ixShape describes a graph by labels so graphs with different ids compare.
+type ixShape struct {
+	Nodes     []string
+	Edges     []string
+	Items     map[string]string
+	Notes     map[string]string
+	Positions map[string]string
+}
+
+func shapeOf(g ixGraph) ixShape {
+	byID := make(map[string]ixNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		byID[node.ID] = node
+	}
+	var titles func(items []ixItem) string
+	titles = func(items []ixItem) string {
+		out := ""
+		for _, item := range items {
+			out += item.Title + "(" + titles(item.Children) + ")"
+		}
+		return out
+	}
+	shape := ixShape{Items: map[string]string{}, Notes: map[string]string{}, Positions: map[string]string{}}
+	for _, node := range g.Nodes {
+		shape.Nodes = append(shape.Nodes, fmt.Sprintf("%s in %q group=%v", node.Label, byID[node.ParentID].Label, node.Group))
+		if len(node.Items) > 0 {
+			shape.Items[node.Label] = titles(node.Items)
+		}
+		if node.NodeNotes != "" {
+			shape.Notes[node.Label] = node.NodeNotes
+		}
+		if !node.Group {
+			shape.Positions[node.Label] = fmt.Sprintf("%g,%g", node.X, node.Y)
+		}
+	}
+	for _, edge := range g.Edges {
+		shape.Edges = append(shape.Edges, fmt.Sprintf("%s->%s %q directed=%v", byID[edge.Source].Label, byID[edge.Target].Label, edge.Label, edge.Directed))
+	}
+	sort.Strings(shape.Nodes)
+	sort.Strings(shape.Edges)
+	return shape
+}
+
+// roundTripKeeps lists what a format carries besides labels and grouping.
+type roundTripKeeps struct {
+	edges, items, notes, positions bool
+}
+
+// testExportImportRoundTrip exports sampleIXGraph in the named format,
+// imports the result and compares what the format is expected to keep.
+func testExportImportRoundTrip(t *testing.T, name string, keeps roundTripKeeps) {
+	t.Helper()
+	format := graphFormats[name]
+	data, err := format.Export(sampleIXGraph())
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	imported, err := format.Import(data, nil)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	got, want := shapeOf(imported), shapeOf(sampleIXGraph())
+
+	if !reflect.DeepEqual(got.Nodes, want.Nodes) {
+		t.Errorf("nodes:\n got %q\nwant %q", got.Nodes, want.Nodes)
+	}
+	if keeps.edges && !reflect.DeepEqual(got.Edges, want.Edges) {
+		t.Errorf("edges:\n got %q\nwant %q", got.Edges, want.Edges)
+	}
+	if keeps.items && !reflect.DeepEqual(got.Items, want.Items) {
+		t.Errorf("items:\n got %q\nwant %q", got.Items, want.Items)
+	}
+	if keeps.notes && !reflect.DeepEqual(got.Notes, want.Notes) {
+		t.Errorf("notes:\n got %q\nwant %q", got.Notes, want.Notes)
+	}
+	if keeps.positions && !reflect.DeepEqual(got.Positions, want.Positions) {
+		t.Errorf("positions:\n got %q\nwant %q", got.Positions, want.Positions)
+	}
+	checkImportedPayload(t, imported)
+
+	// A cut-off export must fail or import cleanly, never panic.
+	for _, cut := range []int{1, len(data) / 3, len(data) / 2, len(data) - 1} {
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					t.Errorf("import of %d/%d bytes panicked: %v", cut, len(data), recovered)
+				}
+			}()
+			if g, err := format.Import(data[:cut], nil); err == nil && len(g.Nodes) > 0 {
+				checkImportedPayload(t, g)
+			}
+		}()
+	}
+}
+
+// importCase is one input for testImportRejects or testImportRepairs.
+type importCase struct {
+	name  string
+	data  string
+	query url.Values
+}
+
+// testImportRejects checks that each input fails with an import error, the
+// kind of error the import endpoints report to the user as a 400.
+func testImportRejects(t *testing.T, name string, cases []importCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := graphFormats[name].Import([]byte(tc.data), tc.query)
+			var importErr *errImport
+			if !errors.As(err, &importErr) {
+				t.Fatalf("err = %v, want an import error", err)
+			}
+		})
+	}
+}
+
+// testImportRepairs checks that inputs which parse but reference missing
+// nodes, repeat ids or nest groups in cycles import as a consistent graph.
+func testImportRepairs(t *testing.T, name string, cases []importCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := graphFormats[name].Import([]byte(tc.data), tc.query)
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			if len(g.Nodes) == 0 {
+				t.Fatal("no nodes imported")
+			}
+			checkImportedPayload(t, g)
+		})
+	}
+}
+
+// checkImportedPayload verifies the invariants every import must meet once
+// stored: unique node ids, parents placed before their children, edge
+// endpoints that exist, and data that passes the graph schema.
+func checkImportedPayload(t *testing.T, g ixGraph) {
+	t.Helper()
+	payload, err := g.toPayload()
+	if err != nil {
+		t.Fatalf("toPayload: %v", err)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateGraphData(data); err != nil {
+		t.Fatalf("imported graph does not validate: %v", err)
+	}
+
+	var nodes []struct {
+		ID         string `json:"id"`
+		ParentNode string `json:"parentNode"`
+	}
+	var edges []struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}
+	json.Unmarshal(payload.Nodes, &nodes)
+	json.Unmarshal(payload.Edges, &edges)
+	parents := make(map[string]string, len(nodes))
+	for i, node := range nodes {
+		if _, dup := parents[node.ID]; dup || node.ID == "" {
+			t.Fatalf("node %d has an empty or duplicate id %q", i, node.ID)
+		}
+		if node.ParentNode != "" {
+			if _, placed := parents[node.ParentNode]; !placed {
+				t.Fatalf("node %s is placed before its parent %s", node.ID, node.ParentNode)
+			}
+		}
+		parents[node.ID] = node.ParentNode
+	}
+	for _, edge := range edges {
+		_, hasSource := parents[edge.Source]
+		_, hasTarget := parents[edge.Target]
+		if !hasSource || !hasTarget {
+			t.Fatalf("edge %s->%s points at a missing node", edge.Source, edge.Target)
+		}
+	}
+}
+
+// testZip builds an archive for importers that read zip files.
+func testZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var entries []archiveFile
+	for name, data := range files {
+		entries = append(entries, archiveFile{Name: name, Data: []byte(data)})
+	}
+	data, err := zipArchive(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+	payload, err := sampleIXGraph().toPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateGraphData(data); err != nil {
+		t.Fatalf("payload does not validate: %v", err)
+	}
+	back, err := ixGraphFromPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := shapeOf(back), shapeOf(sampleIXGraph()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("payload round trip:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestApplyImportOptions(t *testing.T) {
+	g := sampleIXGraph()
+	applyImportOptions(&g, url.Values{"name": {" Renamed "}, "kind": {"map"}})
+	if g.Name != "Renamed" || g.Kind != "map" {
+		t.Fatalf("name = %q, kind = %q", g.Name, g.Kind)
+	}
+	if !slices.ContainsFunc(g.Nodes, func(node ixNode) bool { return node.Label == "Alpha" }) {
+		t.Fatal("options changed the nodes")
+	}
+}
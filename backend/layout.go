@@ -0,0 +1,335 @@
+// Layered and radial auto-layouts for imported graphs that carry no positions.
+package main
+
+import "math"
+
+const (
+	layoutNodeGap     = 60.0
+	layoutRankGap     = 80.0
+	layoutGroupPadX   = 24.0
+	layoutGroupPadTop = 48.0
+	layoutGroupPadEnd = 24.0
+	// layoutMaxBreadth wraps ranks with many nodes (e.g. isolated nodes)
+	// onto several rows.
+	layoutMaxBreadth = 8
+	// layoutRingGap is the minimum distance between rings of a radial
+	// layout and layoutLeafArc the arc each node needs on its ring.
+	layoutRingGap = 240.0
+	layoutLeafArc = 110.0
+)
+
+// layoutLayered positions every node reachable from the root. Within each
+// group (and the root) nodes are ranked along edges, longest path first with
+// back edges ignored, and placed rank by rank. direction is "TB", "BT", "LR"
+// or "RL"; groups are marked as such and sized to fit their children.
+func (g *ixGraph) layoutLayered(direction string) {
+	// An empty or repeated id would make a node a container of itself, so
+	// those get fresh ids (edges keep the first node, as in toPayload), and
+	// nodes whose parents form a cycle are moved to the root.
+	byID := make(map[string]*ixNode, len(g.Nodes))
+	for i := range g.Nodes {
+		if _, dup := byID[g.Nodes[i].ID]; g.Nodes[i].ID == "" || dup {
+			g.Nodes[i].ID = newID("node")
+		}
+		byID[g.Nodes[i].ID] = &g.Nodes[i]
+	}
+	for i := range g.Nodes {
+		if createsParentCycle(byID, g.Nodes[i].ID, g.Nodes[i].ParentID) {
+			g.Nodes[i].ParentID = ""
+		}
+	}
+
+	index := make(map[string]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.ID] = i
+	}
+	parentOf := func(id string) string {
+		parentID := g.Nodes[index[id]].ParentID
+		if _, ok := index[parentID]; !ok {
+			return ""
+		}
+		return parentID
+	}
+	children := map[string][]string{}
+	for _, node := range g.Nodes {
+		parentID := parentOf(node.ID)
+		children[parentID] = append(children[parentID], node.ID)
+	}
+
+	// memberUnder returns the ancestor of id (or id itself) that sits
+	// directly in container, so edges into a group rank the group.
+	memberUnder := func(id, container string) (string, bool) {
+		if _, ok := index[id]; !ok {
+			return "", false
+		}
+		for steps := 0; steps <= len(g.Nodes); steps++ {
+			parentID := parentOf(id)
+			if parentID == container {
+				return id, true
+			}
+			if parentID == "" {
+				return "", false
+			}
+			id = parentID
+		}
+		return "", false
+	}
+
+	horizontal := direction == "LR" || direction == "RL"
+	reversed := direction == "BT" || direction == "RL"
+
+	var layout func(container string) ixPoint
+	layout = func(container string) ixPoint {
+		members := children[container]
+		sizes := make(map[string]ixPoint, len(members))
+		for _, id := range members {
+			node := &g.Nodes[index[id]]
+			switch {
+			case len(children[id]) > 0:
+				content := layout(id)
+				node.Group = true
+				node.Width = content.X + 2*layoutGroupPadX
+				node.Height = content.Y + layoutGroupPadTop + layoutGroupPadEnd
+			case node.Group:
+				node.Width = math.Max(node.Width, defaultGroupWidth)
+				node.Height = math.Max(node.Height, defaultGroupHeight)
+			}
+			if node.Group {
+				sizes[id] = ixPoint{X: node.Width, Y: node.Height}
+			} else {
+				sizes[id] = ixPoint{X: defaultNodeWidth, Y: defaultNodeHeight}
+			}
+		}
+
+		edges := map[string][]string{}
+		seen := map[[2]string]struct{}{}
+		for _, edge := range g.Edges {
+			source, ok := memberUnder(edge.Source, container)
+			if !ok {
+				continue
+			}
+			target, ok := memberUnder(edge.Target, container)
+			if !ok || source == target {
+				continue
+			}
+			key := [2]string{source, target}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			edges[source] = append(edges[source], target)
+		}
+		ranks := layerRanks(members, edges)
+
+		var layers [][]string
+		for _, id := range members {
+			for len(layers) <= ranks[id] {
+				layers = append(layers, nil)
+			}
+			layers[ranks[id]] = append(layers[ranks[id]], id)
+		}
+		var rows [][]string
+		for _, layer := range layers {
+			for len(layer) > layoutMaxBreadth {
+				rows = append(rows, layer[:layoutMaxBreadth])
+				layer = layer[layoutMaxBreadth:]
+			}
+			if len(layer) > 0 {
+				rows = append(rows, layer)
+			}
+		}
+
+		// main is the axis along ranks, cross the axis within a rank.
+		main := func(size ixPoint) float64 {
+			if horizontal {
+				return size.X
+			}
+			return size.Y
+		}
+		cross := func(size ixPoint) float64 {
+			if horizontal {
+				return size.Y
+			}
+			return size.X
+		}
+		breadths := make([]float64, len(rows))
+		depths := make([]float64, len(rows))
+		maxBreadth := 0.0
+		for i, row := range rows {
+			for j, id := range row {
+				if j > 0 {
+					breadths[i] += layoutNodeGap
+				}
+				breadths[i] += cross(sizes[id])
+				depths[i] = math.Max(depths[i], main(sizes[id]))
+			}
+			maxBreadth = math.Max(maxBreadth, breadths[i])
+		}
+		totalDepth := 0.0
+		for i, depth := range depths {
+			if i > 0 {
+				totalDepth += layoutRankGap
+			}
+			totalDepth += depth
+		}
+
+		originX, originY := 0.0, 0.0
+		if container != "" {
+			originX, originY = layoutGroupPadX, layoutGroupPadTop
+		}
+		offset := 0.0
+		for i, row := range rows {
+			along := (maxBreadth - breadths[i]) / 2
+			for _, id := range row {
+				size := sizes[id]
+				at := offset
+				if reversed {
+					at = totalDepth - offset - main(size)
+				}
+				node := &g.Nodes[index[id]]
+				if horizontal {
+					node.X, node.Y = originX+at, originY+along
+				} else {
+					node.X, node.Y = originX+along, originY+at
+				}
+				node.HasPosition = true
+				along += cross(size) + layoutNodeGap
+			}
+			offset += depths[i] + layoutRankGap
+		}
+
+		if horizontal {
+			return ixPoint{X: totalDepth, Y: maxBreadth}
+		}
+		return ixPoint{X: maxBreadth, Y: totalDepth}
+	}
+	layout("")
+}
+
+// layerRanks assigns each member its longest-path depth from a source,
+// ignoring edges that close a cycle.
+func layerRanks(members []string, edges map[string][]string) map[string]int {
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[string]int, len(members))
+	dag := make(map[string][]string, len(members))
+	order := make([]string, 0, len(members))
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = active
+		for _, next := range edges[id] {
+			switch state[next] {
+			case unvisited:
+				dag[id] = append(dag[id], next)
+				visit(next)
+			case done:
+				dag[id] = append(dag[id], next)
+			}
+		}
+		state[id] = done
+		order = append(order, id)
+	}
+	for _, id := range members {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+
+	ranks := make(map[string]int, len(members))
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		for _, next := range dag[id] {
+			if ranks[next] < ranks[id]+1 {
+				ranks[next] = ranks[id] + 1
+			}
+		}
+	}
+	return ranks
+}
+
+// layoutRadial places each tree of a forest (children lists keyed by node ID)
+// around its root: depth d sits on ring d and every subtree gets an angle
+// proportional to its leaves, starting at the top and going clockwise. Rings
+// grow until the narrowest subtree on them has room. Trees are placed side by
+// side, left to right. Groups are not supported.
+func (g *ixGraph) layoutRadial(roots []string, children map[string][]string) {
+	index := make(map[string]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.ID] = i
+	}
+	leaves := map[string]float64{}
+	var countLeaves func(id string, depth int) float64
+	countLeaves = func(id string, depth int) float64 {
+		count := 0.0
+		if depth <= len(g.Nodes) {
+			for _, child := range children[id] {
+				count += countLeaves(child, depth+1)
+			}
+		}
+		leaves[id] = math.Max(count, 1)
+		return leaves[id]
+	}
+
+	offsetX := 0.0
+	for _, root := range roots {
+		if _, ok := index[root]; !ok {
+			continue
+		}
+		countLeaves(root, 0)
+
+		// First pass: the angle span of every node, then the ring radii.
+		type placement struct {
+			id         string
+			depth      int
+			start, end float64
+		}
+		var placed []placement
+		var assign func(id string, depth int, start, end float64)
+		assign = func(id string, depth int, start, end float64) {
+			placed = append(placed, placement{id: id, depth: depth, start: start, end: end})
+			if depth > len(g.Nodes) {
+				return
+			}
+			at := start
+			for _, child := range children[id] {
+				span := (end - start) * leaves[child] / leaves[id]
+				assign(child, depth+1, at, at+span)
+				at += span
+			}
+		}
+		assign(root, 0, -math.Pi/2, 3*math.Pi/2)
+
+		var narrowest []float64
+		for _, p := range placed {
+			for len(narrowest) <= p.depth {
+				narrowest = append(narrowest, 2*math.Pi)
+			}
+			narrowest[p.depth] = math.Min(narrowest[p.depth], p.end-p.start)
+		}
+		radii := make([]float64, len(narrowest))
+		for depth := 1; depth < len(radii); depth++ {
+			radii[depth] = math.Max(radii[depth-1]+layoutRingGap, layoutLeafArc/narrowest[depth])
+		}
+
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX := math.Inf(-1)
+		for _, p := range placed {
+			angle := (p.start + p.end) / 2
+			node := &g.Nodes[index[p.id]]
+			node.X = radii[p.depth]*math.Cos(angle) - defaultNodeWidth/2
+			node.Y = radii[p.depth]*math.Sin(angle) - defaultNodeHeight/2
+			node.HasPosition = true
+			minX, minY = math.Min(minX, node.X), math.Min(minY, node.Y)
+			maxX = math.Max(maxX, node.X+defaultNodeWidth)
+		}
+		for _, p := range placed {
+			node := &g.Nodes[index[p.id]]
+			node.X += offsetX - minX
+			node.Y -= minY
+		}
+		offsetX += maxX - minX + 2*layoutNodeGap
+	}
+}
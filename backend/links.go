@@ -0,0 +1,289 @@
+// Cross-graph node links (node data.links) and the backlink index behind them.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// nodeLink is one entry of a node's data.links; an empty GraphID points into
+// the same graph.
+type nodeLink struct {
+	GraphID string `json:"graphId"`
+	NodeID  string `json:"nodeId"`
+}
+
+// nodeLinkRow is a link flattened for the node_links table.
+type nodeLinkRow struct {
+	SourceNodeID  string `json:"nodeId"`
+	SourceLabel   string `json:"-"`
+	TargetGraphID string `json:"targetGraphId"`
+	TargetNodeID  string `json:"targetNodeId"`
+}
+
+type backlink struct {
+	GraphID   string    `json:"graphId"`
+	GraphName string    `json:"graphName"`
+	NodeID    string    `json:"nodeId"`
+	NodeLabel string    `json:"nodeLabel"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// invalidLinksError rejects a save whose new links point to nodes that do not
+// exist or belong to another user. Nothing is written when it is returned.
+type invalidLinksError struct {
+	Links []nodeLinkRow
+}
+
+func (e *invalidLinksError) Error() string {
+	return fmt.Sprintf("%d node link(s) point to missing nodes", len(e.Links))
+}
+
+// writeInvalidLinks writes a 422 for invalidLinksError and reports whether it did.
+func writeInvalidLinks(w http.ResponseWriter, err error) bool {
+	var linksErr *invalidLinksError
+	if !errors.As(err, &linksErr) {
+		return false
+	}
+	writeJSONStatus(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":   "invalid_links",
+		"message": "some node links point to nodes that do not exist",
+		"links":   linksErr.Links,
+	})
+	return true
+}
+
+// extractNodeLinks collects data.links from every node, resolving empty graph
+// ids to graphID and dropping duplicates.
+func extractNodeLinks(graphID string, nodes json.RawMessage) []nodeLinkRow {
+	var parsed []struct {
+		ID   string `json:"id"`
+		Data struct {
+			Label string     `json:"label"`
+			Links []nodeLink `json:"links"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(nodes, &parsed); err != nil {
+		return nil
+	}
+
+	var rows []nodeLinkRow
+	for _, node := range parsed {
+		if node.ID == "" {
+			continue
+		}
+		for _, link := range node.Data.Links {
+			row := nodeLinkRow{
+				SourceNodeID:  node.ID,
+				SourceLabel:   node.Data.Label,
+				TargetGraphID: strings.TrimSpace(link.GraphID),
+				TargetNodeID:  strings.TrimSpace(link.NodeID),
+			}
+			if row.TargetGraphID == "" {
+				row.TargetGraphID = graphID
+			}
+			if row.TargetNodeID == "" || slices.Contains(rows, row) {
+				continue
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// checkNodeLinks validates links that graphID does not already have. Links
+// that were accepted earlier are kept even if their target has since been
+// deleted, so unrelated edits never fail because of another graph.
+func checkNodeLinks(ctx context.Context, q querier, userID, graphID string, nodes json.RawMessage, links []nodeLinkRow) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	existing := make(map[nodeLink]struct{})
+	rows, err := q.Query(
+		ctx,
+		"SELECT target_graph_id, target_node_id FROM node_links WHERE user_id = $1 AND source_graph_id = $2",
+		userID,
+		graphID,
+	)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var target nodeLink
+		if err := rows.Scan(&target.GraphID, &target.NodeID); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[target] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	localIDs, _ := nodeIDsOf(nodes)
+	var invalid []nodeLinkRow
+	var graphIDs, nodeIDs []string
+	for _, link := range links {
+		if _, ok := existing[nodeLink{GraphID: link.TargetGraphID, NodeID: link.TargetNodeID}]; ok {
+			continue
+		}
+		if link.TargetGraphID == graphID {
+			if !slices.Contains(localIDs, link.TargetNodeID) {
+				invalid = append(invalid, link)
+			}
+			continue
+		}
+		graphIDs = append(graphIDs, link.TargetGraphID)
+		nodeIDs = append(nodeIDs, link.TargetNodeID)
+	}
+
+	if len(graphIDs) > 0 {
+		missing := make(map[nodeLink]struct{})
+		rows, err := q.Query(
+			ctx,
+			`SELECT t.graph_id, t.node_id
+			 FROM unnest($2::text[], $3::text[]) AS t(graph_id, node_id)
+			 WHERE NOT EXISTS (
+			   SELECT 1 FROM graphs g
+			   WHERE g.id = t.graph_id AND g.user_id = $1
+			     AND g.data->'nodes' @> jsonb_build_array(jsonb_build_object('id', t.node_id))
+			 )`,
+			userID,
+			graphIDs,
+			nodeIDs,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var target nodeLink
+			if err := rows.Scan(&target.GraphID, &target.NodeID); err != nil {
+				rows.Close()
+				return err
+			}
+			missing[target] = struct{}{}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, link := range links {
+			if _, ok := missing[nodeLink{GraphID: link.TargetGraphID, NodeID: link.TargetNodeID}]; ok {
+				invalid = append(invalid, link)
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return &invalidLinksError{Links: invalid}
+	}
+	return nil
+}
+
+// replaceNodeLinks rewrites the backlink index rows owned by graphID.
+func replaceNodeLinks(ctx context.Context, q querier, userID, graphID string, links []nodeLinkRow) error {
+	if _, err := q.Exec(ctx, "DELETE FROM node_links WHERE user_id = $1 AND source_graph_id = $2", userID, graphID); err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	sourceNodes := make([]string, len(links))
+	labels := make([]string, len(links))
+	targetGraphs := make([]string, len(links))
+	targetNodes := make([]string, len(links))
+	for i, link := range links {
+		sourceNodes[i] = link.SourceNodeID
+		labels[i] = link.SourceLabel
+		targetGraphs[i] = link.TargetGraphID
+		targetNodes[i] = link.TargetNodeID
+	}
+	_, err := q.Exec(
+		ctx,
+		`INSERT INTO node_links (user_id, source_graph_id, source_node_id, source_label, target_graph_id, target_node_id)
+		 SELECT $1, $2, t.source_node_id, t.source_label, t.target_graph_id, t.target_node_id
+		 FROM unnest($3::text[], $4::text[], $5::text[], $6::text[]) AS t(source_node_id, source_label, target_graph_id, target_node_id)`,
+		userID,
+		graphID,
+		sourceNodes,
+		labels,
+		targetGraphs,
+		targetNodes,
+	)
+	return err
+}
+
+// GET /api/graphs/:id/nodes/:nodeId/backlinks: nodes in any of the caller's graphs linking here.
+func (s *server) handleBacklinks(w http.ResponseWriter, r *http.Request, graphID, nodeID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	exists, _, err := graphHasNode(ctx, s.pool, userID, graphID, nodeID)
+	if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to load backlinks", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	}
+
+	// Joining graphs hides rows left behind by deleted source graphs.
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT l.source_graph_id, g.name, l.source_node_id, l.source_label, g.updated_at
+		 FROM node_links l
+		 JOIN graphs g ON g.id = l.source_graph_id AND g.user_id = l.user_id
+		 WHERE l.user_id = $1 AND l.target_graph_id = $2 AND l.target_node_id = $3
+		 ORDER BY g.updated_at DESC, l.source_node_id`,
+		userID,
+		graphID,
+		nodeID,
+	)
+	if err != nil {
+		log.Printf("failed to list backlinks: %v", err)
+		http.Error(w, "failed to load backlinks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	backlinks := []backlink{}
+	for rows.Next() {
+		var link backlink
+		if err := rows.Scan(&link.GraphID, &link.GraphName, &link.NodeID, &link.NodeLabel, &link.UpdatedAt); err != nil {
+			log.Printf("failed to scan backlink: %v", err)
+			http.Error(w, "failed to load backlinks", http.StatusInternalServerError)
+			return
+		}
+		backlinks = append(backlinks, link)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list backlinks: %v", err)
+		http.Error(w, "failed to load backlinks", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, backlinks)
+}
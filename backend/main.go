@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -77,6 +78,49 @@ func main() {
 		log.Print("SUPABASE_JWT_SECRET not set; legacy HS256 tokens will not be accepted")
 	}
 
+	plans, err := parsePlans(os.Getenv("QUOTA_PLANS"))
+	if err != nil {
+		log.Fatalf("invalid QUOTA_PLANS: %v", err)
+	}
+	defaultPlan := strings.TrimSpace(os.Getenv("DEFAULT_PLAN"))
+	if defaultPlan == "" {
+		defaultPlan = defaultPlanName
+	}
+	if _, ok := plans[defaultPlan]; !ok {
+		log.Fatalf("DEFAULT_PLAN %q is not a configured plan", defaultPlan)
+	}
+
+	maxDecodedBodyBytes := int64(defaultMaxDecodedBodyBytes)
+	if raw := strings.TrimSpace(os.Getenv("MAX_DECODED_BODY_BYTES")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("invalid MAX_DECODED_BODY_BYTES: %q", raw)
+		}
+		maxDecodedBodyBytes = parsed
+	}
+
+	blobs, err := newBlobStoreFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure blob store: %v", err)
+	}
+	maxAttachmentBytes := int64(defaultMaxAttachmentBytes)
+	if raw := strings.TrimSpace(os.Getenv("MAX_ATTACHMENT_BYTES")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("invalid MAX_ATTACHMENT_BYTES: %q", raw)
+		}
+		maxAttachmentBytes = parsed
+	}
+
+	maxImportJobBytes := int64(defaultMaxImportJobBytes)
+	if raw := strings.TrimSpace(os.Getenv("MAX_IMPORT_JOB_BYTES")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("invalid MAX_IMPORT_JOB_BYTES: %q", raw)
+		}
+		maxImportJobBytes = parsed
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 
 	pool, err := pgxpool.New(ctx, databaseURL)
@@ -111,13 +155,31 @@ func main() {
 		modelServerAPIKey:   modelServerAPIKey,
 		supabaseJWTSecret:   supabaseJWTSecret,
 		jwkCache:            make(map[string]jwkCacheEntry),
+		plans:               plans,
+		defaultPlan:         defaultPlan,
+		maxDecodedBodyBytes: maxDecodedBodyBytes,
+		blobs:               blobs,
+		maxAttachmentBytes:  maxAttachmentBytes,
+		maxImportJobBytes:   maxImportJobBytes,
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", srv.handleHealth)
-	mux.Handle("/api/graph", srv.withCORS(http.HandlerFunc(srv.handleGraph)))
-	mux.Handle("/api/graphs", srv.withCORS(http.HandlerFunc(srv.handleGraphs)))
-	mux.Handle("/api/graphs/", srv.withCORS(http.HandlerFunc(srv.handleGraphByID)))
+	mux.Handle("/api/graph", srv.withCORS(withCompression(http.HandlerFunc(srv.handleGraph))))
+	mux.Handle("/api/graphs", srv.withCORS(withCompression(http.HandlerFunc(srv.handleGraphs))))
+	mux.Handle("/api/graphs/", srv.withCORS(withCompression(http.HandlerFunc(srv.handleGraphByID))))
+	mux.Handle("/api/graphs/bulk", srv.withCORS(http.HandlerFunc(srv.handleBulkGraphs)))
+	mux.Handle("/api/graphs/import", srv.withCORS(withCompression(http.HandlerFunc(srv.handleImportGraph))))
+	mux.Handle("/api/graphs/import/jobs", srv.withCORS(withCompression(http.HandlerFunc(srv.handleImportJobs))))
+	mux.Handle("/api/graphs/import/jobs/", srv.withCORS(http.HandlerFunc(srv.handleImportJobByID)))
+	mux.Handle("/api/graphs/export", srv.withCORS(http.HandlerFunc(srv.handleExportGraphs)))
+	mux.Handle("/api/templates", srv.withCORS(withCompression(http.HandlerFunc(srv.handleTemplates))))
+	mux.Handle("/api/templates/", srv.withCORS(withCompression(http.HandlerFunc(srv.handleTemplateByID))))
+	mux.Handle("/api/account/export", srv.withCORS(http.HandlerFunc(srv.handleAccountExport)))
+	mux.Handle("/api/account/import", srv.withCORS(http.HandlerFunc(srv.handleAccountImport)))
+	mux.Handle("/api/usage", srv.withCORS(http.HandlerFunc(srv.handleUsage)))
+	mux.Handle("/api/schema/graph.json", srv.withCORS(http.HandlerFunc(srv.handleGraphSchema)))
+	mux.Handle("/api/sync", srv.withCORS(withCompression(http.HandlerFunc(srv.handleSync))))
 	mux.Handle("/api/ai/graph", srv.withCORS(http.HandlerFunc(srv.handleAIGraph)))
 
 	log.Printf("backend ready on :%s", port)
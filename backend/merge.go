@@ -0,0 +1,268 @@
+// Three-way merge of graph payloads keyed by node/edge/item IDs.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// mergeConflict records a value changed differently on both sides. Absent
+// values (added or deleted on one side) are omitted from the JSON.
+type mergeConflict struct {
+	Path   string          `json:"path"`
+	Base   json.RawMessage `json:"base,omitempty"`
+	Client json.RawMessage `json:"client,omitempty"`
+	Server json.RawMessage `json:"server,omitempty"`
+}
+
+type graphMerger struct {
+	conflicts []mergeConflict
+}
+
+// mergeGraphJSON merges client changes into the server copy relative to base.
+// Conflicting values resolve to the server side and are reported so the
+// client can decide what to keep.
+func mergeGraphJSON(base, client, server json.RawMessage) (json.RawMessage, []mergeConflict) {
+	m := &graphMerger{conflicts: []mergeConflict{}}
+	merged := m.mergeValue("", base, client, server)
+	return m.dropDanglingEdges(merged, base, client, server), m.conflicts
+}
+
+// dropDanglingEdges removes merged edges whose source or target node was
+// deleted on the other side (e.g. the client linked a node the server
+// removed) and reports each one as a conflict.
+func (m *graphMerger) dropDanglingEdges(merged, base, client, server json.RawMessage) json.RawMessage {
+	fields := decodeJSONObject(merged)
+	if !isIDList(fields["edges"]) {
+		return merged
+	}
+	_, nodes := decodeIDList(fields["nodes"])
+	// Nodes of any side that did not survive the merge. Endpoints missing on
+	// every side were already dangling and are left alone.
+	deleted := map[string]struct{}{}
+	for _, side := range []json.RawMessage{base, client, server} {
+		order, _ := decodeIDList(decodeJSONObject(side)["nodes"])
+		for _, id := range order {
+			if _, ok := nodes[id]; !ok {
+				deleted[id] = struct{}{}
+			}
+		}
+	}
+	if len(deleted) == 0 {
+		return merged
+	}
+	_, baseEdges := decodeIDList(decodeJSONObject(base)["edges"])
+	_, clientEdges := decodeIDList(decodeJSONObject(client)["edges"])
+	_, serverEdges := decodeIDList(decodeJSONObject(server)["edges"])
+
+	edgeOrder, edges := decodeIDList(fields["edges"])
+	kept := make([]json.RawMessage, 0, len(edgeOrder))
+	for _, id := range edgeOrder {
+		var endpoints struct {
+			Source string `json:"source"`
+			Target string `json:"target"`
+		}
+		_ = json.Unmarshal(edges[id], &endpoints)
+		_, lostSource := deleted[endpoints.Source]
+		_, lostTarget := deleted[endpoints.Target]
+		if !lostSource && !lostTarget {
+			kept = append(kept, edges[id])
+			continue
+		}
+		m.conflicts = append(m.conflicts, mergeConflict{
+			Path:   "edges[" + id + "]",
+			Base:   baseEdges[id],
+			Client: clientEdges[id],
+			Server: serverEdges[id],
+		})
+	}
+	if len(kept) == len(edgeOrder) {
+		return merged
+	}
+
+	encoded, err := json.Marshal(kept)
+	if err != nil {
+		return merged
+	}
+	fields["edges"] = encoded
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return merged
+	}
+	return data
+}
+
+// mergeValue returns the merged value or nil when the value should be absent.
+func (m *graphMerger) mergeValue(path string, base, client, server json.RawMessage) json.RawMessage {
+	switch {
+	case jsonEqual(client, server):
+		return client
+	case jsonEqual(base, client):
+		return server
+	case jsonEqual(base, server):
+		return client
+	}
+
+	if isJSONObject(client) && isJSONObject(server) && (base == nil || isJSONObject(base)) {
+		return m.mergeObject(path, base, client, server)
+	}
+	if isIDList(client) && isIDList(server) && (base == nil || isIDList(base)) {
+		return m.mergeList(path, base, client, server)
+	}
+
+	m.conflicts = append(m.conflicts, mergeConflict{
+		Path:   path,
+		Base:   base,
+		Client: client,
+		Server: server,
+	})
+	return server
+}
+
+func (m *graphMerger) mergeObject(path string, base, client, server json.RawMessage) json.RawMessage {
+	baseFields := decodeJSONObject(base)
+	clientFields := decodeJSONObject(client)
+	serverFields := decodeJSONObject(server)
+
+	merged := make(map[string]json.RawMessage, len(serverFields))
+	keys := make(map[string]struct{})
+	for _, fields := range []map[string]json.RawMessage{baseFields, clientFields, serverFields} {
+		for key := range fields {
+			keys[key] = struct{}{}
+		}
+	}
+	// Keys are visited in order so conflicts are reported in a stable order.
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		if value := m.mergeValue(fieldPath, baseFields[key], clientFields[key], serverFields[key]); value != nil {
+			merged[key] = value
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return server
+	}
+	return data
+}
+
+// mergeList merges arrays of objects by their "id" field. Server order is
+// kept and entries added by the client are appended.
+func (m *graphMerger) mergeList(path string, base, client, server json.RawMessage) json.RawMessage {
+	_, baseByID := decodeIDList(base)
+	clientOrder, clientByID := decodeIDList(client)
+	serverOrder, serverByID := decodeIDList(server)
+
+	merged := make([]json.RawMessage, 0, len(serverOrder)+len(clientOrder))
+	for _, id := range serverOrder {
+		entryPath := path + "[" + id + "]"
+		if value := m.mergeValue(entryPath, baseByID[id], clientByID[id], serverByID[id]); value != nil {
+			merged = append(merged, value)
+		}
+	}
+	for _, id := range clientOrder {
+		if _, ok := serverByID[id]; ok {
+			continue
+		}
+		entryPath := path + "[" + id + "]"
+		if value := m.mergeValue(entryPath, baseByID[id], clientByID[id], nil); value != nil {
+			merged = append(merged, value)
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return server
+	}
+	return data
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return bytes.Equal(canonicalJSON(a), canonicalJSON(b))
+}
+
+// canonicalJSON re-encodes a value so key order and whitespace do not matter.
+func canonicalJSON(raw json.RawMessage) []byte {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return raw
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+func isJSONObject(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func decodeJSONObject(raw json.RawMessage) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if raw != nil {
+		_ = json.Unmarshal(raw, &fields)
+	}
+	return fields
+}
+
+// isIDList reports whether raw is an array whose entries all carry a string id.
+func isIDList(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return false
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(trimmed, &entries); err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entryID(entry) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeIDList(raw json.RawMessage) ([]string, map[string]json.RawMessage) {
+	byID := map[string]json.RawMessage{}
+	if raw == nil {
+		return nil, byID
+	}
+	var entries []json.RawMessage
+	_ = json.Unmarshal(raw, &entries)
+	order := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		id := entryID(entry)
+		if _, seen := byID[id]; seen {
+			continue
+		}
+		byID[id] = entry
+		order = append(order, id)
+	}
+	return order, byID
+}
+
+func entryID(raw json.RawMessage) string {
+	if !isJSONObject(raw) {
+		return ""
+	}
+	var entry struct {
+		ID any `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return ""
+	}
+	id, _ := entry.ID.(string)
+	return strings.TrimSpace(id)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeMerged(t *testing.T, raw json.RawMessage) map[string]any {
+	t.Helper()
+	var value map[string]any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		t.Fatalf("merged graph is not an object: %s", raw)
+	}
+	return value
+}
+
+func conflictPaths(conflicts []mergeConflict) []string {
+	paths := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		paths[i] = conflict.Path
+	}
+	return paths
+}
+
+func TestMergeGraphJSONCombinesIndependentEdits(t *testing.T) {
+	base := json.RawMessage(`{"name":"G","nodes":[{"id":"a","label":"A"},{"id":"b","label":"B"}],"edges":[]}`)
+	client := json.RawMessage(`{"name":"G","nodes":[{"id":"a","label":"A2"},{"id":"b","label":"B"},{"id":"c","label":"C"}],"edges":[]}`)
+	server := json.RawMessage(`{"name":"Renamed","nodes":[{"id":"a","label":"A"},{"id":"b","label":"B3"}],"edges":[{"id":"e","source":"a","target":"b"}]}`)
+
+	merged, conflicts := mergeGraphJSON(base, client, server)
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+	want := map[string]any{
+		"name": "Renamed",
+		"nodes": []any{
+			map[string]any{"id": "a", "label": "A2"},
+			map[string]any{"id": "b", "label": "B3"},
+			map[string]any{"id": "c", "label": "C"},
+		},
+		"edges": []any{map[string]any{"id": "e", "source": "a", "target": "b"}},
+	}
+	if got := decodeMerged(t, merged); !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged = %v\nwant %v", got, want)
+	}
+}
+
+func TestMergeGraphJSONReportsConflictsInStableOrder(t *testing.T) {
+	base := json.RawMessage(`{"name":"G","kind":"note","nodes":[{"id":"a","label":"A","x":1,"y":1}],"edges":[]}`)
+	client := json.RawMessage(`{"name":"Client","kind":"map","nodes":[{"id":"a","label":"client","x":2,"y":2}],"edges":[]}`)
+	server := json.RawMessage(`{"name":"Server","kind":"tree","nodes":[{"id":"a","label":"server","x":3,"y":3}],"edges":[]}`)
+
+	want := []string{"kind", "name", "nodes[a].label", "nodes[a].x", "nodes[a].y"}
+	for i := 0; i < 20; i++ {
+		merged, conflicts := mergeGraphJSON(base, client, server)
+		if got := conflictPaths(conflicts); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: conflict paths = %v, want %v", i, got, want)
+		}
+		if got := decodeMerged(t, merged)["name"]; got != "Server" {
+			t.Fatalf("conflicts should resolve to the server side, name = %v", got)
+		}
+	}
+}
+
+func TestMergeGraphJSONDropsEdgesToDeletedNodes(t *testing.T) {
+	base := json.RawMessage(`{"nodes":[{"id":"a"},{"id":"b"},{"id":"c"}],"edges":[]}`)
+	// The client links a to b while the server deletes b and links a to c;
+	// the client deletes c.
+	client := json.RawMessage(`{"nodes":[{"id":"a"},{"id":"b"}],"edges":[{"id":"ab","source":"a","target":"b"}]}`)
+	server := json.RawMessage(`{"nodes":[{"id":"a"},{"id":"c"}],"edges":[{"id":"ac","source":"a","target":"c"}]}`)
+
+	merged, conflicts := mergeGraphJSON(base, client, server)
+	if got := decodeMerged(t, merged)["edges"]; !reflect.DeepEqual(got, []any{}) {
+		t.Fatalf("dangling edges kept: %v", got)
+	}
+	if got := conflictPaths(conflicts); !reflect.DeepEqual(got, []string{"edges[ac]", "edges[ab]"}) {
+		t.Fatalf("conflict paths = %v", got)
+	}
+	if conflicts[0].Server == nil || conflicts[0].Client != nil || conflicts[1].Client == nil || conflicts[1].Server != nil {
+		t.Fatalf("conflicts should carry the side that added the edge: %+v", conflicts)
+	}
+}
+
+func TestMergeGraphJSONKeepsPreexistingDanglingEdges(t *testing.T) {
+	graph := json.RawMessage(`{"nodes":[{"id":"a"}],"edges":[{"id":"e","source":"a","target":"gone"}]}`)
+	client := json.RawMessage(`{"nodes":[{"id":"a","label":"new"}],"edges":[{"id":"e","source":"a","target":"gone"}]}`)
+	merged, conflicts := mergeGraphJSON(graph, client, graph)
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+	if edges := decodeMerged(t, merged)["edges"].([]any); len(edges) != 1 {
+		t.Fatalf("edges = %v", edges)
+	}
+}
+
+func TestMergeGraphJSONDeleteVersusEdit(t *testing.T) {
+	base := json.RawMessage(`{"nodes":[{"id":"a","label":"A"}],"edges":[]}`)
+	client := json.RawMessage(`{"nodes":[],"edges":[]}`)
+	server := json.RawMessage(`{"nodes":[{"id":"a","label":"edited"}],"edges":[]}`)
+
+	merged, conflicts := mergeGraphJSON(base, client, server)
+	if got := conflictPaths(conflicts); !reflect.DeepEqual(got, []string{"nodes[a]"}) {
+		t.Fatalf("conflict paths = %v", got)
+	}
+	if conflicts[0].Client != nil {
+		t.Fatalf("a deleted side should be absent, got %s", conflicts[0].Client)
+	}
+	if nodes := decodeMerged(t, merged)["nodes"].([]any); len(nodes) != 1 {
+		t.Fatalf("the server edit should be kept, nodes = %v", nodes)
+	}
+}
+
+func TestMergeGraphJSONMergesNestedItemsByID(t *testing.T) {
+	base := json.RawMessage(`{"name":"G","nodes":[{"id":"a","data":{"items":[{"id":"i","title":"I"},{"id":"j","title":"J"}]}}],"edges":[]}`)
+	client := json.RawMessage(`{"name":"G","nodes":[{"id":"a","data":{"items":[{"id":"i","title":"I2"},{"id":"j","title":"J"}]}}],"edges":[]}`)
+	server := json.RawMessage(`{"name":"G","nodes":[{"id":"a","data":{"items":[{"id":"i","title":"I3"},{"id":"j","title":"J3"},{"id":"k","title":"K"}]}}],"edges":[]}`)
+
+	merged, conflicts := mergeGraphJSON(base, client, server)
+	if got, want := conflictPaths(conflicts), []string{"nodes[a].data.items[i].title"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("conflict paths = %v, want %v", got, want)
+	}
+	items := decodeMerged(t, merged)["nodes"].([]any)[0].(map[string]any)["data"].(map[string]any)["items"]
+	want := []any{
+		map[string]any{"id": "i", "title": "I3"},
+		map[string]any{"id": "j", "title": "J3"},
+		map[string]any{"id": "k", "title": "K"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("merged items = %v, want %v", items, want)
+	}
+}
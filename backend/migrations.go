@@ -16,12 +16,16 @@ func ensureSchema(ctx context.Context, pool *pgxpool.Pool) error {
 			kind text NOT NULL DEFAULT 'note',
 			data jsonb NOT NULL,
 			node_notes jsonb NOT NULL DEFAULT '[]'::jsonb,
+			tags text[] NOT NULL DEFAULT '{}',
+			folder text NOT NULL DEFAULT '',
 			updated_at timestamptz NOT NULL DEFAULT now()
 		)`,
 		`ALTER TABLE graphs ADD COLUMN IF NOT EXISTS user_id text`,
 		`ALTER TABLE graphs ADD COLUMN IF NOT EXISTS name text`,
 		`ALTER TABLE graphs ADD COLUMN IF NOT EXISTS kind text`,
 		`ALTER TABLE graphs ADD COLUMN IF NOT EXISTS node_notes jsonb`,
+		`ALTER TABLE graphs ADD COLUMN IF NOT EXISTS tags text[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE graphs ADD COLUMN IF NOT EXISTS folder text NOT NULL DEFAULT ''`,
 		`UPDATE graphs
 		 SET name = coalesce(nullif(trim(data->>'name'), ''), 'Untitled Graph')
 		 WHERE name IS NULL OR trim(name) = ''`,
@@ -57,6 +61,88 @@ func ensureSchema(ctx context.Context, pool *pgxpool.Pool) error {
 		`CREATE INDEX IF NOT EXISTS graphs_user_id_idx ON graphs(user_id)`,
 		`CREATE INDEX IF NOT EXISTS graphs_user_kind_updated_idx ON graphs(user_id, kind, updated_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS graphs_node_notes_idx ON graphs USING GIN(node_notes)`,
+		`CREATE INDEX IF NOT EXISTS graphs_tags_idx ON graphs USING GIN(tags)`,
+		`CREATE TABLE IF NOT EXISTS user_plans (
+			user_id text PRIMARY KEY,
+			plan text NOT NULL,
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		`CREATE TABLE IF NOT EXISTS node_attachments (
+			id text PRIMARY KEY,
+			user_id text NOT NULL,
+			graph_id text NOT NULL,
+			node_id text NOT NULL,
+			file_name text NOT NULL,
+			content_type text NOT NULL,
+			size_bytes bigint NOT NULL,
+			storage_key text NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		`CREATE INDEX IF NOT EXISTS node_attachments_graph_node_idx ON node_attachments(user_id, graph_id, node_id)`,
+		`CREATE TABLE IF NOT EXISTS graph_client_ids (
+			user_id text NOT NULL,
+			client_id text NOT NULL,
+			graph_id text NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			PRIMARY KEY (user_id, client_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS graph_templates (
+			id text PRIMARY KEY,
+			user_id text NOT NULL,
+			name text NOT NULL,
+			description text NOT NULL DEFAULT '',
+			kind text NOT NULL DEFAULT 'note',
+			variables text[] NOT NULL DEFAULT '{}',
+			data jsonb NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		`CREATE INDEX IF NOT EXISTS graph_templates_user_idx ON graph_templates(user_id, updated_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS node_links (
+			user_id text NOT NULL,
+			source_graph_id text NOT NULL,
+			source_node_id text NOT NULL,
+			source_label text NOT NULL DEFAULT '',
+			target_graph_id text NOT NULL,
+			target_node_id text NOT NULL,
+			PRIMARY KEY (user_id, source_graph_id, source_node_id, target_graph_id, target_node_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS node_links_target_idx ON node_links(user_id, target_graph_id, target_node_id)`,
+		`CREATE TABLE IF NOT EXISTS graph_wikilinks (
+			user_id text NOT NULL,
+			graph_id text NOT NULL,
+			source_node_id text NOT NULL,
+			item_id text NOT NULL DEFAULT '',
+			target text NOT NULL,
+			position int NOT NULL,
+			PRIMARY KEY (user_id, graph_id, source_node_id, item_id, target)
+		)`,
+		`CREATE TABLE IF NOT EXISTS graph_comments (
+			id text PRIMARY KEY,
+			user_id text NOT NULL,
+			graph_id text NOT NULL,
+			node_id text NOT NULL,
+			item_id text NOT NULL DEFAULT '',
+			parent_id text,
+			author_id text NOT NULL,
+			author_name text NOT NULL DEFAULT '',
+			body text NOT NULL,
+			resolved boolean NOT NULL DEFAULT false,
+			resolved_at timestamptz,
+			resolved_by text,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		`CREATE INDEX IF NOT EXISTS graph_comments_graph_idx ON graph_comments(user_id, graph_id, node_id)`,
+		`CREATE TABLE IF NOT EXISTS import_jobs (
+			id text PRIMARY KEY,
+			user_id text NOT NULL,
+			status text NOT NULL,
+			state jsonb NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			updated_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		`CREATE INDEX IF NOT EXISTS import_jobs_user_idx ON import_jobs(user_id, status)`,
 	}
 
 	for _, statement := range statements {
@@ -0,0 +1,129 @@
+// Shared mind map model for the FreeMind and XMind importers: a topic tree
+// becomes nodes laid out radially, with edges along the hierarchy.
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// mindTopic is one topic of a mind map. ID is the file's own identifier,
+// used to resolve cross links; Notes is Markdown.
+type mindTopic struct {
+	ID       string
+	Title    string
+	Notes    string
+	Children []mindTopic
+}
+
+// mindLink is a cross link between two topics (FreeMind arrow links, XMind
+// relationships).
+type mindLink struct {
+	From, To string
+	Label    string
+}
+
+type mindMap struct {
+	// Name is used when the central topic has no title.
+	Name string
+	// Roots holds the central topic followed by any floating topics.
+	Roots    []mindTopic
+	Links    []mindLink
+	Warnings []string
+}
+
+// graph converts the map. Topics deeper than ?depth= levels (the central
+// topic is level 1; unset keeps every topic as a node) collapse into the
+// items tree of their nearest node. Hierarchy edges are undirected, cross
+// links directed.
+func (m mindMap) graph(opts url.Values) (ixGraph, error) {
+	depth := 0
+	if value := opts.Get("depth"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return ixGraph{}, importErrorf("depth must be a positive number")
+		}
+		depth = parsed
+	}
+	if len(m.Roots) == 0 {
+		return ixGraph{}, importErrorf("mind map has no topics")
+	}
+
+	g := ixGraph{Name: firstNonEmpty(strings.TrimSpace(m.Roots[0].Title), strings.TrimSpace(m.Name)), Warnings: m.Warnings}
+	// nodeOf maps topic IDs to the node that shows them, including topics
+	// collapsed into items.
+	nodeOf := map[string]string{}
+	children := map[string][]string{}
+	var roots []string
+
+	var toItem func(topic mindTopic, nodeID string) ixItem
+	toItem = func(topic mindTopic, nodeID string) ixItem {
+		if topic.ID != "" {
+			nodeOf[topic.ID] = nodeID
+		}
+		item := ixItem{
+			ID:        newID("item"),
+			Title:     strings.TrimSpace(topic.Title),
+			Notes:     []ixNote{},
+			ItemNotes: markdownToEditorJS(topic.Notes),
+			Children:  []ixItem{},
+		}
+		for _, child := range topic.Children {
+			item.Children = append(item.Children, toItem(child, nodeID))
+		}
+		return item
+	}
+	var walk func(topic mindTopic, parentID string, level int)
+	walk = func(topic mindTopic, parentID string, level int) {
+		node := ixNode{
+			ID:        newID("node"),
+			Label:     strings.TrimSpace(topic.Title),
+			NodeNotes: markdownToEditorJS(topic.Notes),
+		}
+		if topic.ID != "" {
+			nodeOf[topic.ID] = node.ID
+		}
+		if parentID == "" {
+			roots = append(roots, node.ID)
+		} else {
+			children[parentID] = append(children[parentID], node.ID)
+			g.Edges = append(g.Edges, ixEdge{Source: parentID, Target: node.ID})
+		}
+		collapse := depth > 0 && level >= depth
+		for _, child := range topic.Children {
+			if collapse {
+				node.Items = append(node.Items, toItem(child, node.ID))
+			}
+		}
+		g.Nodes = append(g.Nodes, node)
+		if !collapse {
+			for _, child := range topic.Children {
+				walk(child, node.ID, level+1)
+			}
+		}
+	}
+	for _, root := range m.Roots {
+		walk(root, "", 1)
+	}
+
+	missing := 0
+	for _, link := range m.Links {
+		source, target := nodeOf[link.From], nodeOf[link.To]
+		if source == "" || target == "" {
+			missing++
+			continue
+		}
+		if source == target {
+			continue
+		}
+		g.Edges = append(g.Edges, ixEdge{Source: source, Target: target, Label: strings.TrimSpace(link.Label), Directed: true})
+	}
+	if missing > 0 {
+		g.Warnings = append(g.Warnings, fmt.Sprintf("skipped %d links to missing topics", missing))
+	}
+
+	g.layoutRadial(roots, children)
+	return g, nil
+}
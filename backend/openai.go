@@ -144,10 +144,16 @@ type aiNote struct {
 }
 
 type aiEdge struct {
-	ID     string `json:"id"`
-	Source string `json:"source"`
-	Target string `json:"target"`
-	Type   string `json:"type,omitempty"`
+	ID     string      `json:"id"`
+	Source string      `json:"source"`
+	Target string      `json:"target"`
+	Type   string      `json:"type,omitempty"`
+	Label  string      `json:"label,omitempty"`
+	Data   *aiEdgeData `json:"data,omitempty"`
+}
+
+type aiEdgeData struct {
+	Directed bool `json:"directed"`
 }
 
 // POST /api/ai/graph: validate input, call OpenAI, sanitize graph payload.
@@ -542,121 +548,17 @@ Rules:
 - Provide position.x and position.y for each node.
 - Use type="group" for containers and set child nodes' parentNode to the group id.
 - Include items/notes only if they add value; otherwise use empty arrays.
-- Always include all fields in the schema; use null for optional fields when unused (parentNode, extent, style, position3d).
-- Use edge type "smoothstep".
+- Always include all fields in the schema; use null for optional fields when unused (parentNode, style, position3d, edge label and data).
+- Use edge type "smoothstep"; set data.directed to true for edges with a direction.
 - Edges must reference existing node ids.`,
 		maxNodes,
 	)
 }
 
-// JSON schema used for strict structured output from OpenAI.
+// JSON schema used for strict structured output from OpenAI, derived from
+// the stored GraphPayload schema (see aiGraphSchema).
 func graphSchema() map[string]any {
-	return map[string]any{
-		"type":                 "object",
-		"additionalProperties": false,
-		"properties": map[string]any{
-			"name": map[string]any{
-				"type": "string",
-			},
-			"nodes": map[string]any{
-				"type": "array",
-				"items": map[string]any{
-					"type":                 "object",
-					"additionalProperties": false,
-					"properties": map[string]any{
-						"id": map[string]any{
-							"type": "string",
-						},
-						"type": map[string]any{
-							"type": "string",
-						},
-						"position": map[string]any{
-							"type":                 "object",
-							"additionalProperties": false,
-							"properties": map[string]any{
-								"x": map[string]any{"type": "number"},
-								"y": map[string]any{"type": "number"},
-							},
-							"required": []string{"x", "y"},
-						},
-						"parentNode": map[string]any{
-							"type": []string{"string", "null"},
-						},
-						"extent": map[string]any{
-							"type": []string{"string", "null"},
-						},
-						"style": map[string]any{
-							"type":                 []string{"object", "null"},
-							"additionalProperties": false,
-							"properties": map[string]any{
-								"width":  map[string]any{"type": "number"},
-								"height": map[string]any{"type": "number"},
-							},
-							"required": []string{"width", "height"},
-						},
-						"data": map[string]any{
-							"type":                 "object",
-							"additionalProperties": false,
-							"properties": map[string]any{
-								"label": map[string]any{"type": "string"},
-								"position3d": map[string]any{
-									"type":                 []string{"object", "null"},
-									"additionalProperties": false,
-									"properties": map[string]any{
-										"x": map[string]any{"type": "number"},
-										"y": map[string]any{"type": "number"},
-										"z": map[string]any{"type": "number"},
-									},
-									"required": []string{"x", "y", "z"},
-								},
-								"items": map[string]any{
-									"type": "array",
-									"items": map[string]any{
-										"type":                 "object",
-										"additionalProperties": false,
-										"properties": map[string]any{
-											"id":    map[string]any{"type": "string"},
-											"title": map[string]any{"type": "string"},
-											"notes": map[string]any{
-												"type": "array",
-												"items": map[string]any{
-													"type":                 "object",
-													"additionalProperties": false,
-													"properties": map[string]any{
-														"id":    map[string]any{"type": "string"},
-														"title": map[string]any{"type": "string"},
-													},
-													"required": []string{"id", "title"},
-												},
-											},
-										},
-										"required": []string{"id", "title", "notes"},
-									},
-								},
-							},
-							"required": []string{"label", "position3d", "items"},
-						},
-					},
-					"required": []string{"id", "type", "position", "parentNode", "extent", "style", "data"},
-				},
-			},
-			"edges": map[string]any{
-				"type": "array",
-				"items": map[string]any{
-					"type":                 "object",
-					"additionalProperties": false,
-					"properties": map[string]any{
-						"id":     map[string]any{"type": "string"},
-						"source": map[string]any{"type": "string"},
-						"target": map[string]any{"type": "string"},
-						"type":   map[string]any{"type": "string"},
-					},
-					"required": []string{"id", "source", "target", "type"},
-				},
-			},
-		},
-		"required": []string{"name", "nodes", "edges"},
-	}
+	return aiGraphSchema()
 }
 
 func newID(prefix string) string {
@@ -0,0 +1,467 @@
+// Per-user storage quotas and payload limits configured by plan.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultPlanName = "free"
+
+// planLimits caps what a user may store. Zero means unlimited.
+type planLimits struct {
+	MaxGraphs        int64 `json:"maxGraphs"`
+	MaxStoredBytes   int64 `json:"maxStoredBytes"`
+	MaxNodesPerGraph int64 `json:"maxNodesPerGraph"`
+	MaxPayloadBytes  int64 `json:"maxPayloadBytes"`
+	MaxTemplates     int64 `json:"maxTemplates"`
+}
+
+// Built-in plans; QUOTA_PLANS (JSON object keyed by plan name) overrides or extends them.
+var defaultPlans = map[string]planLimits{
+	"free": {
+		MaxGraphs:        50,
+		MaxStoredBytes:   50 << 20,
+		MaxNodesPerGraph: 2000,
+		MaxPayloadBytes:  2 << 20,
+		MaxTemplates:     20,
+	},
+	"pro": {
+		MaxGraphs:        1000,
+		MaxStoredBytes:   1 << 30,
+		MaxNodesPerGraph: 20000,
+		MaxPayloadBytes:  16 << 20,
+		MaxTemplates:     500,
+	},
+}
+
+// quotaError is written as the JSON body of 403/413 responses.
+type quotaError struct {
+	Status  int    `json:"-"`
+	Error   string `json:"error"`
+	Quota   string `json:"quota"`
+	Plan    string `json:"plan"`
+	Limit   int64  `json:"limit"`
+	Used    int64  `json:"used"`
+	Message string `json:"message"`
+}
+
+// usageStats.StoredBytes counts graph data, attachments and templates;
+// AttachmentBytes is the attachment share of it.
+type usageStats struct {
+	Graphs            int64 `json:"graphs"`
+	Templates         int64 `json:"templates"`
+	StoredBytes       int64 `json:"storedBytes"`
+	AttachmentBytes   int64 `json:"attachmentBytes"`
+	LargestGraphNodes int64 `json:"largestGraphNodes"`
+}
+
+type usageResponse struct {
+	Plan   string     `json:"plan"`
+	Limits planLimits `json:"limits"`
+	Usage  usageStats `json:"usage"`
+}
+
+func parsePlans(raw string) (map[string]planLimits, error) {
+	plans := make(map[string]planLimits, len(defaultPlans))
+	for name, limits := range defaultPlans {
+		plans[name] = limits
+	}
+	if strings.TrimSpace(raw) == "" {
+		return plans, nil
+	}
+	var overrides map[string]planLimits
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, err
+	}
+	for name, limits := range overrides {
+		plans[strings.TrimSpace(name)] = limits
+	}
+	return plans, nil
+}
+
+// userPlan resolves the caller's plan from user_plans, falling back to the default plan.
+func (s *server) userPlan(ctx context.Context, userID string) (string, planLimits, error) {
+	var plan string
+	err := s.pool.QueryRow(ctx, "SELECT plan FROM user_plans WHERE user_id=$1", userID).Scan(&plan)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", planLimits{}, err
+	}
+	if limits, ok := s.plans[plan]; ok {
+		return plan, limits, nil
+	}
+	return s.defaultPlan, s.plans[s.defaultPlan], nil
+}
+
+// graphUpload is a decoded graph save request plus the caller's plan.
+type graphUpload struct {
+	plan    string
+	limits  planLimits
+	payload graphPayload
+	// empty is true when the request carried no body.
+	empty bool
+}
+
+// openGraphBody resolves the caller's plan and opens the (possibly compressed)
+// request body under the plan's payload limit. On failure it has already
+// written the response.
+func (s *server) openGraphBody(w http.ResponseWriter, r *http.Request, userID string) (string, planLimits, io.ReadCloser, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to load plan", http.StatusInternalServerError)
+		return "", planLimits{}, nil, false
+	}
+
+	body, err := openRequestBody(w, r, payloadWireLimit(limits), s.decodedBodyLimit(payloadWireLimit(limits)))
+	if errors.Is(err, errUnsupportedEncoding) {
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return "", planLimits{}, nil, false
+	}
+	if err != nil {
+		writeBodyError(w, r, plan, limits, err)
+		return "", planLimits{}, nil, false
+	}
+	return plan, limits, body, true
+}
+
+// readGraphBody reads a whole graph request body (used by batch endpoints).
+func (s *server) readGraphBody(w http.ResponseWriter, r *http.Request, userID string) (string, planLimits, []byte, bool) {
+	plan, limits, body, ok := s.openGraphBody(w, r, userID)
+	if !ok {
+		return "", planLimits{}, nil, false
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeBodyError(w, r, plan, limits, err)
+		return "", planLimits{}, nil, false
+	}
+	return plan, limits, data, true
+}
+
+// readGraphUpload streams a single graph payload, rejecting graphs over the
+// plan's node limit before the whole body is read.
+func (s *server) readGraphUpload(w http.ResponseWriter, r *http.Request, userID string) (graphUpload, bool) {
+	plan, limits, body, ok := s.openGraphBody(w, r, userID)
+	if !ok {
+		return graphUpload{}, false
+	}
+	defer body.Close()
+
+	payload, found, err := decodeGraphPayload(body, limits.MaxNodesPerGraph)
+	if errors.Is(err, errTooManyNodes) {
+		writeQuotaError(w, &quotaError{
+			Status:  http.StatusForbidden,
+			Error:   "quota_exceeded",
+			Quota:   "nodesPerGraph",
+			Plan:    plan,
+			Limit:   limits.MaxNodesPerGraph,
+			Used:    limits.MaxNodesPerGraph + 1,
+			Message: fmt.Sprintf("graph has more than %d nodes, the limit of the %q plan", limits.MaxNodesPerGraph, plan),
+		})
+		return graphUpload{}, false
+	}
+	if err != nil {
+		writeBodyError(w, r, plan, limits, err)
+		return graphUpload{}, false
+	}
+	return graphUpload{plan: plan, limits: limits, payload: payload, empty: !found}, true
+}
+
+// payloadWireLimit is the request body size a plan allows on the wire.
+func payloadWireLimit(limits planLimits) int64 {
+	if limits.MaxPayloadBytes <= 0 {
+		return maxUnlimitedBodyBytes
+	}
+	return limits.MaxPayloadBytes
+}
+
+// writeBodyError maps body read failures to 413 (with a quota body) or 400.
+// A limit other than the plan's wire limit is the decoded size cap of a
+// compressed body.
+func writeBodyError(w http.ResponseWriter, r *http.Request, plan string, limits planLimits, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) && maxErr.Limit != payloadWireLimit(limits) {
+		writeQuotaError(w, &quotaError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Error:   "payload_too_large",
+			Quota:   "decodedPayloadBytes",
+			Plan:    plan,
+			Limit:   maxErr.Limit,
+			Message: fmt.Sprintf("decompressed graph payload exceeds the %d byte limit of the %q plan", maxErr.Limit, plan),
+		})
+		return
+	}
+	if errors.As(err, &maxErr) {
+		writeQuotaError(w, &quotaError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Error:   "payload_too_large",
+			Quota:   "payloadBytes",
+			Plan:    plan,
+			Limit:   maxErr.Limit,
+			Used:    r.ContentLength,
+			Message: fmt.Sprintf("graph payload exceeds the %d byte limit of the %q plan", maxErr.Limit, plan),
+		})
+		return
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "invalid body", http.StatusBadRequest)
+}
+
+// checkGraphQuota verifies that storing data as graph id keeps the user within
+// their plan. It returns nil when the save is allowed. q must be the
+// transaction that stores the graph: the check takes a per-user lock held
+// until it ends, so concurrent saves cannot all pass the same count.
+func checkGraphQuota(ctx context.Context, q querier, userID, id, plan string, limits planLimits, payload graphPayload, dataLen int) (*quotaError, error) {
+	if limits.MaxNodesPerGraph > 0 {
+		nodes := int64(countJSONArray(payload.Nodes))
+		if nodes > limits.MaxNodesPerGraph {
+			return &quotaError{
+				Status:  http.StatusForbidden,
+				Error:   "quota_exceeded",
+				Quota:   "nodesPerGraph",
+				Plan:    plan,
+				Limit:   limits.MaxNodesPerGraph,
+				Used:    nodes,
+				Message: fmt.Sprintf("graph has %d nodes; the %q plan allows %d", nodes, plan, limits.MaxNodesPerGraph),
+			}, nil
+		}
+	}
+	if limits.MaxGraphs <= 0 && limits.MaxStoredBytes <= 0 {
+		return nil, nil
+	}
+	if err := lockUserQuota(ctx, q, userID); err != nil {
+		return nil, err
+	}
+
+	var graphs, storedBytes, currentBytes int64
+	var exists bool
+	err := q.QueryRow(
+		ctx,
+		`SELECT count(*),
+		        `+storedBytesQuery+`,
+		        coalesce(sum(octet_length(data::text)) FILTER (WHERE id = $2), 0),
+		        coalesce(bool_or(id = $2), false)
+		 FROM graphs
+		 WHERE user_id = $1`,
+		userID,
+		id,
+	).Scan(&graphs, &storedBytes, &currentBytes, &exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists && limits.MaxGraphs > 0 && graphs >= limits.MaxGraphs {
+		return &quotaError{
+			Status:  http.StatusForbidden,
+			Error:   "quota_exceeded",
+			Quota:   "graphs",
+			Plan:    plan,
+			Limit:   limits.MaxGraphs,
+			Used:    graphs,
+			Message: fmt.Sprintf("the %q plan allows %d graphs", plan, limits.MaxGraphs),
+		}, nil
+	}
+	projected := storedBytes - currentBytes + int64(dataLen)
+	if limits.MaxStoredBytes > 0 && projected > limits.MaxStoredBytes && projected > storedBytes {
+		return &quotaError{
+			Status:  http.StatusForbidden,
+			Error:   "quota_exceeded",
+			Quota:   "storedBytes",
+			Plan:    plan,
+			Limit:   limits.MaxStoredBytes,
+			Used:    storedBytes,
+			Message: fmt.Sprintf("saving would use %d bytes; the %q plan allows %d", projected, plan, limits.MaxStoredBytes),
+		}, nil
+	}
+	return nil, nil
+}
+
+// attachmentBytesQuery and templateBytesQuery sum the user's ($1) attachment
+// sizes and template data; stored bytes count both alongside graph data.
+const (
+	attachmentBytesQuery = `(SELECT coalesce(sum(size_bytes), 0)::bigint FROM node_attachments WHERE user_id = $1)`
+	templateBytesQuery   = `(SELECT coalesce(sum(octet_length(data::text)), 0)::bigint FROM graph_templates WHERE user_id = $1)`
+	// storedBytesQuery is a user's total stored bytes, selected FROM graphs.
+	storedBytesQuery = `coalesce(sum(octet_length(data::text)), 0) + ` + attachmentBytesQuery + ` + ` + templateBytesQuery
+)
+
+// lockUserQuota serializes quota checks of one user until q's transaction ends.
+func lockUserQuota(ctx context.Context, q querier, userID string) error {
+	_, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('graph-quota:' || $1))", userID)
+	return err
+}
+
+// checkAttachmentQuota verifies that storing size more attachment bytes keeps
+// the user within their stored bytes limit. Like checkGraphQuota, q must be
+// the transaction that records the attachment.
+func checkAttachmentQuota(ctx context.Context, q querier, userID, plan string, limits planLimits, size int64) (*quotaError, error) {
+	if limits.MaxStoredBytes <= 0 {
+		return nil, nil
+	}
+	if err := lockUserQuota(ctx, q, userID); err != nil {
+		return nil, err
+	}
+
+	var storedBytes int64
+	err := q.QueryRow(
+		ctx,
+		`SELECT `+storedBytesQuery+`
+		 FROM graphs
+		 WHERE user_id = $1`,
+		userID,
+	).Scan(&storedBytes)
+	if err != nil {
+		return nil, err
+	}
+	if projected := storedBytes + size; projected > limits.MaxStoredBytes {
+		return &quotaError{
+			Status:  http.StatusForbidden,
+			Error:   "quota_exceeded",
+			Quota:   "storedBytes",
+			Plan:    plan,
+			Limit:   limits.MaxStoredBytes,
+			Used:    storedBytes,
+			Message: fmt.Sprintf("storing the attachment would use %d bytes; the %q plan allows %d", projected, plan, limits.MaxStoredBytes),
+		}, nil
+	}
+	return nil, nil
+}
+
+// checkTemplateQuota verifies that saving a user template of dataLen bytes
+// keeps the user within their plan's template count, node and stored bytes
+// limits. Like checkGraphQuota, q must be the transaction that stores it.
+func checkTemplateQuota(ctx context.Context, q querier, userID, plan string, limits planLimits, payload graphPayload, dataLen int) (*quotaError, error) {
+	if limits.MaxNodesPerGraph > 0 {
+		nodes := int64(countJSONArray(payload.Nodes))
+		if nodes > limits.MaxNodesPerGraph {
+			return &quotaError{
+				Status:  http.StatusForbidden,
+				Error:   "quota_exceeded",
+				Quota:   "nodesPerGraph",
+				Plan:    plan,
+				Limit:   limits.MaxNodesPerGraph,
+				Used:    nodes,
+				Message: fmt.Sprintf("template has %d nodes; the %q plan allows %d", nodes, plan, limits.MaxNodesPerGraph),
+			}, nil
+		}
+	}
+	if limits.MaxTemplates <= 0 && limits.MaxStoredBytes <= 0 {
+		return nil, nil
+	}
+	if err := lockUserQuota(ctx, q, userID); err != nil {
+		return nil, err
+	}
+
+	var templates, storedBytes int64
+	err := q.QueryRow(
+		ctx,
+		`SELECT (SELECT count(*) FROM graph_templates WHERE user_id = $1), `+storedBytesQuery+`
+		 FROM graphs
+		 WHERE user_id = $1`,
+		userID,
+	).Scan(&templates, &storedBytes)
+	if err != nil {
+		return nil, err
+	}
+	if limits.MaxTemplates > 0 && templates >= limits.MaxTemplates {
+		return &quotaError{
+			Status:  http.StatusForbidden,
+			Error:   "quota_exceeded",
+			Quota:   "templates",
+			Plan:    plan,
+			Limit:   limits.MaxTemplates,
+			Used:    templates,
+			Message: fmt.Sprintf("the %q plan allows %d templates", plan, limits.MaxTemplates),
+		}, nil
+	}
+	if projected := storedBytes + int64(dataLen); limits.MaxStoredBytes > 0 && projected > limits.MaxStoredBytes {
+		return &quotaError{
+			Status:  http.StatusForbidden,
+			Error:   "quota_exceeded",
+			Quota:   "storedBytes",
+			Plan:    plan,
+			Limit:   limits.MaxStoredBytes,
+			Used:    storedBytes,
+			Message: fmt.Sprintf("saving the template would use %d bytes; the %q plan allows %d", projected, plan, limits.MaxStoredBytes),
+		}, nil
+	}
+	return nil, nil
+}
+
+func writeQuotaError(w http.ResponseWriter, qe *quotaError) {
+	writeJSONStatus(w, qe.Status, qe)
+}
+
+func countJSONArray(raw json.RawMessage) int {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// GET /api/usage: current consumption against the caller's plan.
+func (s *server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to load usage", http.StatusInternalServerError)
+		return
+	}
+
+	var usage usageStats
+	err = s.pool.QueryRow(
+		ctx,
+		`SELECT count(*),
+		        (SELECT count(*) FROM graph_templates WHERE user_id = $1),
+		        `+storedBytesQuery+`,
+		        `+attachmentBytesQuery+`,
+		        coalesce(max(jsonb_array_length(
+		          CASE WHEN jsonb_typeof(data->'nodes') = 'array' THEN data->'nodes' ELSE '[]'::jsonb END
+		        )), 0)
+		 FROM graphs
+		 WHERE user_id = $1`,
+		userID,
+	).Scan(&usage.Graphs, &usage.Templates, &usage.StoredBytes, &usage.AttachmentBytes, &usage.LargestGraphNodes)
+	if err != nil {
+		log.Printf("failed to load usage: %v", err)
+		http.Error(w, "failed to load usage", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, usageResponse{Plan: plan, Limits: limits, Usage: usage})
+}
@@ -0,0 +1,588 @@
+// Headless SVG rendering of stored graphs, mirroring the editor's look:
+// group boxes, nodes, smoothstep edges and the light/dark theme colors.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	svgPadding      = 24.0
+	svgFontSize     = 13.0
+	svgGroupFont    = 11.0
+	svgEdgeFont     = 11.0
+	svgHandleOffset = 20.0
+	svgCornerRadius = 5.0
+	svgMaxDimension = 8192
+)
+
+// svgTheme holds the editor's CSS variables (frontend/src/index.css).
+type svgTheme struct {
+	Background  string
+	NodeFill    string
+	NodeBorder  string
+	NodeText    string
+	GroupFill   string
+	GroupBorder string
+	GroupText   string
+	Edge        string
+	EdgeText    string
+	Accent      string
+}
+
+var svgThemes = map[string]svgTheme{
+	"light": {
+		Background:  "#f7f7f5",
+		NodeFill:    "#e5ecff",
+		NodeBorder:  "rgba(91, 124, 250, 0.4)",
+		NodeText:    "#0f1114",
+		GroupFill:   "rgba(15, 23, 42, 0.04)",
+		GroupBorder: "rgba(20, 29, 38, 0.12)",
+		GroupText:   "#6f6f6a",
+		Edge:        "rgba(63, 68, 78, 0.38)",
+		EdgeText:    "#343434",
+		Accent:      "#5b7cfa",
+	},
+	"dark": {
+		Background:  "#191919",
+		NodeFill:    "#2b3e97",
+		NodeBorder:  "rgba(91, 124, 250, 0.42)",
+		NodeText:    "#f5f6f8",
+		GroupFill:   "rgba(255, 255, 255, 0.018)",
+		GroupBorder: "rgba(255, 255, 255, 0.1)",
+		GroupText:   "#989892",
+		Edge:        "rgba(223, 227, 233, 0.5)",
+		EdgeText:    "#c9c9c6",
+		Accent:      "#5b7cfa",
+	},
+}
+
+// svgColorPattern accepts CSS color values from node styles without letting
+// them break out of an SVG attribute.
+var svgColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|(rgb|rgba|hsl|hsla)\([0-9.,%\s]+\))$`)
+
+// svgOptions are the query parameters of render.svg.
+type svgOptions struct {
+	Theme svgTheme
+	// Width/Height scale the output; zero keeps the natural size (or the
+	// aspect ratio when only one is set).
+	Width, Height float64
+	Items         bool
+	// Transparent omits the background rectangle.
+	Transparent bool
+}
+
+// svgBox is a node as drawn: absolute bounds plus the style taken from the
+// stored React Flow node.
+type svgBox struct {
+	node                ixNode
+	x, y, width, height float64
+	fill, stroke, color string
+	task                bool
+	progress            float64
+	depth               int
+}
+
+// GET /api/graphs/:id/render.svg?theme=light|dark&width=&height=&items=true&background=false
+func (s *server) handleRenderSVG(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := svgOptions{Theme: svgThemes["light"]}
+	if name := strings.ToLower(strings.TrimSpace(query.Get("theme"))); name != "" {
+		theme, ok := svgThemes[name]
+		if !ok {
+			http.Error(w, "theme must be light or dark", http.StatusBadRequest)
+			return
+		}
+		opts.Theme = theme
+	}
+	for param, target := range map[string]*float64{"width": &opts.Width, "height": &opts.Height} {
+		value := strings.TrimSpace(query.Get(param))
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 16 || parsed > svgMaxDimension {
+			http.Error(w, fmt.Sprintf("%s must be between 16 and %d", param, svgMaxDimension), http.StatusBadRequest)
+			return
+		}
+		*target = float64(parsed)
+	}
+	opts.Items, _ = strconv.ParseBool(query.Get("items"))
+	opts.Transparent = query.Get("background") == "false"
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var data []byte
+	err = s.pool.QueryRow(ctx, "SELECT data FROM graphs WHERE id=$1 AND user_id=$2", id, userID).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to load graph", http.StatusInternalServerError)
+		return
+	}
+	var payload graphPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		http.Error(w, "stored graph is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	out, err := renderGraphSVG(payload, opts)
+	if err != nil {
+		http.Error(w, "stored graph is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.Write(out)
+}
+
+// renderGraphSVG draws payload with its stored positions and sizes.
+func renderGraphSVG(payload graphPayload, opts svgOptions) ([]byte, error) {
+	g, err := ixGraphFromPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	var stored []struct {
+		Width  *float64       `json:"width"`
+		Height *float64       `json:"height"`
+		Style  map[string]any `json:"style"`
+		Data   struct {
+			Progress any `json:"progress"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload.Nodes, &stored); err != nil {
+		return nil, err
+	}
+	// Application graphs render every plain node as a task card.
+	tasks := payload.Kind == "application"
+
+	positions := g.absolutePositions()
+	parents := map[string]string{}
+	for _, node := range g.Nodes {
+		parents[node.ID] = node.ParentID
+	}
+	boxes := make([]*svgBox, 0, len(g.Nodes))
+	byID := map[string]*svgBox{}
+	for i, node := range g.Nodes {
+		box := &svgBox{
+			node:   node,
+			x:      positions[node.ID].X,
+			y:      positions[node.ID].Y,
+			width:  defaultNodeWidth,
+			height: defaultNodeHeight,
+		}
+		if node.Group {
+			box.width, box.height = node.Width, node.Height
+		} else if i < len(stored) {
+			if stored[i].Width != nil && *stored[i].Width > 0 {
+				box.width = *stored[i].Width
+			}
+			if stored[i].Height != nil && *stored[i].Height > 0 {
+				box.height = *stored[i].Height
+			}
+			box.width = numberOr(stored[i].Style["width"], box.width)
+			box.height = numberOr(stored[i].Style["height"], box.height)
+		}
+		if i < len(stored) {
+			style := stored[i].Style
+			box.fill = svgStyleColor(style, "background", "backgroundColor")
+			box.stroke = svgStyleColor(style, "borderColor")
+			box.color = svgStyleColor(style, "color")
+			box.task = tasks && !node.Group
+			box.progress = math.Min(100, math.Max(0, numberOr(stored[i].Data.Progress, 0)))
+		}
+		for parent := node.ParentID; parent != "" && box.depth < len(g.Nodes); parent = parents[parent] {
+			box.depth++
+		}
+		boxes = append(boxes, box)
+		byID[node.ID] = box
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, box := range boxes {
+		minX, minY = math.Min(minX, box.x), math.Min(minY, box.y)
+		maxX, maxY = math.Max(maxX, box.x+box.width), math.Max(maxY, box.y+box.height)
+	}
+	if len(boxes) == 0 {
+		minX, minY, maxX, maxY = 0, 0, defaultNodeWidth, defaultNodeHeight
+	}
+	minX, minY = minX-svgPadding, minY-svgPadding
+	viewWidth, viewHeight := maxX+svgPadding-minX, maxY+svgPadding-minY
+	width, height := viewWidth, viewHeight
+	switch {
+	case opts.Width > 0 && opts.Height > 0:
+		width, height = opts.Width, opts.Height
+	case opts.Width > 0:
+		width, height = opts.Width, math.Round(opts.Width*viewHeight/viewWidth)
+	case opts.Height > 0:
+		width, height = math.Round(opts.Height*viewWidth/viewHeight), opts.Height
+	}
+
+	theme := opts.Theme
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s" font-family="Inter, system-ui, -apple-system, 'Segoe UI', sans-serif">`+"\n",
+		svgNumber(width), svgNumber(height), svgNumber(minX), svgNumber(minY), svgNumber(viewWidth), svgNumber(viewHeight))
+	fmt.Fprintf(&b, "<title>%s</title>\n", xmlEscape(g.Name))
+	fmt.Fprintf(&b, `<defs><marker id="arrow" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="8" markerHeight="8" markerUnits="userSpaceOnUse" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker></defs>`+"\n", theme.Edge)
+	if !opts.Transparent {
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+			svgNumber(minX), svgNumber(minY), svgNumber(viewWidth), svgNumber(viewHeight), theme.Background)
+	}
+
+	// React Flow draws groups (outer first), then edges, then nodes.
+	groups := slices.DeleteFunc(slices.Clone(boxes), func(box *svgBox) bool { return !box.node.Group })
+	slices.SortStableFunc(groups, func(a, b *svgBox) int { return a.depth - b.depth })
+	for _, box := range groups {
+		writeSVGGroup(&b, box, theme, opts.Items)
+	}
+	for _, edge := range g.Edges {
+		source, target := byID[edge.Source], byID[edge.Target]
+		if source == nil || target == nil {
+			continue
+		}
+		writeSVGEdge(&b, edge, source, target, theme)
+	}
+	for _, box := range boxes {
+		if !box.node.Group {
+			writeSVGNode(&b, box, theme, opts.Items)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String()), nil
+}
+
+func writeSVGGroup(b *strings.Builder, box *svgBox, theme svgTheme, items bool) {
+	fmt.Fprintf(b, `<g class="group"><rect x="%s" y="%s" width="%s" height="%s" rx="4" fill="%s" stroke="%s"/>`,
+		svgNumber(box.x), svgNumber(box.y), svgNumber(box.width), svgNumber(box.height),
+		firstNonEmpty(box.fill, theme.GroupFill), firstNonEmpty(box.stroke, theme.GroupBorder))
+	label := svgTruncate(box.node.Label, box.width-20, svgGroupFont)
+	fmt.Fprintf(b, `<text x="%s" y="%s" font-size="%s" font-weight="600" fill="%s">%s</text>`,
+		svgNumber(box.x+10), svgNumber(box.y+8+svgGroupFont), svgNumber(svgGroupFont), firstNonEmpty(box.color, theme.GroupText), xmlEscape(label))
+	if count := countItems(box.node.Items); items && count > 0 {
+		writeSVGBadge(b, box.x+box.width-8, box.y+8, count, theme)
+	}
+	b.WriteString("</g>\n")
+}
+
+func writeSVGNode(b *strings.Builder, box *svgBox, theme svgTheme, items bool) {
+	fmt.Fprintf(b, `<g class="node"><rect x="%s" y="%s" width="%s" height="%s" rx="4" fill="%s" stroke="%s"/>`,
+		svgNumber(box.x), svgNumber(box.y), svgNumber(box.width), svgNumber(box.height),
+		firstNonEmpty(box.fill, theme.NodeFill), firstNonEmpty(box.stroke, theme.NodeBorder))
+
+	textHeight := box.height - 16
+	if box.task {
+		// Room for the progress bar and percentage below the title.
+		textHeight -= 26
+	}
+	maxLines := max(1, int(textHeight/(svgFontSize*1.3)))
+	lines := svgWrap(box.node.Label, box.width-28, svgFontSize, maxLines)
+	lineHeight := svgFontSize * 1.3
+	top := box.y + (box.height-float64(len(lines))*lineHeight)/2 + svgFontSize
+	if box.task {
+		top = box.y + 8 + svgFontSize
+	}
+	color := firstNonEmpty(box.color, theme.NodeText)
+	for i, line := range lines {
+		fmt.Fprintf(b, `<text x="%s" y="%s" font-size="%s" font-weight="600" text-anchor="middle" fill="%s">%s</text>`,
+			svgNumber(box.x+box.width/2), svgNumber(top+float64(i)*lineHeight), svgNumber(svgFontSize), color, xmlEscape(line))
+	}
+	if box.task {
+		barY := box.y + box.height - 26
+		barWidth := box.width - 28
+		fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="6" rx="3" fill="%s" opacity="0.25"/>`,
+			svgNumber(box.x+14), svgNumber(barY), svgNumber(barWidth), color)
+		fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="6" rx="3" fill="%s"/>`,
+			svgNumber(box.x+14), svgNumber(barY), svgNumber(barWidth*box.progress/100), theme.Accent)
+		fmt.Fprintf(b, `<text x="%s" y="%s" font-size="10" fill="%s">%d%%</text>`,
+			svgNumber(box.x+14), svgNumber(barY+18), color, int(math.Round(box.progress)))
+	}
+	if count := countItems(box.node.Items); items && count > 0 {
+		writeSVGBadge(b, box.x+box.width-4, box.y-8, count, theme)
+	}
+	b.WriteString("</g>\n")
+}
+
+// writeSVGBadge draws an item count pill whose right edge is at right.
+func writeSVGBadge(b *strings.Builder, right, top float64, count int, theme svgTheme) {
+	text := strconv.Itoa(count)
+	width := math.Max(18, svgTextWidth(text, 10)+10)
+	fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="16" rx="8" fill="%s"/>`,
+		svgNumber(right-width), svgNumber(top), svgNumber(width), theme.Accent)
+	fmt.Fprintf(b, `<text x="%s" y="%s" font-size="10" font-weight="600" text-anchor="middle" fill="#ffffff">%s</text>`,
+		svgNumber(right-width/2), svgNumber(top+11.5), text)
+}
+
+// writeSVGEdge draws a smoothstep path between the handles the edge uses,
+// falling back to right → left as React Flow does.
+func writeSVGEdge(b *strings.Builder, edge ixEdge, source, target *svgBox, theme svgTheme) {
+	start, startDir := svgHandlePoint(source, firstNonEmpty(edge.SourceHandle, "right-out"))
+	end, endDir := svgHandlePoint(target, firstNonEmpty(edge.TargetHandle, "left-in"))
+	points := svgStepPoints(start, startDir, end, endDir)
+
+	fmt.Fprintf(b, `<g class="edge"><path d="%s" fill="none" stroke="%s" stroke-width="2.4"`, svgRoundedPath(points), theme.Edge)
+	if edge.Directed {
+		b.WriteString(` marker-end="url(#arrow)"`)
+	}
+	b.WriteString("/>")
+	if label := strings.TrimSpace(edge.Label); label != "" {
+		mid := svgPolylineMidpoint(points)
+		label = svgTruncate(label, 200, svgEdgeFont)
+		width := svgTextWidth(label, svgEdgeFont) + 8
+		fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" rx="2" fill="%s" opacity="0.9"/>`,
+			svgNumber(mid.X-width/2), svgNumber(mid.Y-svgEdgeFont/2-3), svgNumber(width), svgNumber(svgEdgeFont+6), theme.Background)
+		fmt.Fprintf(b, `<text x="%s" y="%s" font-size="%s" text-anchor="middle" fill="%s">%s</text>`,
+			svgNumber(mid.X), svgNumber(mid.Y+svgEdgeFont/2-1.5), svgNumber(svgEdgeFont), theme.EdgeText, xmlEscape(label))
+	}
+	b.WriteString("</g>\n")
+}
+
+// svgHandlePoint returns where a handle sits on a box and the direction an
+// edge leaves it in.
+func svgHandlePoint(box *svgBox, handle string) (ixPoint, ixPoint) {
+	switch handle {
+	case "left-in":
+		return ixPoint{X: box.x, Y: box.y + box.height/2}, ixPoint{X: -1}
+	case "top-in":
+		return ixPoint{X: box.x + box.width/2, Y: box.y}, ixPoint{Y: -1}
+	case "bottom-out":
+		return ixPoint{X: box.x + box.width/2, Y: box.y + box.height}, ixPoint{Y: 1}
+	default:
+		return ixPoint{X: box.x + box.width, Y: box.y + box.height/2}, ixPoint{X: 1}
+	}
+}
+
+// svgStepPoints routes an orthogonal path from start to end, leaving and
+// entering along the handle directions.
+func svgStepPoints(start, startDir, end, endDir ixPoint) []ixPoint {
+	out := ixPoint{X: start.X + startDir.X*svgHandleOffset, Y: start.Y + startDir.Y*svgHandleOffset}
+	in := ixPoint{X: end.X + endDir.X*svgHandleOffset, Y: end.Y + endDir.Y*svgHandleOffset}
+	horizontalStart, horizontalEnd := startDir.X != 0, endDir.X != 0
+
+	var points []ixPoint
+	switch {
+	case horizontalStart && horizontalEnd:
+		if (in.X-out.X)*startDir.X >= 0 {
+			midX := (start.X + end.X) / 2
+			points = []ixPoint{start, {X: midX, Y: start.Y}, {X: midX, Y: end.Y}, end}
+		} else {
+			midY := (start.Y + end.Y) / 2
+			points = []ixPoint{start, out, {X: out.X, Y: midY}, {X: in.X, Y: midY}, in, end}
+		}
+	case !horizontalStart && !horizontalEnd:
+		if (in.Y-out.Y)*startDir.Y >= 0 {
+			midY := (start.Y + end.Y) / 2
+			points = []ixPoint{start, {X: start.X, Y: midY}, {X: end.X, Y: midY}, end}
+		} else {
+			midX := (start.X + end.X) / 2
+			points = []ixPoint{start, out, {X: midX, Y: out.Y}, {X: midX, Y: in.Y}, in, end}
+		}
+	case horizontalStart:
+		if (end.X-out.X)*startDir.X >= 0 && (start.Y-in.Y)*endDir.Y >= 0 {
+			points = []ixPoint{start, {X: end.X, Y: start.Y}, end}
+		} else {
+			points = []ixPoint{start, out, {X: out.X, Y: in.Y}, in, end}
+		}
+	default:
+		if (end.Y-out.Y)*startDir.Y >= 0 && (start.X-in.X)*endDir.X >= 0 {
+			points = []ixPoint{start, {X: start.X, Y: end.Y}, end}
+		} else {
+			points = []ixPoint{start, out, {X: in.X, Y: out.Y}, in, end}
+		}
+	}
+
+	// Drop repeated and collinear points so corners are only drawn where the
+	// path turns.
+	cleaned := []ixPoint{points[0]}
+	for _, point := range points[1:] {
+		last := cleaned[len(cleaned)-1]
+		if point == last {
+			continue
+		}
+		if len(cleaned) >= 2 {
+			prev := cleaned[len(cleaned)-2]
+			if (prev.X == last.X && last.X == point.X) || (prev.Y == last.Y && last.Y == point.Y) {
+				cleaned[len(cleaned)-1] = point
+				continue
+			}
+		}
+		cleaned = append(cleaned, point)
+	}
+	return cleaned
+}
+
+// svgRoundedPath draws a polyline with rounded corners.
+func svgRoundedPath(points []ixPoint) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "M%s,%s", svgNumber(points[0].X), svgNumber(points[0].Y))
+	for i := 1; i < len(points)-1; i++ {
+		prev, corner, next := points[i-1], points[i], points[i+1]
+		radius := math.Min(svgCornerRadius, math.Min(svgDistance(prev, corner), svgDistance(corner, next))/2)
+		before := svgTowards(corner, prev, radius)
+		after := svgTowards(corner, next, radius)
+		fmt.Fprintf(&b, " L%s,%s Q%s,%s %s,%s", svgNumber(before.X), svgNumber(before.Y),
+			svgNumber(corner.X), svgNumber(corner.Y), svgNumber(after.X), svgNumber(after.Y))
+	}
+	last := points[len(points)-1]
+	fmt.Fprintf(&b, " L%s,%s", svgNumber(last.X), svgNumber(last.Y))
+	return b.String()
+}
+
+func svgDistance(a, b ixPoint) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// svgTowards moves distance from a towards b.
+func svgTowards(a, b ixPoint, distance float64) ixPoint {
+	length := svgDistance(a, b)
+	if length == 0 {
+		return a
+	}
+	return ixPoint{X: a.X + (b.X-a.X)*distance/length, Y: a.Y + (b.Y-a.Y)*distance/length}
+}
+
+// svgPolylineMidpoint is the point halfway along the path, where the edge
+// label goes.
+func svgPolylineMidpoint(points []ixPoint) ixPoint {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += svgDistance(points[i-1], points[i])
+	}
+	remaining := total / 2
+	for i := 1; i < len(points); i++ {
+		segment := svgDistance(points[i-1], points[i])
+		if segment >= remaining {
+			return svgTowards(points[i-1], points[i], remaining)
+		}
+		remaining -= segment
+	}
+	return points[len(points)-1]
+}
+
+// svgStyleColor returns the first safe color among the style keys.
+func svgStyleColor(style map[string]any, keys ...string) string {
+	for _, key := range keys {
+		value, _ := style[key].(string)
+		value = strings.TrimSpace(value)
+		if value != "" && svgColorPattern.MatchString(value) {
+			return value
+		}
+	}
+	return ""
+}
+
+// svgTextWidth estimates rendered text width; there is no font metrics
+// source on the server, so it uses average glyph widths.
+func svgTextWidth(text string, fontSize float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		width += svgRuneWidth(r)
+	}
+	return width * fontSize
+}
+
+// svgRuneWidth is the estimated advance of r in ems.
+func svgRuneWidth(r rune) float64 {
+	switch {
+	case r == ' ' || strings.ContainsRune("iljtfrI.,:;'|!", r):
+		return 0.32
+	case r >= 'A' && r <= 'Z' || strings.ContainsRune("mwMW@", r):
+		return 0.7
+	case r > 0x2e80:
+		return 1
+	}
+	return 0.56
+}
+
+// svgTruncate shortens text with an ellipsis to fit maxWidth. Widths are
+// summed in one pass, so long labels stay linear.
+func svgTruncate(text string, maxWidth, fontSize float64) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if svgTextWidth(text, fontSize) <= maxWidth {
+		return text
+	}
+	available := maxWidth/fontSize - svgRuneWidth('…')
+	width, cut := 0.0, 0
+	for i, r := range text {
+		width += svgRuneWidth(r)
+		if width > available {
+			break
+		}
+		cut = i + utf8.RuneLen(r)
+	}
+	return strings.TrimSpace(text[:cut]) + "…"
+}
+
+// svgWrap breaks text into at most maxLines lines of maxWidth, truncating
+// the last one. The line width is kept as a running sum, so long labels
+// stay linear.
+func svgWrap(text string, maxWidth, fontSize float64, maxLines int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	var lines []string
+	// width is the line's width in ems, summed rune by rune as svgTextWidth
+	// does, so a line accepted here is not truncated by svgTruncate.
+	start, width := 0, 0.0
+	for i, word := range words {
+		candidate := width
+		if i > start {
+			candidate += svgRuneWidth(' ')
+		}
+		for _, r := range word {
+			candidate += svgRuneWidth(r)
+		}
+		if i == start || candidate*fontSize <= maxWidth {
+			width = candidate
+			continue
+		}
+		if len(lines) == maxLines-1 {
+			return append(lines, svgTruncate(strings.Join(words[start:], " "), maxWidth, fontSize))
+		}
+		lines = append(lines, svgTruncate(strings.Join(words[start:i], " "), maxWidth, fontSize))
+		start, width = i, 0
+		for _, r := range word {
+			width += svgRuneWidth(r)
+		}
+	}
+	return append(lines, svgTruncate(strings.Join(words[start:], " "), maxWidth, fontSize))
+}
+
+func svgNumber(value float64) string {
+	rounded := math.Round(value*100) / 100
+	if rounded == 0 {
+		// Avoid printing "-0".
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSVGWrap(t *testing.T) {
+	// At font size 10, "aaaa" is 22.4 wide and a space 3.2.
+	tests := []struct {
+		name     string
+		text     string
+		maxWidth float64
+		maxLines int
+		want     []string
+	}{
+		{"empty", "  ", 100, 3, nil},
+		{"fits", "aaaa aaaa", 100, 3, []string{"aaaa aaaa"}},
+		{"wraps", "aaaa aaaa aaaa", 50, 3, []string{"aaaa aaaa", "aaaa"}},
+		{"just fits", "aaaa aaaa", 48.1, 3, []string{"aaaa aaaa"}},
+		{"last line truncated", "aaaa aaaa aaaa aaaa", 25, 2, []string{"aaaa", "aaa…"}},
+		{"long word truncated", "aaaaaaaaaa b", 30, 2, []string{"aaaa…", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svgWrap(tt.text, tt.maxWidth, 10, tt.maxLines); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("svgWrap = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSVGWrapLongLabelIsLinear(t *testing.T) {
+	label := strings.Repeat("word ", 200000)
+	start := time.Now()
+	lines := svgWrap(label, 1e9, 10, 3)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("wrapping a %d byte label took %v", len(label), elapsed)
+	}
+}
@@ -6,6 +6,8 @@ create table if not exists graphs (
   kind text not null default 'note',
   data jsonb not null,
   node_notes jsonb not null default '[]'::jsonb,
+  tags text[] not null default '{}',
+  folder text not null default '',
   updated_at timestamptz not null default now()
 );
 
@@ -14,6 +16,8 @@ alter table graphs add column if not exists user_id text;
 alter table graphs add column if not exists name text;
 alter table graphs add column if not exists kind text;
 alter table graphs add column if not exists node_notes jsonb;
+alter table graphs add column if not exists tags text[] not null default '{}';
+alter table graphs add column if not exists folder text not null default '';
 
 update graphs
 set name = coalesce(nullif(trim(data->>'name'), ''), 'Untitled Graph')
@@ -54,3 +58,106 @@ alter table graphs alter column node_notes set not null;
 create index if not exists graphs_user_id_idx on graphs(user_id);
 create index if not exists graphs_user_kind_updated_idx on graphs(user_id, kind, updated_at desc);
 create index if not exists graphs_node_notes_idx on graphs using gin(node_notes);
+create index if not exists graphs_tags_idx on graphs using gin(tags);
+
+-- Maps offline client graph ids ("local-...") to server ids for /api/sync.
+create table if not exists graph_client_ids (
+  user_id text not null,
+  client_id text not null,
+  graph_id text not null,
+  created_at timestamptz not null default now(),
+  primary key (user_id, client_id)
+);
+
+-- Quota plan per user; users without a row get DEFAULT_PLAN.
+create table if not exists user_plans (
+  user_id text primary key,
+  plan text not null,
+  updated_at timestamptz not null default now()
+);
+
+-- Node attachment metadata; file bytes live in the configured blob store.
+create table if not exists node_attachments (
+  id text primary key,
+  user_id text not null,
+  graph_id text not null,
+  node_id text not null,
+  file_name text not null,
+  content_type text not null,
+  size_bytes bigint not null,
+  storage_key text not null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists node_attachments_graph_node_idx on node_attachments(user_id, graph_id, node_id);
+
+-- User-defined graph templates; built-in templates are compiled into the server.
+create table if not exists graph_templates (
+  id text primary key,
+  user_id text not null,
+  name text not null,
+  description text not null default '',
+  kind text not null default 'note',
+  variables text[] not null default '{}',
+  data jsonb not null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists graph_templates_user_idx on graph_templates(user_id, updated_at desc);
+
+-- Cross-graph node links (node data.links), rebuilt on every save of the source graph.
+create table if not exists node_links (
+  user_id text not null,
+  source_graph_id text not null,
+  source_node_id text not null,
+  source_label text not null default '',
+  target_graph_id text not null,
+  target_node_id text not null,
+  primary key (user_id, source_graph_id, source_node_id, target_graph_id, target_node_id)
+);
+
+create index if not exists node_links_target_idx on node_links(user_id, target_graph_id, target_node_id);
+
+-- [[Label]] references parsed from node and item notes; rebuilt on every save.
+create table if not exists graph_wikilinks (
+  user_id text not null,
+  graph_id text not null,
+  source_node_id text not null,
+  item_id text not null default '',
+  target text not null,
+  position int not null,
+  primary key (user_id, graph_id, source_node_id, item_id, target)
+);
+
+-- Comment threads on nodes/items; replies point at the thread root via parent_id.
+create table if not exists graph_comments (
+  id text primary key,
+  user_id text not null,
+  graph_id text not null,
+  node_id text not null,
+  item_id text not null default '',
+  parent_id text,
+  author_id text not null,
+  author_name text not null default '',
+  body text not null,
+  resolved boolean not null default false,
+  resolved_at timestamptz,
+  resolved_by text,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists graph_comments_graph_idx on graph_comments(user_id, graph_id, node_id);
+
+-- Async import jobs; state is the polled job JSON so any instance can answer.
+create table if not exists import_jobs (
+  id text primary key,
+  user_id text not null,
+  status text not null,
+  state jsonb not null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists import_jobs_user_idx on import_jobs(user_id, status);
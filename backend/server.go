@@ -32,4 +32,15 @@ type server struct {
 	// ES256 projects use Supabase JWKS; keep an in-memory cache to avoid frequent fetches.
 	jwkCache map[string]jwkCacheEntry
 	jwkMu    sync.RWMutex
+	// Quota plans keyed by name; users without a user_plans row get defaultPlan.
+	plans       map[string]planLimits
+	defaultPlan string
+	// Ceiling on the decoded size of gzip/zstd request bodies; below it the
+	// decoded size is bounded by the wire limit (see decodedBodyLimit).
+	maxDecodedBodyBytes int64
+	// Attachment bytes live in a blob store; metadata lives in node_attachments.
+	blobs              blobStore
+	maxAttachmentBytes int64
+	// Max size of async import uploads (import_jobs.go).
+	maxImportJobBytes int64
 }
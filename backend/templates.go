@@ -0,0 +1,668 @@
+// Server-side graph templates: built-in starters plus user-defined templates.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const builtinTemplatePrefix = "builtin-"
+
+// templateVariablePattern matches {{name}} placeholders in labels and titles.
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+type graphTemplate struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Kind        string       `json:"kind"`
+	Builtin     bool         `json:"builtin"`
+	Variables   []string     `json:"variables"`
+	UpdatedAt   *time.Time   `json:"updatedAt,omitempty"`
+	Graph       graphPayload `json:"graph"`
+}
+
+// templateSummary is returned in template lists (without the graph body).
+type templateSummary struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Kind        string     `json:"kind"`
+	Builtin     bool       `json:"builtin"`
+	Variables   []string   `json:"variables"`
+	NodeCount   int        `json:"nodeCount"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
+}
+
+type createTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// GraphID copies an existing graph; otherwise Graph is used as-is.
+	GraphID string        `json:"graphId,omitempty"`
+	Graph   *graphPayload `json:"graph,omitempty"`
+}
+
+// instantiateTemplateRequest is the body of POST /api/graphs?template=:id.
+type instantiateTemplateRequest struct {
+	Name      string            `json:"name,omitempty"`
+	Kind      string            `json:"kind,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+var builtinTemplates = []graphTemplate{
+	newBuiltinTemplate(
+		"mind-map",
+		"Mind map",
+		"A central topic with four branches.",
+		`{"name":"{{topic}}","kind":"note","nodes":[
+			{"id":"center","type":"default","position":{"x":320,"y":220},"data":{"label":"{{topic}}","items":[]}},
+			{"id":"branch-1","type":"default","position":{"x":80,"y":60},"data":{"label":"Ideas","items":[]}},
+			{"id":"branch-2","type":"default","position":{"x":560,"y":60},"data":{"label":"Questions","items":[]}},
+			{"id":"branch-3","type":"default","position":{"x":80,"y":380},"data":{"label":"Resources","items":[]}},
+			{"id":"branch-4","type":"default","position":{"x":560,"y":380},"data":{"label":"Next steps","items":[]}}
+		],"edges":[
+			{"id":"e1","source":"center","target":"branch-1","type":"smoothstep"},
+			{"id":"e2","source":"center","target":"branch-2","type":"smoothstep"},
+			{"id":"e3","source":"center","target":"branch-3","type":"smoothstep"},
+			{"id":"e4","source":"center","target":"branch-4","type":"smoothstep"}
+		]}`,
+	),
+	newBuiltinTemplate(
+		"project-plan",
+		"Project plan",
+		"Goals, milestones, tasks and risks for a project.",
+		`{"name":"{{project}} plan","kind":"note","nodes":[
+			{"id":"project","type":"default","position":{"x":300,"y":20},"data":{"label":"{{project}}","items":[]}},
+			{"id":"goals","type":"default","position":{"x":40,"y":180},"data":{"label":"Goals","items":[{"id":"goal-1","title":"Define success for {{project}}","notes":[],"children":[]}]}},
+			{"id":"milestones","type":"default","position":{"x":300,"y":180},"data":{"label":"Milestones","items":[]}},
+			{"id":"risks","type":"default","position":{"x":560,"y":180},"data":{"label":"Risks","items":[]}},
+			{"id":"tasks","type":"default","position":{"x":300,"y":340},"data":{"label":"Tasks","items":[]}}
+		],"edges":[
+			{"id":"e1","source":"project","target":"goals","type":"smoothstep","data":{"directed":true}},
+			{"id":"e2","source":"project","target":"milestones","type":"smoothstep","data":{"directed":true}},
+			{"id":"e3","source":"project","target":"risks","type":"smoothstep","data":{"directed":true}},
+			{"id":"e4","source":"milestones","target":"tasks","type":"smoothstep","data":{"directed":true}}
+		]}`,
+	),
+	newBuiltinTemplate(
+		"study-topic",
+		"Study topic",
+		"Concepts grouped under a subject with practice and review nodes.",
+		`{"name":"{{subject}}","kind":"note","nodes":[
+			{"id":"group","type":"group","position":{"x":0,"y":0},"style":{"width":520,"height":220},"data":{"label":"{{subject}}","items":[]}},
+			{"id":"basics","type":"default","position":{"x":40,"y":80},"parentNode":"group","extent":"parent","data":{"label":"Basics","items":[]}},
+			{"id":"advanced","type":"default","position":{"x":300,"y":80},"parentNode":"group","extent":"parent","data":{"label":"Advanced","items":[]}},
+			{"id":"practice","type":"default","position":{"x":60,"y":300},"data":{"label":"Practice","items":[]}},
+			{"id":"review","type":"default","position":{"x":320,"y":300},"data":{"label":"Review","items":[]}}
+		],"edges":[
+			{"id":"e1","source":"basics","target":"advanced","type":"smoothstep","data":{"directed":true}},
+			{"id":"e2","source":"advanced","target":"practice","type":"smoothstep","data":{"directed":true}},
+			{"id":"e3","source":"practice","target":"review","type":"smoothstep","data":{"directed":true}}
+		]}`,
+	),
+}
+
+func newBuiltinTemplate(slug, name, description, graphJSON string) graphTemplate {
+	var graph graphPayload
+	if err := json.Unmarshal([]byte(graphJSON), &graph); err != nil {
+		panic(fmt.Sprintf("invalid built-in template %s: %v", slug, err))
+	}
+	return graphTemplate{
+		ID:          builtinTemplatePrefix + slug,
+		Name:        name,
+		Description: description,
+		Kind:        graph.Kind,
+		Builtin:     true,
+		Variables:   templateVariables(graph),
+		Graph:       graph,
+	}
+}
+
+func findBuiltinTemplate(id string) (graphTemplate, bool) {
+	for _, template := range builtinTemplates {
+		if template.ID == id {
+			return template, true
+		}
+	}
+	return graphTemplate{}, false
+}
+
+func (t graphTemplate) summary() templateSummary {
+	return templateSummary{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		Kind:        t.Kind,
+		Builtin:     t.Builtin,
+		Variables:   t.Variables,
+		NodeCount:   countJSONArray(t.Graph.Nodes),
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// Template collection endpoint (list + create).
+func (s *server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListTemplates(w, r)
+	case http.MethodPost:
+		s.handleCreateTemplate(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Per-template handler (fetch + delete).
+func (s *server) handleTemplateByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/templates/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "template id required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGetTemplate(w, r, id)
+	case http.MethodDelete:
+		s.handleDeleteTemplate(w, r, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Lists built-in templates followed by the caller's own, optionally filtered by kind.
+func (s *server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	kind := strings.TrimSpace(r.URL.Query().Get("kind"))
+	summaries := make([]templateSummary, 0, len(builtinTemplates))
+	for _, template := range builtinTemplates {
+		if kind == "" || template.Kind == kind {
+			summaries = append(summaries, template.summary())
+		}
+	}
+
+	rows, err := s.pool.Query(
+		ctx,
+		`SELECT id, name, description, kind, variables, updated_at,
+		        jsonb_array_length(CASE WHEN jsonb_typeof(data->'nodes') = 'array' THEN data->'nodes' ELSE '[]'::jsonb END)
+		 FROM graph_templates
+		 WHERE user_id = $1 AND ($2 = '' OR kind = $2)
+		 ORDER BY updated_at DESC`,
+		userID,
+		kind,
+	)
+	if err != nil {
+		log.Printf("failed to list templates: %v", err)
+		http.Error(w, "failed to list templates", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary templateSummary
+		var updatedAt time.Time
+		if err := rows.Scan(&summary.ID, &summary.Name, &summary.Description, &summary.Kind, &summary.Variables, &updatedAt, &summary.NodeCount); err != nil {
+			log.Printf("failed to scan template: %v", err)
+			http.Error(w, "failed to list templates", http.StatusInternalServerError)
+			return
+		}
+		summary.UpdatedAt = &updatedAt
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("failed to list templates: %v", err)
+		http.Error(w, "failed to list templates", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, summaries)
+}
+
+// POST /api/templates: save a template from an existing graph or an inline graph.
+func (s *server) handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	plan, limits, body, ok := s.readGraphBody(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req createTemplateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var graph graphPayload
+	switch {
+	case strings.TrimSpace(req.GraphID) != "":
+		var data []byte
+		err := s.pool.QueryRow(ctx, "SELECT data FROM graphs WHERE id=$1 AND user_id=$2", req.GraphID, userID).Scan(&data)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "graph not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("failed to read graph: %v", err)
+			http.Error(w, "failed to create template", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(data, &graph); err != nil {
+			http.Error(w, "stored graph is invalid", http.StatusUnprocessableEntity)
+			return
+		}
+	case req.Graph != nil:
+		graph = *req.Graph
+	default:
+		http.Error(w, "graphId or graph is required", http.StatusBadRequest)
+		return
+	}
+	if graph.Nodes == nil || graph.Edges == nil {
+		http.Error(w, "nodes and edges are required", http.StatusBadRequest)
+		return
+	}
+	normalizeGraphPayload(&graph)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = graph.Name
+	}
+	data, err := json.Marshal(graph)
+	if err != nil {
+		http.Error(w, "failed to encode template", http.StatusInternalServerError)
+		return
+	}
+	// Templates become graphs, so they are held to the same schema as saves.
+	if writeInvalidGraph(w, validateGraphData(data)) {
+		return
+	}
+	id, err := generateID()
+	if err != nil {
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+
+	template := graphTemplate{
+		ID:          id,
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+		Kind:        graph.Kind,
+		Variables:   templateVariables(graph),
+		Graph:       graph,
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin transaction: %v", err)
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	quotaErr, err := checkTemplateQuota(ctx, tx, userID, plan, limits, graph, len(data))
+	if err != nil {
+		log.Printf("failed to check quota: %v", err)
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+	if quotaErr != nil {
+		writeQuotaError(w, quotaErr)
+		return
+	}
+
+	var updatedAt time.Time
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO graph_templates (id, user_id, name, description, kind, variables, data, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		 RETURNING updated_at`,
+		template.ID,
+		userID,
+		template.Name,
+		template.Description,
+		template.Kind,
+		template.Variables,
+		data,
+	).Scan(&updatedAt)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("failed to create template: %v", err)
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+	template.UpdatedAt = &updatedAt
+
+	writeJSONStatus(w, http.StatusCreated, template.summary())
+}
+
+func (s *server) handleGetTemplate(w http.ResponseWriter, r *http.Request, id string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	template, err := s.loadTemplate(ctx, userID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read template: %v", err)
+		http.Error(w, "failed to load template", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, template)
+}
+
+func (s *server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request, id string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if strings.HasPrefix(id, builtinTemplatePrefix) {
+		http.Error(w, "built-in templates cannot be deleted", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	cmd, err := s.pool.Exec(ctx, "DELETE FROM graph_templates WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		log.Printf("failed to delete template: %v", err)
+		http.Error(w, "failed to delete template", http.StatusInternalServerError)
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadTemplate returns a built-in template or one owned by userID (pgx.ErrNoRows otherwise).
+func (s *server) loadTemplate(ctx context.Context, userID, id string) (graphTemplate, error) {
+	if template, ok := findBuiltinTemplate(id); ok {
+		return template, nil
+	}
+
+	template := graphTemplate{ID: id}
+	var data []byte
+	var updatedAt time.Time
+	err := s.pool.QueryRow(
+		ctx,
+		`SELECT name, description, kind, variables, data, updated_at
+		 FROM graph_templates
+		 WHERE id = $1 AND user_id = $2`,
+		id,
+		userID,
+	).Scan(&template.Name, &template.Description, &template.Kind, &template.Variables, &data, &updatedAt)
+	if err != nil {
+		return graphTemplate{}, err
+	}
+	if err := json.Unmarshal(data, &template.Graph); err != nil {
+		return graphTemplate{}, err
+	}
+	template.UpdatedAt = &updatedAt
+	return template, nil
+}
+
+// POST /api/graphs?template=:id: create a graph from a template with fresh ids.
+func (s *server) handleCreateGraphFromTemplate(w http.ResponseWriter, r *http.Request, templateID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, ok := s.readRequestJSON(w, r, defaultMaxBodyBytes)
+	if !ok {
+		return
+	}
+	var req instantiateTemplateRequest
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	template, err := s.loadTemplate(ctx, userID, templateID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read template: %v", err)
+		http.Error(w, "failed to create graph", http.StatusInternalServerError)
+		return
+	}
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to create graph", http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := instantiateTemplate(template.Graph, req.Variables)
+	if err != nil {
+		http.Error(w, "template graph is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		payload.Name = name
+	}
+	if kind := strings.TrimSpace(req.Kind); kind != "" {
+		payload.Kind = kind
+	}
+
+	s.createGraphAndRespond(ctx, w, userID, plan, limits, payload)
+}
+
+// templateVariables lists the distinct {{name}} placeholders in the fields
+// instantiateTemplate substitutes: the graph name, node labels and item titles.
+func templateVariables(graph graphPayload) []string {
+	variables := []string{}
+	add := func(text string) {
+		for _, match := range templateVariablePattern.FindAllStringSubmatch(text, -1) {
+			if !slices.Contains(variables, match[1]) {
+				variables = append(variables, match[1])
+			}
+		}
+	}
+	var addItems func(items []templateItem)
+	addItems = func(items []templateItem) {
+		for _, item := range items {
+			add(item.Title)
+			addItems(item.Children)
+		}
+	}
+	add(graph.Name)
+	var entries []json.RawMessage
+	_ = json.Unmarshal(graph.Nodes, &entries)
+	for _, entry := range entries {
+		var node struct {
+			Data struct {
+				Label string         `json:"label"`
+				Items []templateItem `json:"items"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(entry, &node); err != nil {
+			continue
+		}
+		add(node.Data.Label)
+		addItems(node.Data.Items)
+	}
+	return variables
+}
+
+// templateItem is the part of an item templateVariables reads.
+type templateItem struct {
+	Title    string         `json:"title"`
+	Children []templateItem `json:"children"`
+}
+
+func substituteTemplateVariables(text string, variables map[string]string) string {
+	return templateVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := templateVariablePattern.FindStringSubmatch(match)[1]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// instantiateTemplate copies a template graph, giving every node, edge, item
+// and note a fresh id and substituting variables in labels and item titles.
+func instantiateTemplate(graph graphPayload, variables map[string]string) (graphPayload, error) {
+	var nodes []map[string]any
+	var edges []map[string]any
+	if err := json.Unmarshal(graph.Nodes, &nodes); err != nil {
+		return graphPayload{}, err
+	}
+	if err := json.Unmarshal(graph.Edges, &edges); err != nil {
+		return graphPayload{}, err
+	}
+
+	nodeIDs := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		if id, ok := node["id"].(string); ok {
+			nodeIDs[id] = newID("node")
+		}
+	}
+	for _, node := range nodes {
+		id, _ := node["id"].(string)
+		if fresh, ok := nodeIDs[id]; ok {
+			node["id"] = fresh
+		} else {
+			node["id"] = newID("node")
+		}
+		if parent, ok := node["parentNode"].(string); ok && parent != "" {
+			if fresh, ok := nodeIDs[parent]; ok {
+				node["parentNode"] = fresh
+			} else {
+				delete(node, "parentNode")
+				delete(node, "extent")
+			}
+		}
+		if data, ok := node["data"].(map[string]any); ok {
+			if label, ok := data["label"].(string); ok {
+				data["label"] = substituteTemplateVariables(label, variables)
+			}
+			if items, ok := data["items"].([]any); ok {
+				refreshTemplateItems(items, variables)
+			}
+			if links, ok := data["links"].([]any); ok {
+				data["links"] = remapTemplateLinks(links, nodeIDs)
+			}
+		}
+	}
+
+	keptEdges := make([]map[string]any, 0, len(edges))
+	for _, edge := range edges {
+		source, _ := edge["source"].(string)
+		target, _ := edge["target"].(string)
+		if nodeIDs[source] == "" || nodeIDs[target] == "" {
+			continue
+		}
+		edge["id"] = newID("edge")
+		edge["source"] = nodeIDs[source]
+		edge["target"] = nodeIDs[target]
+		keptEdges = append(keptEdges, edge)
+	}
+
+	nodesJSON, err := json.Marshal(nodes)
+	if err != nil {
+		return graphPayload{}, err
+	}
+	edgesJSON, err := json.Marshal(keptEdges)
+	if err != nil {
+		return graphPayload{}, err
+	}
+	return graphPayload{
+		Name:  substituteTemplateVariables(graph.Name, variables),
+		Nodes: nodesJSON,
+		Edges: edgesJSON,
+		Kind:  graph.Kind,
+		Extra: graph.Extra,
+	}, nil
+}
+
+// remapTemplateLinks points same-graph links at the fresh node ids, dropping
+// links to nodes that are not part of the template.
+func remapTemplateLinks(links []any, nodeIDs map[string]string) []any {
+	kept := make([]any, 0, len(links))
+	for _, raw := range links {
+		link, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		if graphID, _ := link["graphId"].(string); graphID != "" {
+			kept = append(kept, link)
+			continue
+		}
+		nodeID, _ := link["nodeId"].(string)
+		if fresh, ok := nodeIDs[nodeID]; ok {
+			link["nodeId"] = fresh
+			kept = append(kept, link)
+		}
+	}
+	return kept
+}
+
+func refreshTemplateItems(items []any, variables map[string]string) {
+	for _, raw := range items {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		item["id"] = newID("item")
+		if title, ok := item["title"].(string); ok {
+			item["title"] = substituteTemplateVariables(title, variables)
+		}
+		if notes, ok := item["notes"].([]any); ok {
+			for _, rawNote := range notes {
+				if note, ok := rawNote.(map[string]any); ok {
+					note["id"] = newID("note")
+				}
+			}
+		}
+		if children, ok := item["children"].([]any); ok {
+			refreshTemplateItems(children, variables)
+		}
+	}
+}
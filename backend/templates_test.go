@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateVariablesMatchSubstitutedFields(t *testing.T) {
+	var graph graphPayload
+	err := json.Unmarshal([]byte(`{"name":"{{project}} plan","nodes":[
+		{"id":"a","data":{"label":"{{ owner }}","nodeNotes":"{{notes_only}}","items":[
+			{"id":"i","title":"Ship {{release}}","itemNotes":"{{item_notes}}","children":[{"id":"c","title":"{{child}}"}]}
+		]},"style":{"fontFamily":"{{style_only}}"}}
+	],"edges":[]}`), &graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"project", "owner", "release", "child"}
+	if got := templateVariables(graph); !reflect.DeepEqual(got, want) {
+		t.Fatalf("templateVariables = %v, want %v", got, want)
+	}
+
+	values := map[string]string{}
+	for _, name := range want {
+		values[name] = "X"
+	}
+	instance, err := instantiateTemplate(graph, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(instance)
+	for _, name := range want {
+		if strings.Contains(string(encoded), "{{"+name) || strings.Contains(string(encoded), "{{ "+name) {
+			t.Fatalf("variable %s was listed but not substituted: %s", name, encoded)
+		}
+	}
+}
+
+func TestBuiltinTemplateVariables(t *testing.T) {
+	want := map[string][]string{
+		"builtin-mind-map":     {"topic"},
+		"builtin-project-plan": {"project"},
+		"builtin-study-topic":  {"subject"},
+	}
+	for id, variables := range want {
+		template, ok := findBuiltinTemplate(id)
+		if !ok {
+			t.Fatalf("missing built-in template %s", id)
+		}
+		if !reflect.DeepEqual(template.Variables, variables) {
+			t.Fatalf("%s variables = %v, want %v", id, template.Variables, variables)
+		}
+	}
+}
+
+func TestBuiltinTemplatesMatchGraphSchema(t *testing.T) {
+	for _, template := range builtinTemplates {
+		data, err := json.Marshal(template.Graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validateGraphData(data); err != nil {
+			t.Fatalf("%s does not validate: %v", template.ID, err)
+		}
+	}
+}
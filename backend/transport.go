@@ -0,0 +1,320 @@
+// Compressed request/response transport and streaming graph decoding.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+
+	// Compressed request bodies may decode to maxDecodedBodyRatio times their
+	// wire limit (for graph saves, the plan's maxPayloadBytes), and never
+	// past MAX_DECODED_BODY_BYTES.
+	maxDecodedBodyRatio        = 8
+	defaultMaxDecodedBodyBytes = 64 << 20
+)
+
+var (
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+	errTooManyNodes        = errors.New("too many nodes")
+)
+
+// openRequestBody returns the decoded request body. wireLimit bounds the bytes
+// received; compressed bodies may expand up to decodedLimit. Reads past either
+// limit fail with *http.MaxBytesError.
+func openRequestBody(w http.ResponseWriter, r *http.Request, wireLimit, decodedLimit int64) (io.ReadCloser, error) {
+	if r.Body == nil {
+		return nil, errors.New("missing body")
+	}
+	wire := http.MaxBytesReader(w, r.Body, wireLimit)
+
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return wire, nil
+	case encodingGzip:
+		reader, err := gzip.NewReader(wire)
+		if err != nil {
+			wire.Close()
+			return nil, err
+		}
+		return decodedBody{Reader: http.MaxBytesReader(w, reader, decodedLimit), closers: []io.Closer{reader, wire}}, nil
+	case encodingZstd:
+		decoder, err := zstd.NewReader(wire, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(decodedLimit)))
+		if err != nil {
+			wire.Close()
+			return nil, err
+		}
+		closer := closerFunc(func() error {
+			decoder.Close()
+			return nil
+		})
+		return decodedBody{Reader: http.MaxBytesReader(w, io.NopCloser(decoder), decodedLimit), closers: []io.Closer{closer, wire}}, nil
+	default:
+		wire.Close()
+		return nil, errUnsupportedEncoding
+	}
+}
+
+// decodedBodyLimit is the decoded size allowed for a body of at most
+// wireLimit bytes on the wire.
+func (s *server) decodedBodyLimit(wireLimit int64) int64 {
+	return min(wireLimit*maxDecodedBodyRatio, s.maxDecodedBodyBytes)
+}
+
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b decodedBody) Close() error {
+	var err error
+	for _, closer := range b.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// readRequestJSON reads a small (possibly compressed) JSON request body of at
+// most limit bytes on the wire. On failure it has already written the
+// response.
+func (s *server) readRequestJSON(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	body, err := openRequestBody(w, r, limit, s.decodedBodyLimit(limit))
+	if errors.Is(err, errUnsupportedEncoding) {
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return nil, false
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return nil, false
+	}
+	return data, true
+}
+
+// decodeGraphPayload streams a graph payload, decoding nodes and edges one
+// element at a time so oversized graphs are rejected before they are fully
+// read; other top-level fields are kept in payload.Extra. Only the decoded
+// values are held in memory, never the raw body. maxNodes <= 0 disables the
+// node limit. An empty body yields ok=false with no error.
+func decodeGraphPayload(r io.Reader, maxNodes int64) (payload graphPayload, ok bool, err error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if errors.Is(err, io.EOF) {
+		return payload, false, nil
+	}
+	if err != nil {
+		return payload, false, err
+	}
+	if delim, isDelim := token.(json.Delim); !isDelim || delim != '{' {
+		return payload, false, errors.New("graph must be a json object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return payload, false, err
+		}
+		key, _ := token.(string)
+		switch strings.ToLower(key) {
+		case "name":
+			err = decoder.Decode(&payload.Name)
+		case "kind":
+			err = decoder.Decode(&payload.Kind)
+		case "nodes":
+			payload.Nodes, err = decodeJSONArrayStream(decoder, maxNodes)
+		case "edges":
+			payload.Edges, err = decodeJSONArrayStream(decoder, 0)
+		default:
+			var value json.RawMessage
+			if err = decoder.Decode(&value); err == nil {
+				if payload.Extra == nil {
+					payload.Extra = map[string]json.RawMessage{}
+				}
+				payload.Extra[key] = value
+			}
+		}
+		if err != nil {
+			return payload, false, err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return payload, false, err
+	}
+	return payload, true, nil
+}
+
+// decodeJSONArrayStream copies a JSON array (or null) element by element.
+func decodeJSONArrayStream(decoder *json.Decoder, maxEntries int64) (json.RawMessage, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+	if delim, isDelim := token.(json.Delim); !isDelim || delim != '[' {
+		return nil, errors.New("expected json array")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	var count int64
+	// entry is reused: RawMessage decoding appends into its existing buffer.
+	var entry json.RawMessage
+	for decoder.More() {
+		count++
+		if maxEntries > 0 && count > maxEntries {
+			return nil, fmt.Errorf("%w: more than %d", errTooManyNodes, maxEntries)
+		}
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if count > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(entry)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// withCompression compresses responses with zstd or gzip when the client
+// accepts it. Bodiless responses (204/304) pass through untouched.
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressedResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks zstd over gzip; entries with q=0 are ignored.
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q <= 0 {
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+	switch {
+	case accepted[encodingZstd]:
+		return encodingZstd
+	case accepted[encodingGzip]:
+		return encodingGzip
+	default:
+		return ""
+	}
+}
+
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     io.WriteCloser
+	wroteHeader bool
+	passthrough bool
+}
+
+func (c *compressedResponseWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	header := c.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || isCompressedMediaType(header.Get("Content-Type")) {
+		c.passthrough = true
+	} else {
+		header.Set("Content-Encoding", c.encoding)
+		header.Del("Content-Length")
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// isCompressedMediaType reports payloads that gain nothing from another encoding pass.
+func isCompressedMediaType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	switch {
+	case strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml" && mediaType != "image/bmp":
+		return true
+	case mediaType == "application/pdf", mediaType == "application/zip", mediaType == "application/gzip":
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *compressedResponseWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.passthrough {
+		return c.ResponseWriter.Write(p)
+	}
+	if c.encoder == nil {
+		switch c.encoding {
+		case encodingZstd:
+			encoder, err := zstd.NewWriter(c.ResponseWriter, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+			if err != nil {
+				return 0, err
+			}
+			c.encoder = encoder
+		default:
+			c.encoder = gzip.NewWriter(c.ResponseWriter)
+		}
+	}
+	return c.encoder.Write(p)
+}
+
+func (c *compressedResponseWriter) Close() error {
+	if c.encoder == nil {
+		return nil
+	}
+	return c.encoder.Close()
+}
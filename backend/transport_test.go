@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeGraphPayloadKeepsExtraFields(t *testing.T) {
+	body := `{"name":"G","viewport":{"x":1,"zoom":2},"nodes":[{"id":"a"}],"edges":[],"settings":["dark"]}`
+	payload, ok, err := decodeGraphPayload(strings.NewReader(body), 0)
+	if err != nil || !ok {
+		t.Fatalf("decode: ok = %v, err = %v", ok, err)
+	}
+	if string(payload.Extra["viewport"]) != `{"x":1,"zoom":2}` || string(payload.Extra["settings"]) != `["dark"]` {
+		t.Fatalf("extra fields = %v", payload.Extra)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got map[string]any
+	json.Unmarshal([]byte(body), &want)
+	json.Unmarshal(encoded, &got)
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(wantJSON, gotJSON) {
+		t.Fatalf("re-marshaled payload:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	var decoded graphPayload
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "G" || len(decoded.Extra) != 2 {
+		t.Fatalf("unmarshaled payload = %+v", decoded)
+	}
+}
+
+func TestGraphPayloadExtraCannotShadowFields(t *testing.T) {
+	payload := graphPayload{
+		Name:  "real",
+		Nodes: json.RawMessage(`[]`),
+		Edges: json.RawMessage(`[]`),
+		Extra: map[string]json.RawMessage{"Name": json.RawMessage(`"fake"`), "nodes": json.RawMessage(`[1]`)},
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"edges":[],"name":"real","nodes":[]}` {
+		t.Fatalf("encoded = %s", encoded)
+	}
+}
+
+func TestDecodeGraphPayloadNodeLimit(t *testing.T) {
+	body := `{"nodes":[{"id":"a"},{"id":"b"},{"id":"c"}],"edges":[]}`
+	if _, _, err := decodeGraphPayload(strings.NewReader(body), 2); !errors.Is(err, errTooManyNodes) {
+		t.Fatalf("err = %v, want errTooManyNodes", err)
+	}
+	payload, _, err := decodeGraphPayload(strings.NewReader(body), 3)
+	if err != nil || string(payload.Nodes) != `[{"id":"a"},{"id":"b"},{"id":"c"}]` {
+		t.Fatalf("nodes = %s, err = %v", payload.Nodes, err)
+	}
+}
+
+func TestDecodeGraphPayloadRejectsMalformed(t *testing.T) {
+	tests := map[string]string{
+		"array":          `[]`,
+		"nodes object":   `{"nodes":{}}`,
+		"truncated":      `{"nodes":[{"id":"a"}`,
+		"trailing comma": `{"name":"a",}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := decodeGraphPayload(strings.NewReader(body), 0); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	if _, ok, err := decodeGraphPayload(strings.NewReader(""), 0); ok || err != nil {
+		t.Fatalf("empty body: ok = %v, err = %v", ok, err)
+	}
+}
+
+func TestReadRequestJSONDecompresses(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(`{"base":{}}`))
+	zw.Close()
+
+	s := &server{maxDecodedBodyBytes: 1 << 20}
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	body, ok := s.readRequestJSON(rec, req, 1<<10)
+	if !ok || string(body) != `{"base":{}}` {
+		t.Fatalf("body = %q, ok = %v, status %d", body, ok, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Encoding", "br")
+	rec = httptest.NewRecorder()
+	if _, ok := s.readRequestJSON(rec, req, 1<<10); ok || rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unsupported encoding: ok = %v, status %d", ok, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 2048)))
+	rec = httptest.NewRecorder()
+	if _, ok := s.readRequestJSON(rec, req, 1<<10); ok || rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body: ok = %v, status %d", ok, rec.Code)
+	}
+}
+
+func TestDecodedBodyLimitFollowsWireLimit(t *testing.T) {
+	s := &server{maxDecodedBodyBytes: 64 << 20}
+	if got := s.decodedBodyLimit(2 << 20); got != 2<<20*maxDecodedBodyRatio {
+		t.Fatalf("decoded limit for 2 MB = %d", got)
+	}
+	if got := s.decodedBodyLimit(maxUnlimitedBodyBytes); got != 64<<20 {
+		t.Fatalf("decoded limit for unlimited plans = %d, want the server cap", got)
+	}
+
+	// A small gzip body that expands past its decoded limit is rejected as
+	// a decoded-size quota error, not as the plan's wire limit.
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(bytes.Repeat([]byte(" "), 64<<10))
+	zw.Close()
+	limits := planLimits{MaxPayloadBytes: 4 << 10}
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	body, err := openRequestBody(rec, req, payloadWireLimit(limits), s.decodedBodyLimit(payloadWireLimit(limits)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(body)
+	body.Close()
+	writeBodyError(rec, req, "free", limits, err)
+	var quota quotaError
+	json.Unmarshal(rec.Body.Bytes(), &quota)
+	if rec.Code != http.StatusRequestEntityTooLarge || quota.Quota != "decodedPayloadBytes" || quota.Limit != 4<<10*maxDecodedBodyRatio {
+		t.Fatalf("status %d, quota %+v", rec.Code, quota)
+	}
+}
@@ -3,15 +3,71 @@ package main
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
 // graphPayload mirrors frontend GraphPayload with JSON-encoded nodes/edges.
+// Other top-level fields are kept in Extra and written back, so a save
+// stores everything the client sent.
 type graphPayload struct {
-	Name  string          `json:"name"`
-	Nodes json.RawMessage `json:"nodes"`
-	Edges json.RawMessage `json:"edges"`
-	Kind  string          `json:"kind,omitempty"`
+	Name  string                     `json:"name"`
+	Nodes json.RawMessage            `json:"nodes"`
+	Edges json.RawMessage            `json:"edges"`
+	Kind  string                     `json:"kind,omitempty"`
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// isGraphPayloadField reports keys decoded into graphPayload's own fields,
+// matched case-insensitively like encoding/json does.
+func isGraphPayloadField(key string) bool {
+	for _, field := range []string{"name", "nodes", "edges", "kind"} {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p graphPayload) MarshalJSON() ([]byte, error) {
+	type plain graphPayload
+	data, err := json.Marshal(plain(p))
+	if err != nil || len(p.Extra) == 0 {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage, len(p.Extra)+4)
+	for key, value := range p.Extra {
+		if !isGraphPayloadField(key) {
+			fields[key] = value
+		}
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func (p *graphPayload) UnmarshalJSON(data []byte) error {
+	type plain graphPayload
+	var known plain
+	if err := json.Unmarshal(data, &known); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key := range fields {
+		if isGraphPayloadField(key) {
+			delete(fields, key)
+		}
+	}
+	known.Extra = nil
+	if len(fields) > 0 {
+		known.Extra = fields
+	}
+	*p = graphPayload(known)
+	return nil
 }
 
 // graphSummary is returned in graph lists.
@@ -19,4 +75,6 @@ type graphSummary struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	Tags      []string  `json:"tags,omitempty"`
+	Folder    string    `json:"folder,omitempty"`
 }
@@ -11,12 +11,33 @@ import (
 	"strings"
 )
 
+const (
+	defaultMaxBodyBytes = 2 << 20
+	// Ceiling used when a plan leaves the payload size unlimited.
+	maxUnlimitedBodyBytes = 256 << 20
+)
+
+var errBodyTooLarge = errors.New("body too large")
+
 func readBody(r *http.Request) ([]byte, error) {
+	return readBodyLimit(r, defaultMaxBodyBytes)
+}
+
+// readBodyLimit reads at most limit bytes and reports errBodyTooLarge instead
+// of silently truncating larger bodies.
+func readBodyLimit(r *http.Request, limit int64) ([]byte, error) {
 	if r.Body == nil {
 		return nil, errors.New("missing body")
 	}
 	defer r.Body.Close()
-	return io.ReadAll(io.LimitReader(r.Body, 2<<20))
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, errBodyTooLarge
+	}
+	return body, nil
 }
 
 func generateID() (string, error) {
@@ -28,7 +49,12 @@ func generateID() (string, error) {
 }
 
 func writeJSON(w http.ResponseWriter, value any) {
+	writeJSONStatus(w, http.StatusOK, value)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, value any) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	encoder := json.NewEncoder(w)
 	if err := encoder.Encode(value); err != nil {
 		http.Error(w, "failed to encode response", http.StatusInternalServerError)
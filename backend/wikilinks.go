@@ -0,0 +1,571 @@
+// Wiki-link ([[Label]]) parsing from node and item notes, label resolution and
+// conversion of resolved links into edges.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	wikiLinkResolved   = "resolved"
+	wikiLinkAmbiguous  = "ambiguous"
+	wikiLinkUnresolved = "unresolved"
+)
+
+var (
+	wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+	htmlTagPattern  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// wikiLinkRow is one [[...]] occurrence found in a node's notes (ItemID empty)
+// or in one of its items' notes.
+type wikiLinkRow struct {
+	SourceNodeID string
+	ItemID       string
+	Target       string
+}
+
+type wikiLinkTarget struct {
+	GraphID   string `json:"graphId"`
+	GraphName string `json:"graphName"`
+	NodeID    string `json:"nodeId"`
+	Label     string `json:"label"`
+}
+
+type wikiLink struct {
+	NodeID string `json:"nodeId"`
+	ItemID string `json:"itemId,omitempty"`
+	Text   string `json:"text"`
+	Status string `json:"status"`
+	// Target is the chosen match; for ambiguous links the most recently
+	// updated candidate, preferring the current graph.
+	Target     *wikiLinkTarget `json:"target,omitempty"`
+	Candidates int             `json:"candidates,omitempty"`
+}
+
+type wikiLinksResponse struct {
+	Links      []wikiLink `json:"links"`
+	Unresolved []string   `json:"unresolved"`
+}
+
+type wikiEdgesRequest struct {
+	// IncludeAmbiguous also converts links that matched several nodes, using Target.
+	IncludeAmbiguous bool `json:"includeAmbiguous,omitempty"`
+}
+
+type wikiEdgesResponse struct {
+	Edges     []json.RawMessage `json:"edges"`
+	Links     []nodeLinkRow     `json:"links"`
+	Saved     bool              `json:"saved"`
+	UpdatedAt *time.Time        `json:"updatedAt,omitempty"`
+}
+
+type wikiItem struct {
+	ID        string            `json:"id"`
+	ItemNotes string            `json:"itemNotes"`
+	Children  []json.RawMessage `json:"children"`
+}
+
+// extractWikiLinks parses [[...]] references from nodeNotes and itemNotes.
+// Nodes and items that do not decode are skipped so one malformed entry
+// does not drop the links of the rest of the graph.
+func extractWikiLinks(nodes json.RawMessage) []wikiLinkRow {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(nodes, &entries); err != nil {
+		return nil
+	}
+
+	var rows []wikiLinkRow
+	add := func(nodeID, itemID, notes string) {
+		for _, target := range wikiLinkTargets(notes) {
+			row := wikiLinkRow{SourceNodeID: nodeID, ItemID: itemID, Target: target}
+			if !slices.Contains(rows, row) {
+				rows = append(rows, row)
+			}
+		}
+	}
+	var walkItems func(nodeID string, items []json.RawMessage)
+	walkItems = func(nodeID string, items []json.RawMessage) {
+		for _, raw := range items {
+			var item wikiItem
+			if err := json.Unmarshal(raw, &item); err != nil {
+				continue
+			}
+			add(nodeID, item.ID, item.ItemNotes)
+			walkItems(nodeID, item.Children)
+		}
+	}
+	for _, entry := range entries {
+		var node struct {
+			ID   string `json:"id"`
+			Data struct {
+				NodeNotes string            `json:"nodeNotes"`
+				Items     []json.RawMessage `json:"items"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(entry, &node); err != nil || node.ID == "" {
+			continue
+		}
+		add(node.ID, "", node.Data.NodeNotes)
+		walkItems(node.ID, node.Data.Items)
+	}
+	return rows
+}
+
+// wikiLinkTargets returns the link targets in an Editor.js document (or plain
+// text). "[[Label|alias]]" and "[[Label#section]]" both resolve to Label.
+func wikiLinkTargets(notes string) []string {
+	if strings.TrimSpace(notes) == "" || !strings.Contains(notes, "[[") {
+		return nil
+	}
+
+	var texts []string
+	var document any
+	if err := json.Unmarshal([]byte(notes), &document); err == nil {
+		collectJSONStrings(document, &texts)
+	} else {
+		texts = []string{notes}
+	}
+
+	var targets []string
+	for _, text := range texts {
+		for _, match := range wikiLinkPattern.FindAllStringSubmatch(text, -1) {
+			target := html.UnescapeString(htmlTagPattern.ReplaceAllString(match[1], ""))
+			target, _, _ = strings.Cut(target, "|")
+			target, _, _ = strings.Cut(target, "#")
+			target = strings.Join(strings.Fields(target), " ")
+			if target != "" && !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+func collectJSONStrings(value any, out *[]string) {
+	switch v := value.(type) {
+	case string:
+		*out = append(*out, v)
+	case []any:
+		for _, entry := range v {
+			collectJSONStrings(entry, out)
+		}
+	case map[string]any:
+		for _, entry := range v {
+			collectJSONStrings(entry, out)
+		}
+	}
+}
+
+// replaceWikiLinks rewrites the parsed wiki-links stored for graphID.
+func replaceWikiLinks(ctx context.Context, q querier, userID, graphID string, nodes json.RawMessage) error {
+	if _, err := q.Exec(ctx, "DELETE FROM graph_wikilinks WHERE user_id = $1 AND graph_id = $2", userID, graphID); err != nil {
+		return err
+	}
+	rows := extractWikiLinks(nodes)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	nodeIDs := make([]string, len(rows))
+	itemIDs := make([]string, len(rows))
+	targets := make([]string, len(rows))
+	for i, row := range rows {
+		nodeIDs[i] = row.SourceNodeID
+		itemIDs[i] = row.ItemID
+		targets[i] = row.Target
+	}
+	_, err := q.Exec(
+		ctx,
+		`INSERT INTO graph_wikilinks (user_id, graph_id, source_node_id, item_id, target, position)
+		 SELECT $1, $2, t.source_node_id, t.item_id, t.target, t.position
+		 FROM unnest($3::text[], $4::text[], $5::text[]) WITH ORDINALITY AS t(source_node_id, item_id, target, position)`,
+		userID,
+		graphID,
+		nodeIDs,
+		itemIDs,
+		targets,
+	)
+	return err
+}
+
+// loadWikiLinks reads the stored wiki-links of graphID in note order.
+func loadWikiLinks(ctx context.Context, q querier, userID, graphID string) ([]wikiLinkRow, error) {
+	rows, err := q.Query(
+		ctx,
+		`SELECT source_node_id, item_id, target
+		 FROM graph_wikilinks
+		 WHERE user_id = $1 AND graph_id = $2
+		 ORDER BY position`,
+		userID,
+		graphID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []wikiLinkRow
+	for rows.Next() {
+		var link wikiLinkRow
+		if err := rows.Scan(&link.SourceNodeID, &link.ItemID, &link.Target); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// resolveWikiLinks matches link targets to node labels (case-insensitive),
+// first inside the graph itself and then across the user's other graphs.
+func resolveWikiLinks(ctx context.Context, q querier, userID, graphID, graphName string, nodes json.RawMessage, rows []wikiLinkRow) ([]wikiLink, error) {
+	var parsed []struct {
+		ID   string `json:"id"`
+		Data struct {
+			Label string `json:"label"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(nodes, &parsed)
+
+	local := make(map[string][]wikiLinkTarget)
+	for _, node := range parsed {
+		key := strings.ToLower(strings.TrimSpace(node.Data.Label))
+		if node.ID != "" && key != "" {
+			local[key] = append(local[key], wikiLinkTarget{GraphID: graphID, GraphName: graphName, NodeID: node.ID, Label: node.Data.Label})
+		}
+	}
+
+	var remoteKeys []string
+	for _, row := range rows {
+		key := strings.ToLower(row.Target)
+		if _, ok := local[key]; !ok && !slices.Contains(remoteKeys, key) {
+			remoteKeys = append(remoteKeys, key)
+		}
+	}
+
+	remote := make(map[string][]wikiLinkTarget)
+	if len(remoteKeys) > 0 {
+		result, err := q.Query(
+			ctx,
+			`SELECT g.id, g.name, n->>'id', n->'data'->>'label'
+			 FROM graphs g
+			 CROSS JOIN LATERAL jsonb_array_elements(
+			   CASE WHEN jsonb_typeof(g.data->'nodes') = 'array' THEN g.data->'nodes' ELSE '[]'::jsonb END
+			 ) AS n
+			 WHERE g.user_id = $1 AND g.id <> $2
+			   AND lower(trim(n->'data'->>'label')) = ANY($3)
+			 ORDER BY g.updated_at DESC`,
+			userID,
+			graphID,
+			remoteKeys,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for result.Next() {
+			var target wikiLinkTarget
+			if err := result.Scan(&target.GraphID, &target.GraphName, &target.NodeID, &target.Label); err != nil {
+				result.Close()
+				return nil, err
+			}
+			key := strings.ToLower(strings.TrimSpace(target.Label))
+			remote[key] = append(remote[key], target)
+		}
+		result.Close()
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	links := make([]wikiLink, 0, len(rows))
+	for _, row := range rows {
+		link := wikiLink{NodeID: row.SourceNodeID, ItemID: row.ItemID, Text: row.Target, Status: wikiLinkUnresolved}
+		key := strings.ToLower(row.Target)
+		matches := local[key]
+		if len(matches) == 0 {
+			matches = remote[key]
+		}
+		if len(matches) > 0 {
+			target := matches[0]
+			link.Target = &target
+			link.Status = wikiLinkResolved
+			if len(matches) > 1 {
+				link.Status = wikiLinkAmbiguous
+				link.Candidates = len(matches)
+			}
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// Routes /api/graphs/:id/wikilinks[/edges].
+func (s *server) handleWikiLinks(w http.ResponseWriter, r *http.Request, graphID, rest string) {
+	switch rest {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleListWikiLinks(w, r, graphID)
+	case "edges":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleWikiLinkEdges(w, r, graphID)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// GET /api/graphs/:id/wikilinks: parsed links with their resolution (?status= filters).
+func (s *server) handleListWikiLinks(w http.ResponseWriter, r *http.Request, graphID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var name string
+	var nodes []byte
+	err = s.pool.QueryRow(ctx, "SELECT name, data->'nodes' FROM graphs WHERE id=$1 AND user_id=$2", graphID, userID).Scan(&name, &nodes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to load wiki-links", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := loadWikiLinks(ctx, s.pool, userID, graphID)
+	if err != nil {
+		log.Printf("failed to load wiki-links: %v", err)
+		http.Error(w, "failed to load wiki-links", http.StatusInternalServerError)
+		return
+	}
+	links, err := resolveWikiLinks(ctx, s.pool, userID, graphID, name, nodes, rows)
+	if err != nil {
+		log.Printf("failed to resolve wiki-links: %v", err)
+		http.Error(w, "failed to load wiki-links", http.StatusInternalServerError)
+		return
+	}
+
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	response := wikiLinksResponse{Links: []wikiLink{}, Unresolved: []string{}}
+	for _, link := range links {
+		if link.Status == wikiLinkUnresolved && !slices.Contains(response.Unresolved, link.Text) {
+			response.Unresolved = append(response.Unresolved, link.Text)
+		}
+		if status == "" || link.Status == status {
+			response.Links = append(response.Links, link)
+		}
+	}
+
+	writeJSON(w, response)
+}
+
+// POST /api/graphs/:id/wikilinks/edges: turn resolved links into edges (same
+// graph) or node links (other graphs) and save the graph.
+func (s *server) handleWikiLinkEdges(w http.ResponseWriter, r *http.Request, graphID string) {
+	userID, err := s.requireUserID(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, ok := s.readRequestJSON(w, r, defaultMaxBodyBytes)
+	if !ok {
+		return
+	}
+	var req wikiEdgesRequest
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	plan, limits, err := s.userPlan(ctx, userID)
+	if err != nil {
+		log.Printf("failed to load plan: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("failed to begin wiki-link conversion: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var current []byte
+	err = tx.QueryRow(ctx, "SELECT data FROM graphs WHERE id=$1 AND user_id=$2 FOR UPDATE", graphID, userID).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "graph not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("failed to read graph: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+
+	var payload graphPayload
+	var nodes, edges []map[string]any
+	if err := json.Unmarshal(current, &payload); err != nil ||
+		json.Unmarshal(payload.Nodes, &nodes) != nil ||
+		json.Unmarshal(payload.Edges, &edges) != nil {
+		http.Error(w, "stored graph is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+
+	rows, err := loadWikiLinks(ctx, tx, userID, graphID)
+	if err != nil {
+		log.Printf("failed to load wiki-links: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+	links, err := resolveWikiLinks(ctx, tx, userID, graphID, payload.Name, payload.Nodes, rows)
+	if err != nil {
+		log.Printf("failed to resolve wiki-links: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+
+	connected := make(map[[2]string]struct{}, len(edges))
+	for _, edge := range edges {
+		source, _ := edge["source"].(string)
+		target, _ := edge["target"].(string)
+		connected[[2]string{source, target}] = struct{}{}
+		connected[[2]string{target, source}] = struct{}{}
+	}
+	nodesByID := make(map[string]map[string]any, len(nodes))
+	for _, node := range nodes {
+		if id, ok := node["id"].(string); ok {
+			nodesByID[id] = node
+		}
+	}
+
+	response := wikiEdgesResponse{Edges: []json.RawMessage{}, Links: []nodeLinkRow{}}
+	for _, link := range links {
+		if link.Target == nil || (link.Status == wikiLinkAmbiguous && !req.IncludeAmbiguous) {
+			continue
+		}
+		source, target := link.NodeID, link.Target.NodeID
+		if link.Target.GraphID == graphID {
+			if source == target {
+				continue
+			}
+			if _, ok := connected[[2]string{source, target}]; ok {
+				continue
+			}
+			connected[[2]string{source, target}] = struct{}{}
+			connected[[2]string{target, source}] = struct{}{}
+			edge := map[string]any{
+				"id":     newID("edge"),
+				"source": source,
+				"target": target,
+				"type":   "smoothstep",
+				"data":   map[string]any{"directed": true},
+			}
+			edges = append(edges, edge)
+			raw, _ := json.Marshal(edge)
+			response.Edges = append(response.Edges, raw)
+			continue
+		}
+
+		node, ok := nodesByID[source]
+		if !ok {
+			continue
+		}
+		if addNodeLink(node, link.Target.GraphID, target) {
+			response.Links = append(response.Links, nodeLinkRow{
+				SourceNodeID:  source,
+				TargetGraphID: link.Target.GraphID,
+				TargetNodeID:  target,
+			})
+		}
+	}
+
+	if len(response.Edges) == 0 && len(response.Links) == 0 {
+		writeJSON(w, response)
+		return
+	}
+
+	if payload.Nodes, err = json.Marshal(nodes); err == nil {
+		payload.Edges, err = json.Marshal(edges)
+	}
+	var data []byte
+	if err == nil {
+		data, err = json.Marshal(payload)
+	}
+	if err != nil {
+		http.Error(w, "failed to encode graph", http.StatusInternalServerError)
+		return
+	}
+
+	quotaErr, err := checkGraphQuota(ctx, tx, userID, graphID, plan, limits, payload, len(data))
+	if err != nil {
+		log.Printf("failed to check quota: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+	if quotaErr != nil {
+		writeQuotaError(w, quotaErr)
+		return
+	}
+
+	updatedAt, err := upsertGraph(ctx, tx, graphID, userID, payload, data)
+	if writeInvalidGraph(w, err) || writeInvalidLinks(w, err) {
+		return
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("failed to save wiki-link edges: %v", err)
+		http.Error(w, "failed to create edges", http.StatusInternalServerError)
+		return
+	}
+
+	response.Saved = true
+	response.UpdatedAt = &updatedAt
+	writeJSON(w, response)
+}
+
+// addNodeLink appends {graphId, nodeId} to node data.links unless present.
+func addNodeLink(node map[string]any, graphID, nodeID string) bool {
+	data, ok := node["data"].(map[string]any)
+	if !ok {
+		data = map[string]any{}
+		node["data"] = data
+	}
+	links, _ := data["links"].([]any)
+	for _, raw := range links {
+		if link, ok := raw.(map[string]any); ok && link["graphId"] == graphID && link["nodeId"] == nodeID {
+			return false
+		}
+	}
+	data["links"] = append(links, map[string]any{"graphId": graphID, "nodeId": nodeID})
+	return true
+}
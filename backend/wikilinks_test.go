@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExtractWikiLinksSkipsOnlyMalformedEntries(t *testing.T) {
+	nodes := json.RawMessage(`[
+		{"id":"a","data":{"nodeNotes":"see [[Beta]]","items":[
+			{"id":"i1","itemNotes":"[[Gamma|g]]","children":[{"id":"i2","itemNotes":"[[Delta#intro]]"}]},
+			{"id":"bad-item","itemNotes":{"blocks":[]}}
+		]}},
+		{"id":"broken","data":{"nodeNotes":42}},
+		"not a node",
+		{"id":"c","data":{"nodeNotes":"[[Alpha]] and [[Alpha]]"}}
+	]`)
+	want := []wikiLinkRow{
+		{SourceNodeID: "a", Target: "Beta"},
+		{SourceNodeID: "a", ItemID: "i1", Target: "Gamma"},
+		{SourceNodeID: "a", ItemID: "i2", Target: "Delta"},
+		{SourceNodeID: "c", Target: "Alpha"},
+	}
+	if got := extractWikiLinks(nodes); !reflect.DeepEqual(got, want) {
+		t.Fatalf("extractWikiLinks =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestExtractWikiLinksInvalidArray(t *testing.T) {
+	for _, raw := range []string{`{}`, `null`, `[`} {
+		if got := extractWikiLinks(json.RawMessage(raw)); len(got) != 0 {
+			t.Fatalf("extractWikiLinks(%s) = %v", raw, got)
+		}
+	}
+}
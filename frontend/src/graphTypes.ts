@@ -14,6 +14,12 @@ export type Item = {
   children: Item[]
 }
 
+// Reference to a node in another graph (or this one when graphId is empty).
+export type NodeLink = {
+  graphId: string
+  nodeId: string
+}
+
 export type NodeData = {
   label: string
   items: Item[]
@@ -25,6 +31,9 @@ export type NodeData = {
   }
   progress?: number
   scriptName?: string
+  links?: NodeLink[]
+  // Set by importers: source IDs and timestamps (e.g. createdAt from Roam).
+  metadata?: Record<string, string>
 }
 
 export type GraphNode = Node<NodeData>
@@ -145,6 +145,8 @@ export const normalizeGraph = (payload: GraphPayload | null, fallbackKind: Graph
       typeof (rawData as NodeData).scriptName === 'string' ? (rawData as NodeData).scriptName : ''
     const nodeNotes =
       typeof (rawData as NodeData).nodeNotes === 'string' ? (rawData as NodeData).nodeNotes : ''
+    const metadata = (rawData as NodeData).metadata
+    const links = (rawData as NodeData).links
     return {
       ...node,
       type: node.type ?? 'default',
@@ -157,6 +159,8 @@ export const normalizeGraph = (payload: GraphPayload | null, fallbackKind: Graph
         position3d,
         progress,
         scriptName,
+        ...(Array.isArray(links) ? { links } : {}),
+        ...(metadata && typeof metadata === 'object' ? { metadata } : {}),
       },
       style,
     }